@@ -30,7 +30,8 @@ func (s *Sideload) All(source yenstream.Pipeline) yenstream.Pipeline {
 	}
 	normalSource := source.
 		Via("filter_tampered", yenstream.NewFilter(s.ctx, func(cdata *stat_replica.CdcMessage) (bool, error) {
-			if tampered[cdata.SourceMetadata.Table] {
+			// truncate dan schema change tidak perlu sideload
+			if tampered[cdata.SourceMetadata.Table] && !cdata.IsControl() {
 				return false, nil
 			}
 			return true, nil
@@ -40,7 +41,7 @@ func (s *Sideload) All(source yenstream.Pipeline) yenstream.Pipeline {
 
 	orderadj := source.
 		Via("sideload_filter_ord_adjustment", yenstream.NewFilter(s.ctx, func(cdata *stat_replica.CdcMessage) (bool, error) {
-			if cdata.SourceMetadata.Table != "order_adjustments" {
+			if cdata.SourceMetadata.Table != "order_adjustments" || cdata.IsControl() {
 				return false, nil
 			}
 
@@ -56,11 +57,7 @@ func (s *Sideload) All(source yenstream.Pipeline) yenstream.Pipeline {
 				for _, cdata := range cdatas {
 					d := cdata.Data.(*models.OrderAdjustment)
 
-					ord := &models.Order{
-						ID: d.OrderID,
-					}
-
-					found, err := s.exact.GetItemStruct(ord)
+					_, found, err := exact_one.Get[models.Order](s.exact, (&models.Order{ID: d.OrderID}).Key())
 					if err != nil {
 						return result, err
 					}
@@ -86,7 +83,7 @@ func (s *Sideload) All(source yenstream.Pipeline) yenstream.Pipeline {
 
 	invtx := source.
 		Via("filter_inv_transaction", yenstream.NewFilter(s.ctx, func(cdata *stat_replica.CdcMessage) (bool, error) {
-			if cdata.SourceMetadata.Table != "inv_transactions" {
+			if cdata.SourceMetadata.Table != "inv_transactions" || cdata.IsControl() {
 				return false, nil
 			}
 			return true, nil
@@ -101,11 +98,7 @@ func (s *Sideload) All(source yenstream.Pipeline) yenstream.Pipeline {
 				for _, cdata := range cdatas {
 					d := cdata.Data.(*models.InvTransaction)
 
-					ordinv := &models.InvOrderData{
-						InvID: d.ID,
-					}
-
-					found, err := s.exact.GetItemStruct(ordinv)
+					_, found, err := exact_one.Get[models.InvOrderData](s.exact, (&models.InvOrderData{InvID: d.ID}).Key())
 					if err != nil {
 						return result, err
 					}
@@ -146,7 +139,7 @@ func (s *Sideload) All(source yenstream.Pipeline) yenstream.Pipeline {
 func (s *Sideload) OrderSideload(source yenstream.Pipeline) yenstream.Pipeline {
 	return source.
 		Via("filter_order_sideload", yenstream.NewFilter(s.ctx, func(cdata *stat_replica.CdcMessage) (bool, error) {
-			if cdata.SourceMetadata.Table != "orders" {
+			if cdata.SourceMetadata.Table != "orders" || cdata.IsControl() {
 				return false, nil
 			}
 			return true, nil
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	_ "expvar"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/stat_db"
+)
+
+// debugAddr default hanya localhost, set DEBUG_ADDR untuk expose ke luar.
+func debugAddr() string {
+	addr := os.Getenv("DEBUG_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:6060"
+	}
+	return addr
+}
+
+// serveDebug serves http.DefaultServeMux, termasuk /debug/vars dari expvar (slot monitor) sampai ctx done.
+func serveDebug(ctx context.Context, addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           http.DefaultServeMux,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("debug server started", slog.String("addr", addr))
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error(err.Error(), slog.String("process", "debug server"))
+	}
+}
+
+// handlePrefixStats serves the key count and size of every prefix, dihitung saat request karena scan semua key.
+func handlePrefixStats(db *badger.DB, prefixes ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := stat_db.PrefixStats(db, prefixes...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	}
+}
+
+// applyRetention sets the ttl of keys written before the rules existed and serves their size at /debug/prefix.
+func applyRetention(db *badger.DB, retention *exact_one.Retention) {
+	count, err := retention.Apply(db)
+	if err != nil {
+		slog.Error(err.Error(), slog.String("process", "apply retention"))
+	}
+	if count > 0 {
+		slog.Info("retention applied", slog.Int("keys", count))
+	}
+
+	prefixes := []string{"metric_wal/", "metric_outbox/"}
+	for _, rule := range retention.Rules() {
+		prefixes = append(prefixes, rule.Prefix)
+	}
+	http.Handle("/debug/prefix", handlePrefixStats(db, prefixes...))
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -15,6 +16,7 @@ import (
 	"github.com/pdcgo/materialize/backfill_pipeline"
 	"github.com/pdcgo/materialize/backfill_pipeline/backfill"
 	"github.com/pdcgo/materialize/coders"
+	"github.com/pdcgo/materialize/dead_letter"
 	"github.com/pdcgo/materialize/selling_metric"
 	"github.com/pdcgo/materialize/selling_pipeline"
 	"github.com/pdcgo/materialize/stat_process/exact_one"
@@ -50,6 +52,8 @@ func main() {
 		fmt.Println("❌ Interrupt received. Shutting down...")
 		cancel()
 	}()
+	go serveDebug(ctx, debugAddr())
+
 	err = coders.WarehouseCoder(ctx)
 	if err != nil {
 		panic(err)
@@ -57,27 +61,58 @@ func main() {
 
 	cdataChan := make(chan *stat_replica.CdcMessage, 9)
 	repcfg := &stat_replica.ReplicationConfig{
-		SlotName:        "stat_slot",
+		SlotName:        slotName("accounting_slot"),
 		PublicationName: "stat_publication",
-		SlotTemporary:   true,
+		// slot permanen, temporary slot hilang saat koneksi putus dan checkpoint tidak bisa dilanjutkan
+		SlotTemporary: false,
 	}
 
 	backfilCfg := &backfill.BackfillConfig{
 		StartTime: time.Now().AddDate(0, 0, -5),
 	}
 
+	badgedb, err := stat_db.NewBadgeDB("./streamdata/replication")
+	if err != nil {
+		panic(err)
+	}
+	defer badgedb.Close()
+
+	err = restoreSnapshot(ctx, badgedb, repcfg.SlotName)
+	if err != nil {
+		panic(err)
+	}
+	http.Handle("/debug/snapshot", handleSnapshot(badgedb, repcfg.SlotName))
+	go scheduleSnapshot(ctx, badgedb, repcfg.SlotName)
+
+	deadLetter := dead_letter.NewBadgeQueue(ctx, badgedb)
+	ctx = dead_letter.ContextWithQueue(ctx, deadLetter)
+	dead_letter.RegisterHandler(http.DefaultServeMux, deadLetter)
+
+	checkpoint := stat_replica.NewSinkCheckpoint(stat_replica.NewBadgeCheckpoint(badgedb, repcfg.SlotName))
 	source := NewCDCStream(ctx,
 		backfilCfg,
 		repcfg,
+		checkpoint,
 		cdataChan,
 	)
 
 	go func() {
 		defer close(cdataChan)
+		count, err := deadLetter.ReplayPending(func(cdata *stat_replica.CdcMessage) error {
+			cdataChan <- cdata
+			return nil
+		})
+		if err != nil {
+			slog.Error(err.Error(), slog.String("process", "replay dead letter"))
+		}
+		if count > 0 {
+			slog.Info("dead letter replayed", slog.Int("count", count))
+		}
+
 		err = source.
 			Init().
 			Backfill().
-			// Stream().
+			Stream().
 			Err()
 
 		if err != nil {
@@ -86,13 +121,6 @@ func main() {
 
 	}()
 
-	badgedb, err := stat_db.NewBadgeDB("./streamdata/replication")
-	if err != nil {
-		panic(err)
-	}
-	defer badgedb.Close()
-	// defer badgedb.DropAll()
-
 	conn, err := backfill.ConnectProdDatabase(ctx)
 	if err != nil {
 		panic(err)
@@ -108,13 +136,19 @@ func main() {
 		panic(err)
 	}
 
+	retention := exact_one.NewRetention(
+		&exact_one.RetentionRule{Prefix: exact_one.AppliedPrefix, TTL: time.Hour * 24 * 7},
+	)
+	applyRetention(badgedb, retention)
+
 	exact := exact_one.NewBadgeExactOne(ctx, badgedb)
+	exact.SetRetention(retention)
+	go exact_one.RunPruneApplied(ctx, exact, checkpoint.Confirmed, time.Minute*10)
 
-	err = yenstream.
-		NewRunnerContext(ctx).
-		CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
-			// source := createLogStream(ctx, yenstream.NewChannelSource(ctx, cdataChan))
-			source := yenstream.NewChannelSource(ctx, cdataChan).Via("dummy", yenstream.NewMap(ctx, func(d any) (any, error) {
+	err = runSegments(ctx, cdataChan, checkpoint,
+		func(ctx *yenstream.RunnerContext, segment yenstream.Pipeline) yenstream.Pipeline {
+			// source := createLogStream(ctx, segment)
+			source := segment.Via("dummy", yenstream.NewMap(ctx, func(d any) (any, error) {
 				return d, nil
 			}))
 
@@ -216,8 +250,7 @@ func main() {
 					return data, err
 				}))
 
-		}).
-		Err()
+		})
 
 	if err != nil {
 		slog.Error("processor stopped", slog.String("err", err.Error()))
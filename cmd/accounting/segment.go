@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/yenstream"
+)
+
+type segmentResult struct {
+	tracked []*stat_replica.CdcMessage
+	closed  bool
+}
+
+// runSegments runs the pipeline per segment of cdataChan, satu segment selesai setelah semua stage,
+// exact one dan commit metric di sink selesai. Baru setelah itu pesan replication di segment itu
+// dikonfirmasi ke checkpoint, jadi lsn di slot tidak pernah mendahului pipeline.
+func runSegments(
+	ctx context.Context,
+	cdataChan chan *stat_replica.CdcMessage,
+	checkpoint *stat_replica.SinkCheckpoint,
+	build func(ctx *yenstream.RunnerContext, source yenstream.Pipeline) yenstream.Pipeline,
+) error {
+	control := selling_metric.GetMetricControl(ctx)
+
+	for {
+		segment := make(chan *stat_replica.CdcMessage, 9)
+		done := make(chan *segmentResult, 1)
+		go feedSegment(cdataChan, segment, control.Freshness(), done)
+
+		err := yenstream.
+			NewRunnerContext(ctx).
+			CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+				return build(ctx, yenstream.NewChannelSource(ctx, segment))
+			}).
+			Err()
+		if err != nil {
+			// pipeline berhenti, sisa segment dibuang dan tidak dikonfirmasi
+			go func() {
+				for range segment {
+				}
+			}()
+			<-done
+			return err
+		}
+
+		result := <-done
+		err = checkpoint.Done(result.tracked...)
+		if err != nil {
+			return err
+		}
+		if result.closed {
+			return nil
+		}
+	}
+}
+
+// feedSegment forwards cdataChan to segment until the interval passed with at least one message.
+func feedSegment(cdataChan chan *stat_replica.CdcMessage, segment chan *stat_replica.CdcMessage, interval time.Duration, done chan *segmentResult) {
+	result := &segmentResult{}
+	defer func() {
+		close(segment)
+		done <- result
+	}()
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	count := 0
+	for {
+		select {
+		case cdata, ok := <-cdataChan:
+			if !ok {
+				result.closed = true
+				return
+			}
+			segment <- cdata
+			count += 1
+			// semua pesan di Done, pesan yang tidak di Track (backfill, dead letter) diabaikan checkpoint
+			result.tracked = append(result.tracked, cdata)
+		case <-timer.C:
+			if count > 0 {
+				return
+			}
+			timer.Reset(interval)
+		}
+	}
+}
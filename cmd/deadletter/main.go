@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pdcgo/materialize/coders"
+	"github.com/pdcgo/materialize/dead_letter"
+	"github.com/pdcgo/materialize/stat_process/stat_db"
+	"github.com/pdcgo/materialize/stat_replica"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: deadletter [-addr host:port | -db path] list|inspect <id>|replay <id>|drop <id>")
+	fmt.Fprintln(os.Stderr, "badger dikunci service, pakai -addr selama playground / accounting jalan, -db hanya saat service berhenti.")
+	flag.PrintDefaults()
+}
+
+// queueClient is the part of the queue the cli needs, lokal lewat badger atau remote lewat debug server.
+type queueClient interface {
+	List() ([]*dead_letter.Entry, error)
+	Get(id string) (*dead_letter.Entry, error)
+	MarkReplay(id string) error
+	Drop(id string) error
+}
+
+func main() {
+	var err error
+	dbPath := flag.String("db", "./streamdata/replication", "lokasi badger db replication")
+	addr := flag.String("addr", "", "debug addr service yang jalan, ex 127.0.0.1:6060")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd := args[0]
+	if cmd != "list" && len(args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var queue queueClient
+	if *addr != "" {
+		queue = newRemoteQueue(*addr)
+	} else {
+		ctx := context.Background()
+		ctx = stat_replica.ContextWithCoder(ctx)
+		err = coders.WarehouseCoder(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		badgedb, err := stat_db.NewBadgeDB(*dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer badgedb.Close()
+
+		queue = dead_letter.NewBadgeQueue(ctx, badgedb)
+	}
+
+	err = run(queue, cmd, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(queue queueClient, cmd string, args []string) error {
+	switch cmd {
+	case "list":
+		entries, err := queue.List()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			table := ""
+			if entry.Message != nil && entry.Message.SourceMetadata != nil {
+				table = entry.Message.SourceMetadata.PrefixKey()
+			}
+			errText := entry.Err
+			if entry.DecodeErr != "" {
+				errText += " (decode: " + entry.DecodeErr + ")"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\treplay=%t\t%s\n",
+				entry.ID,
+				time.UnixMicro(entry.Timestamp).Format(time.RFC3339),
+				entry.Label,
+				table,
+				entry.Replay,
+				errText,
+			)
+		}
+	case "inspect":
+		entry, err := queue.Get(args[1])
+		if err != nil {
+			return err
+		}
+		raw, err := json.MarshalIndent(entry, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(raw))
+	case "replay":
+		// entry dikirim ulang ke pipeline saat playground / accounting start
+		return queue.MarkReplay(args[1])
+	case "drop":
+		return queue.Drop(args[1])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pdcgo/materialize/dead_letter"
+)
+
+// remoteQueue calls dead_letter.RegisterHandler of the running service.
+type remoteQueue struct {
+	base   string
+	client *http.Client
+}
+
+func newRemoteQueue(addr string) *remoteQueue {
+	return &remoteQueue{
+		base:   "http://" + addr + dead_letter.HandlerPath,
+		client: &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+func (r *remoteQueue) do(method, path string, result any) error {
+	req, err := http.NewRequest(method, r.base+path, nil)
+	if err != nil {
+		return err
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return dead_letter.ErrEntryNotFound
+	case res.StatusCode >= 300:
+		msg, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("%s %s: %s %s", method, path, res.Status, msg)
+	case result == nil:
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (r *remoteQueue) List() ([]*dead_letter.Entry, error) {
+	entries := []*dead_letter.Entry{}
+	err := r.do(http.MethodGet, "", &entries)
+	return entries, err
+}
+
+func (r *remoteQueue) Get(id string) (*dead_letter.Entry, error) {
+	var entry dead_letter.Entry
+	err := r.do(http.MethodGet, "/"+id, &entry)
+	return &entry, err
+}
+
+func (r *remoteQueue) MarkReplay(id string) error {
+	return r.do(http.MethodPost, "/"+id+"/replay", nil)
+}
+
+func (r *remoteQueue) Drop(id string) error {
+	return r.do(http.MethodDelete, "/"+id, nil)
+}
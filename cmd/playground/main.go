@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,6 +14,7 @@ import (
 	"github.com/pdcgo/materialize/backfill_pipeline"
 	"github.com/pdcgo/materialize/backfill_pipeline/backfill"
 	"github.com/pdcgo/materialize/coders"
+	"github.com/pdcgo/materialize/dead_letter"
 	"github.com/pdcgo/materialize/selling_metric"
 	"github.com/pdcgo/materialize/selling_pipeline"
 	"github.com/pdcgo/materialize/stat_process/exact_one"
@@ -47,6 +49,8 @@ func main() {
 		fmt.Println("❌ Interrupt received. Shutting down...")
 		cancel()
 	}()
+	go serveDebug(ctx, debugAddr())
+
 	err = coders.WarehouseCoder(ctx)
 	if err != nil {
 		panic(err)
@@ -54,27 +58,58 @@ func main() {
 
 	cdataChan := make(chan *stat_replica.CdcMessage, 9)
 	repcfg := &stat_replica.ReplicationConfig{
-		SlotName:        "stat_slot",
+		SlotName:        slotName("stat_slot"),
 		PublicationName: "stat_publication",
-		SlotTemporary:   true,
+		// slot permanen, temporary slot hilang saat koneksi putus dan checkpoint tidak bisa dilanjutkan
+		SlotTemporary: false,
 	}
 
 	backfilCfg := &backfill.BackfillConfig{
 		StartTime: time.Now().AddDate(0, 0, -5),
 	}
 
+	badgedb, err := stat_db.NewBadgeDB("./streamdata/replication")
+	if err != nil {
+		panic(err)
+	}
+	defer badgedb.Close()
+
+	err = restoreSnapshot(ctx, badgedb, repcfg.SlotName)
+	if err != nil {
+		panic(err)
+	}
+	http.Handle("/debug/snapshot", handleSnapshot(badgedb, repcfg.SlotName))
+	go scheduleSnapshot(ctx, badgedb, repcfg.SlotName)
+
+	deadLetter := dead_letter.NewBadgeQueue(ctx, badgedb)
+	ctx = dead_letter.ContextWithQueue(ctx, deadLetter)
+	dead_letter.RegisterHandler(http.DefaultServeMux, deadLetter)
+
+	checkpoint := stat_replica.NewSinkCheckpoint(stat_replica.NewBadgeCheckpoint(badgedb, repcfg.SlotName))
 	source := NewCDCStream(ctx,
 		backfilCfg,
 		repcfg,
+		checkpoint,
 		cdataChan,
 	)
 
 	go func() {
 		defer close(cdataChan)
+		count, err := deadLetter.ReplayPending(func(cdata *stat_replica.CdcMessage) error {
+			cdataChan <- cdata
+			return nil
+		})
+		if err != nil {
+			slog.Error(err.Error(), slog.String("process", "replay dead letter"))
+		}
+		if count > 0 {
+			slog.Info("dead letter replayed", slog.Int("count", count))
+		}
+
 		err = source.
 			Init().
 			Backfill().
-			// Stream().
+			Stream().
 			Err()
 
 		if err != nil {
@@ -83,19 +118,25 @@ func main() {
 
 	}()
 
-	badgedb, err := stat_db.NewBadgeDB("./streamdata/replication")
-	if err != nil {
-		panic(err)
-	}
-	defer badgedb.Close()
-	defer badgedb.DropAll()
-
 	conn, err := backfill.ConnectProdDatabase(ctx)
 	if err != nil {
 		panic(err)
 	}
 
+	retention := exact_one.NewRetention(
+		&exact_one.RetentionRule{Prefix: "public/orders/", TTL: time.Hour * 24 * 180},
+		&exact_one.RetentionRule{Prefix: "cache/inv_order/", TTL: time.Hour * 24 * 180},
+		// DiffAccount
+		&exact_one.RetentionRule{Prefix: "exact/", TTL: time.Hour * 24 * 60},
+		&exact_one.RetentionRule{Prefix: exact_one.AppliedPrefix, TTL: time.Hour * 24 * 7},
+		&exact_one.RetentionRule{Prefix: exact_one.DeltaAppliedPrefix, TTL: time.Hour * 24 * 7},
+	)
+	applyRetention(badgedb, retention)
+
 	exact := exact_one.NewBadgeExactOne(ctx, badgedb)
+	exact.SetRetention(retention)
+	go stat_db.RunValueLogGC(ctx, badgedb, time.Minute*10, 0.5)
+	go exact_one.RunPruneApplied(ctx, exact, checkpoint.Confirmed, time.Minute*10)
 
 	// inisiasi metric
 	// gather := metric.NewDefaultGather()
@@ -110,8 +151,21 @@ func main() {
 		exact,
 	)
 	shopDailyMetric := selling_metric.NewDailyShopMetric(badgedb, exact)
+	// hari final setelah watermark lewat 2 hari, kolom final di daily shop untuk sink
+	err = shopDailyMetric.SetWatermark(metric.NewWatermark(metric.DayWindow, time.Hour*48))
+	if err != nil {
+		panic(err)
+	}
 	teamDailyMetric := selling_metric.NewDailyTeamMetric(badgedb, exact)
 	bankBalanceMetric := selling_metric.NewDailyBankBalance(badgedb)
+	shopMonthMetric := selling_metric.NewShopRollupMetric(badgedb, metric.MonthWindow)
+	shopWeekMetric := selling_metric.NewShopRollupMetric(badgedb, metric.WeekWindow)
+	shopeeBalanceMetric.SetRetention(retention)
+	shopDailyMetric.SetRetention(retention)
+	teamDailyMetric.SetRetention(retention)
+	bankBalanceMetric.SetRetention(retention)
+	shopMonthMetric.SetRetention(retention)
+	shopWeekMetric.SetRetention(retention)
 
 	// running untuk sync ke postgres
 	// go func() {
@@ -133,11 +187,10 @@ func main() {
 	// 	}
 	// }()
 
-	err = yenstream.
-		NewRunnerContext(ctx).
-		CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
-			// source := createLogStream(ctx, yenstream.NewChannelSource(ctx, cdataChan))
-			source := yenstream.NewChannelSource(ctx, cdataChan).Via("dummy", yenstream.NewMap(ctx, func(d any) (any, error) {
+	err = runSegments(ctx, cdataChan, checkpoint,
+		func(ctx *yenstream.RunnerContext, segment yenstream.Pipeline) yenstream.Pipeline {
+			// source := createLogStream(ctx, segment)
+			source := segment.Via("dummy", yenstream.NewMap(ctx, func(d any) (any, error) {
 				return d, nil
 			}))
 
@@ -204,6 +257,31 @@ func main() {
 						return met, err
 					}))
 
+			saveRollup := func(met *selling_metric.DailyShopMetricData) (*selling_metric.DailyShopMetricData, error) {
+				err := pgGather.SaveItemTo(selling_metric.ShopRollupTable, met)
+				return met, err
+			}
+
+			shopMonthSink := selling_metric.NewMetricStream(
+				ctx,
+				time.Second*5,
+				shopMonthMetric,
+				selling_pipeline.NewShopRollupPipeline(ctx, metric.MonthWindow, shopMonthMetric).
+					All(shopDailyStream.CounterChanges()),
+			).
+				DataChanges(badgedb).
+				Via("saving", yenstream.NewMap(ctx, saveRollup))
+
+			shopWeekSink := selling_metric.NewMetricStream(
+				ctx,
+				time.Second*5,
+				shopWeekMetric,
+				selling_pipeline.NewShopRollupPipeline(ctx, metric.WeekWindow, shopWeekMetric).
+					All(shopDailyStream.CounterChanges()),
+			).
+				DataChanges(badgedb).
+				Via("saving", yenstream.NewMap(ctx, saveRollup))
+
 			spayBalance := selling_metric.
 				NewMetricStream(ctx, time.Second*5, shopeeBalanceMetric, dailyBalanceShopeepay.All(sourcePipe)).
 				DataChanges(badgedb).
@@ -239,6 +317,10 @@ func main() {
 					Via("silent", silent(ctx)),
 				shopDailySink.
 					Via("silent", silent(ctx)),
+				shopMonthSink.
+					Via("silent", silent(ctx)),
+				shopWeekSink.
+					Via("silent", silent(ctx)),
 			).
 				Via("log", yenstream.NewMap(ctx, func(data any) (any, error) {
 					raw, err := json.Marshal(data)
@@ -247,8 +329,7 @@ func main() {
 					return data, err
 				}))
 
-		}).
-		Err()
+		})
 
 	if err != nil {
 		slog.Error("processor stopped", slog.String("err", err.Error()))
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"log/slog"
+	"os"
 	"time"
 
 	"github.com/pdcgo/materialize/backfill_pipeline/backfill"
+	"github.com/pdcgo/materialize/dead_letter"
 	"github.com/pdcgo/materialize/selling_metric"
 	"github.com/pdcgo/materialize/stat_replica"
 )
@@ -28,11 +30,14 @@ type CDCStream interface {
 }
 
 type cdcStreamImpl struct {
-	status    StreamStatus
-	repcfg    *stat_replica.ReplicationConfig
-	cfg       *backfill.BackfillConfig
-	ctx       context.Context
-	cdataChan chan *stat_replica.CdcMessage
+	status     StreamStatus
+	repcfg     *stat_replica.ReplicationConfig
+	cfg        *backfill.BackfillConfig
+	ctx        context.Context
+	cdataChan  chan *stat_replica.CdcMessage
+	checkpoint *stat_replica.SinkCheckpoint
+	// checkpoint sudah ada, lanjut dari slot tanpa backfill
+	resume    bool
 	err       error
 	closefunc []func()
 }
@@ -58,6 +63,13 @@ func (c *cdcStreamImpl) Close() {
 
 // Backfill implements CDCStream.
 func (c *cdcStreamImpl) Backfill() CDCStream {
+	if c.err != nil {
+		return c
+	}
+	if c.resume {
+		slog.Info("checkpoint found, skip backfill")
+		return c
+	}
 	slog.Info("starting backfilling process")
 
 	var err error
@@ -149,36 +161,80 @@ func (c *cdcStreamImpl) setErr(err error) *cdcStreamImpl {
 	return c
 }
 
-// Init implements CDCStream.
+// Init implements CDCStream. Slot dibuat sebelum backfill supaya perubahan selama backfill
+// tertahan di slot dan ikut di stream setelahnya.
 func (c *cdcStreamImpl) Init() CDCStream {
-	return c
-}
-
-// Stream implements CDCStream.
-func (c *cdcStreamImpl) Stream() CDCStream {
+	lsn, err := c.checkpoint.LastLSN()
+	if err != nil {
+		return c.setErr(err)
+	}
+	c.resume = lsn != 0
+
+	// slot hanya boleh dipakai satu consumer, checkpoint consumer lain tidak ikut maju
+	err = stat_replica.CheckSlotOwner(c.ctx,
+		stat_replica.PgSlotQuery(stat_replica.ConnectProdQueryDatabase),
+		c.repcfg.SlotName,
+		lsn,
+	)
+	if err != nil {
+		return c.setErr(err)
+	}
 
-	slog.Info("starting replication streaming")
-	var err error
-	c.status = ReplicaMode
 	conn, err := stat_replica.ConnectProdDatabase(c.ctx)
 	if err != nil {
 		return c.setErr(err)
 	}
-
 	defer conn.Close(c.ctx)
 
-	// Initialize Replication
-	initrep := stat_replica.NewInitReplica(c.ctx, conn, c.repcfg)
-	err = initrep.
+	err = stat_replica.NewInitReplica(c.ctx, conn, c.repcfg).
 		Initialize(c.repcfg.SlotTemporary).
 		Err()
+	return c.setErr(err)
+}
 
-	if err != nil {
-		c.setErr(err)
+// Stream implements CDCStream.
+func (c *cdcStreamImpl) Stream() CDCStream {
+	if c.err != nil {
+		return c
 	}
 
-	replication := stat_replica.NewReplication(c.ctx, conn, c.repcfg)
-	replication.AddHandler(func(msg *stat_replica.CdcMessage) {
+	slog.Info("starting replication streaming")
+	var err error
+	c.status = ReplicaMode
+
+	monitor := stat_replica.NewSlotMonitor(c.ctx,
+		stat_replica.PgSlotQuery(stat_replica.ConnectProdQueryDatabase),
+		c.repcfg.SlotName,
+		&stat_replica.SlotThreshold{
+			RetainedBytes: 5 << 30,
+			ConsumerLag:   1 << 30,
+			Inactive:      time.Minute * 10,
+		},
+	)
+	monitor.OnAlert(func(alert *stat_replica.SlotAlert) {
+		slog.Error(alert.Reason, slog.String("slot", alert.Stats.SlotName))
+	})
+	go monitor.Start(time.Minute)
+
+	replica := stat_replica.NewReplica(c.ctx, stat_replica.ConnectProdDatabase, c.repcfg, c.checkpoint)
+	replica.OnConnected(func(attempt int) {
+		slog.Info("replication connected", slog.Int("attempt", attempt))
+	})
+	replica.OnLagging(func(lag uint64) {
+		slog.Warn("replication lagging", slog.Uint64("lag_bytes", lag))
+	})
+	replica.Configure(func(replication stat_replica.Replication) {
+		// row yang tidak bisa di decode masuk dead letter, stream tidak berhenti karena satu row
+		queue := dead_letter.GetQueue(c.ctx)
+		if queue != nil {
+			replication.SetErrorPolicy(stat_replica.ErrorPolicyDeadLetter, queue)
+		}
+		// enum dan domain dari TYPE message di resolve ke pg_type
+		replication.SetTypeLookup(stat_replica.PgTypeLookup(stat_replica.ConnectProdQueryDatabase))
+		replication.AddLagHandler(monitor.Observe)
+	})
+
+	err = replica.Start(func(msg *stat_replica.CdcMessage) {
 		if msg == nil {
 			return
 		}
@@ -188,10 +244,10 @@ func (c *cdcStreamImpl) Stream() CDCStream {
 			return
 		}
 
+		// dikonfirmasi setelah sink selesai, lihat runSegments
+		c.checkpoint.Track(msg)
 		c.cdataChan <- msg
 	})
-
-	err = replication.Start()
 	if err != nil {
 		return c.setErr(err)
 	}
@@ -199,11 +255,27 @@ func (c *cdcStreamImpl) Stream() CDCStream {
 	return c
 }
 
-func NewCDCStream(ctx context.Context, cfg *backfill.BackfillConfig, repcfg *stat_replica.ReplicationConfig, cdataChan chan *stat_replica.CdcMessage) CDCStream {
+func NewCDCStream(
+	ctx context.Context,
+	cfg *backfill.BackfillConfig,
+	repcfg *stat_replica.ReplicationConfig,
+	checkpoint *stat_replica.SinkCheckpoint,
+	cdataChan chan *stat_replica.CdcMessage,
+) CDCStream {
 	return &cdcStreamImpl{
-		repcfg:    repcfg,
-		ctx:       ctx,
-		cfg:       cfg,
-		cdataChan: cdataChan,
+		repcfg:     repcfg,
+		ctx:        ctx,
+		cfg:        cfg,
+		checkpoint: checkpoint,
+		cdataChan:  cdataChan,
+	}
+}
+
+// slotName returns env REPLICATION_SLOT or def, tiap binary punya slot sendiri.
+func slotName(def string) string {
+	name := os.Getenv("REPLICATION_SLOT")
+	if name == "" {
+		name = def
 	}
+	return name
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pdcgo/materialize/stat_process/snapshot"
+	"github.com/pdcgo/materialize/stat_process/stat_db"
+	"github.com/pdcgo/materialize/stat_replica"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: snapshot create|list|verify|restore [flags]")
+	fmt.Fprintln(os.Stderr, "  create  [-addr host:port] | [-db dir] [-dir snapshots] [-slot name]")
+	fmt.Fprintln(os.Stderr, "  list    [-dir snapshots]")
+	fmt.Fprintln(os.Stderr, "  verify  [-dir snapshots] [-id id]")
+	fmt.Fprintln(os.Stderr, "  restore [-db dir] [-dir snapshots] [-id id] [-slot name] [-force]")
+	fmt.Fprintln(os.Stderr, "id kosong berarti snapshot terbaru. Badger dikunci oleh stream, create dengan -addr memanggil")
+	fmt.Fprintln(os.Stderr, "/debug/snapshot di stream yang jalan, -db dan restore hanya saat stream berhenti.")
+	fmt.Fprintln(os.Stderr, "Restore menulis checkpoint slot, stream lanjut dari lsn snapshot tanpa backfill. Slot harus")
+	fmt.Fprintln(os.Stderr, "permanen dan belum dikonfirmasi melewati lsn snapshot, -force melewati cek slot.")
+}
+
+type storeFlags struct {
+	db  *string
+	dir *string
+}
+
+func addStoreFlags(set *flag.FlagSet) *storeFlags {
+	return &storeFlags{
+		db:  set.String("db", "./streamdata/replication", "direktori badger"),
+		dir: set.String("dir", "./streamdata/snapshots", "direktori snapshot"),
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "create":
+		err = create(args)
+	case "list":
+		err = list(args)
+	case "verify":
+		err = verify(args)
+	case "restore":
+		err = restore(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func create(args []string) error {
+	set := flag.NewFlagSet("create", flag.ExitOnError)
+	sflags := addStoreFlags(set)
+	slot := set.String("slot", "stat_slot", "slot yang checkpointnya dicatat")
+	addr := set.String("addr", "", "debug addr stream yang jalan, ex 127.0.0.1:6060")
+	set.Parse(args)
+
+	if *addr != "" {
+		return createRemote(*addr)
+	}
+
+	db, err := stat_db.NewBadgeDB(*sflags.db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	manifest, err := snapshot.NewStore(*sflags.dir).Create(db, *slot)
+	if err != nil {
+		return err
+	}
+	log.Printf("snapshot %s lsn %s keys %d size %d\n", manifest.ID, manifest.LSN, manifest.Keys, manifest.Size)
+	return nil
+}
+
+// createRemote asks the running stream to create the snapshot, badger tidak bisa dibuka dua proses.
+func createRemote(addr string) error {
+	res, err := http.Post("http://"+addr+"/debug/snapshot", "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("create snapshot %s: %s", res.Status, msg)
+	}
+
+	var manifest snapshot.Manifest
+	err = json.NewDecoder(res.Body).Decode(&manifest)
+	if err != nil {
+		return err
+	}
+	log.Printf("snapshot %s lsn %s keys %d size %d\n", manifest.ID, manifest.LSN, manifest.Keys, manifest.Size)
+	return nil
+}
+
+func list(args []string) error {
+	set := flag.NewFlagSet("list", flag.ExitOnError)
+	sflags := addStoreFlags(set)
+	set.Parse(args)
+
+	manifests, err := snapshot.NewStore(*sflags.dir).List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSLOT\tLSN\tKEYS\tSIZE")
+	for _, manifest := range manifests {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", manifest.ID, manifest.Slot, manifest.LSN, manifest.Keys, manifest.Size)
+	}
+	return w.Flush()
+}
+
+func snapshotID(store *snapshot.Store, id string) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	manifest, err := store.Latest()
+	if err != nil {
+		return "", err
+	}
+	return manifest.ID, nil
+}
+
+func verify(args []string) error {
+	set := flag.NewFlagSet("verify", flag.ExitOnError)
+	sflags := addStoreFlags(set)
+	id := set.String("id", "", "id snapshot")
+	set.Parse(args)
+
+	store := snapshot.NewStore(*sflags.dir)
+	sid, err := snapshotID(store, *id)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := store.Verify(sid)
+	if err != nil {
+		return err
+	}
+	log.Printf("snapshot %s ok, %d keys\n", manifest.ID, manifest.Keys)
+	return nil
+}
+
+func restore(args []string) error {
+	set := flag.NewFlagSet("restore", flag.ExitOnError)
+	sflags := addStoreFlags(set)
+	id := set.String("id", "", "id snapshot")
+	slot := set.String("slot", "stat_slot", "slot yang checkpointnya ditulis")
+	force := set.Bool("force", false, "restore tanpa cek confirmed lsn slot")
+	set.Parse(args)
+
+	store := snapshot.NewStore(*sflags.dir)
+	sid, err := snapshotID(store, *id)
+	if err != nil {
+		return err
+	}
+
+	db, err := stat_db.NewBadgeDB(*sflags.db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var manifest *snapshot.Manifest
+	if *force {
+		manifest, err = store.Restore(sid, db)
+		if err == nil {
+			err = stat_replica.NewBadgeCheckpoint(db, *slot).Commit(manifest.LSN)
+		}
+	} else {
+		query := stat_replica.PgSlotQuery(stat_replica.ConnectProdQueryDatabase)
+		manifest, err = store.RestoreSlot(context.Background(), sid, db, *slot, query)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("restored %s, stream lanjut dari lsn %s slot %s\n", manifest.ID, manifest.LSN, manifest.Slot)
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pdcgo/materialize/coders"
+	"github.com/pdcgo/materialize/debug_pipeline"
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/yenstream"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: walreplay record|slice|replay [flags]")
+	fmt.Fprintln(os.Stderr, "  record -out capture.wal [-slot name] [-pub name]")
+	fmt.Fprintln(os.Stderr, "  slice  -in capture.wal -out slice.wal [-from time] [-to time] [-tables a,b]")
+	fmt.Fprintln(os.Stderr, "  replay -in capture.wal [-speed 1] [-from time] [-to time] [-tables a,b] [-log out.jsonl] [-pipeline [-db dir]]")
+	fmt.Fprintln(os.Stderr, "time format RFC3339, ex 2025-01-08T10:00:00+07:00")
+}
+
+type sliceFlags struct {
+	from   *string
+	to     *string
+	tables *string
+}
+
+func addSliceFlags(set *flag.FlagSet) *sliceFlags {
+	return &sliceFlags{
+		from:   set.String("from", "", "awal commit time"),
+		to:     set.String("to", "", "akhir commit time, tidak termasuk"),
+		tables: set.String("tables", "", "nama tabel atau schema.table, dipisah koma"),
+	}
+}
+
+func (s *sliceFlags) filter() (*stat_replica.WalFilter, error) {
+	var from, to time.Time
+	var err error
+	if *s.from != "" {
+		from, err = time.Parse(time.RFC3339, *s.from)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if *s.to != "" {
+		to, err = time.Parse(time.RFC3339, *s.to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tables := []string{}
+	for _, table := range strings.Split(*s.tables, ",") {
+		table = strings.TrimSpace(table)
+		if table != "" {
+			tables = append(tables, table)
+		}
+	}
+	return stat_replica.NewWalFilter(from, to, tables...), nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	ctx = stat_replica.ContextWithCoder(ctx)
+	err := coders.WarehouseCoder(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "record":
+		err = record(ctx, args)
+	case "slice":
+		err = slice(args)
+	case "replay":
+		err = replay(ctx, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// record captures the replication stream from now until interrupted, pakai temporary slot
+// supaya slot production tidak ikut maju. Publication harus sudah ada dan tidak diubah.
+func record(ctx context.Context, args []string) error {
+	set := flag.NewFlagSet("record", flag.ExitOnError)
+	out := set.String("out", "capture.wal", "file capture")
+	slot := set.String("slot", "stat_record_slot", "nama temporary slot")
+	pub := set.String("pub", "stat_publication", "nama publication")
+	set.Parse(args)
+
+	cfg := &stat_replica.ReplicationConfig{
+		SlotName:        *slot,
+		SlotTemporary:   true,
+		PublicationName: *pub,
+		// Initialize dari NewReplica hanya membuat slot
+		PublicationReadOnly: true,
+	}
+	replica := stat_replica.NewReplica(ctx, stat_replica.ConnectProdDatabase, cfg, stat_replica.NewMemoryCheckpoint())
+	replica.Configure(func(rep stat_replica.Replication) {
+		rep.LogFile(*out)
+	})
+
+	log.Printf("recording to %s, ctrl+c to stop\n", *out)
+	return replica.Start(nil)
+}
+
+func slice(args []string) error {
+	set := flag.NewFlagSet("slice", flag.ExitOnError)
+	in := set.String("in", "", "file capture")
+	out := set.String("out", "", "file hasil slice")
+	sflags := addSliceFlags(set)
+	set.Parse(args)
+
+	if *in == "" || *out == "" {
+		set.Usage()
+		os.Exit(2)
+	}
+
+	filter, err := sflags.filter()
+	if err != nil {
+		return err
+	}
+
+	writer, err := stat_replica.NewWalWriter(*out)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	kept := 0
+	err = stat_replica.ReadWalFrames(*in, func(frame *stat_replica.WalFrame) error {
+		total += 1
+		keep, err := filter.Keep(frame)
+		if err != nil || !keep {
+			return err
+		}
+		kept += 1
+		return writer.Write(frame)
+	})
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	log.Printf("kept %d of %d frames\n", kept, total)
+	return writer.Close()
+}
+
+func replay(ctx context.Context, args []string) error {
+	set := flag.NewFlagSet("replay", flag.ExitOnError)
+	in := set.String("in", "", "file capture")
+	speed := set.Float64("speed", 0, "1 sesuai waktu rekam, 10 sepuluh kali lebih cepat, 0 tanpa jeda")
+	logOut := set.String("log", "", "tulis message sebagai json lines, kosong ke stdout")
+	withPipeline := set.Bool("pipeline", false, "jalankan selling pipeline, yang ditulis perubahan metric")
+	dbDir := set.String("db", "", "badger untuk -pipeline, kosong berarti direktori sementara")
+	sflags := addSliceFlags(set)
+	set.Parse(args)
+
+	if *in == "" {
+		set.Usage()
+		os.Exit(2)
+	}
+
+	filter, err := sflags.filter()
+	if err != nil {
+		return err
+	}
+
+	rep := stat_replica.NewReplay(ctx, *in, filter)
+	rep.SetSpeed(*speed)
+
+	var build func(ctx *yenstream.RunnerContext, source yenstream.Pipeline) yenstream.Pipeline
+	if *withPipeline {
+		ctx = selling_metric.ContextWithMetricControl(ctx)
+		sellingPipe, closeDB, err := newSellingPipeline(ctx, *dbDir)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		build = sellingPipe.build
+	}
+
+	var source interface{ Err() error }
+	yenstream.
+		NewRunnerContext(ctx).
+		CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+			replaySource := debug_pipeline.NewCdcSource(ctx, rep)
+			source = replaySource
+
+			var out yenstream.Pipeline = replaySource
+			if build != nil {
+				out = build(ctx, replaySource)
+			}
+
+			if *logOut != "" {
+				return out.Via("log_file", debug_pipeline.NewLogFile(ctx, *logOut))
+			}
+			return out.Via("log", debug_pipeline.Log(ctx))
+		})
+
+	return source.Err()
+}
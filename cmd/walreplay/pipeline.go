@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/selling_pipeline"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/metric"
+	"github.com/pdcgo/materialize/stat_process/stat_db"
+	"github.com/pdcgo/shared/yenstream"
+)
+
+// sellingPipeline is the selling pipeline of cmd/playground tanpa sideload dan sink postgres,
+// order yang dibutuhkan stage harus ikut terekam di capture.
+type sellingPipeline struct {
+	badgedb     *badger.DB
+	exact       exact_one.ExactlyOnce
+	shopeepay   metric.MetricStore[*metric.DailyShopeepayBalance]
+	shopDaily   metric.MetricStore[*selling_metric.DailyShopMetricData]
+	teamDaily   metric.MetricStore[*selling_metric.DailyTeamMetricData]
+	bankBalance metric.MetricStore[*selling_metric.DailyBankBalance]
+	shopMonth   metric.MetricStore[*selling_metric.DailyShopMetricData]
+	shopWeek    metric.MetricStore[*selling_metric.DailyShopMetricData]
+}
+
+// newSellingPipeline opens badger at dir, dir kosong berarti direktori sementara yang dihapus saat close.
+func newSellingPipeline(ctx context.Context, dir string) (*sellingPipeline, func(), error) {
+	temporary := dir == ""
+	if temporary {
+		var err error
+		dir, err = os.MkdirTemp("", "walreplay-")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	badgedb, err := stat_db.NewBadgeDB(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("pipeline state in %s\n", dir)
+
+	closeDB := func() {
+		badgedb.Close()
+		if temporary {
+			os.RemoveAll(dir)
+		}
+	}
+
+	exact := exact_one.NewBadgeExactOne(ctx, badgedb)
+	pipe := &sellingPipeline{
+		badgedb:     badgedb,
+		exact:       exact,
+		shopeepay:   selling_metric.NewDailyShopeepayBalanceMetric(badgedb, exact),
+		shopDaily:   selling_metric.NewDailyShopMetric(badgedb, exact),
+		teamDaily:   selling_metric.NewDailyTeamMetric(badgedb, exact),
+		bankBalance: selling_metric.NewDailyBankBalance(badgedb),
+		shopMonth:   selling_metric.NewShopRollupMetric(badgedb, metric.MonthWindow),
+		shopWeek:    selling_metric.NewShopRollupMetric(badgedb, metric.WeekWindow),
+	}
+	return pipe, closeDB, nil
+}
+
+// build returns every metric data change of the replayed messages.
+func (p *sellingPipeline) build(ctx *yenstream.RunnerContext, source yenstream.Pipeline) yenstream.Pipeline {
+	flushInterval := time.Second
+
+	sourcePipe := selling_pipeline.ExactOne(ctx, p.exact, source)
+
+	shopDailyStream := selling_metric.NewMetricStream(
+		ctx,
+		flushInterval,
+		p.shopDaily,
+		selling_pipeline.NewShopDailyPipeline(ctx, p.badgedb, p.shopDaily, p.exact).
+			All(sourcePipe),
+	)
+
+	teamDailyStream := selling_metric.NewMetricStream(
+		ctx,
+		flushInterval,
+		p.teamDaily,
+		selling_pipeline.NewDailyTeamPipeline(ctx, p.teamDaily).
+			All(shopDailyStream.CounterChanges()),
+	)
+
+	shopMonthStream := selling_metric.NewMetricStream(
+		ctx,
+		flushInterval,
+		p.shopMonth,
+		selling_pipeline.NewShopRollupPipeline(ctx, metric.MonthWindow, p.shopMonth).
+			All(shopDailyStream.CounterChanges()),
+	)
+
+	shopWeekStream := selling_metric.NewMetricStream(
+		ctx,
+		flushInterval,
+		p.shopWeek,
+		selling_pipeline.NewShopRollupPipeline(ctx, metric.WeekWindow, p.shopWeek).
+			All(shopDailyStream.CounterChanges()),
+	)
+
+	shopeepayStream := selling_metric.NewMetricStream(
+		ctx,
+		flushInterval,
+		p.shopeepay,
+		selling_pipeline.NewDailyShopeepayPipeline(ctx, p.badgedb, p.shopeepay, p.exact).
+			All(sourcePipe),
+	)
+
+	bankBalanceStream := selling_metric.NewMetricStream(
+		ctx,
+		flushInterval,
+		p.bankBalance,
+		selling_pipeline.NewDailyBankPipeline(ctx, p.bankBalance).
+			All(teamDailyStream.CounterChanges()),
+	)
+
+	return yenstream.NewFlatten(ctx, "flatten",
+		shopDailyStream.DataChanges(p.badgedb),
+		teamDailyStream.DataChanges(p.badgedb),
+		shopMonthStream.DataChanges(p.badgedb),
+		shopWeekStream.DataChanges(p.badgedb),
+		shopeepayStream.DataChanges(p.badgedb),
+		bankBalanceStream.DataChanges(p.badgedb),
+	)
+}
@@ -7,55 +7,23 @@ import (
 	"github.com/pdcgo/materialize/stat_replica"
 )
 
+func public(table string) *stat_replica.SourceMetadata {
+	return &stat_replica.SourceMetadata{Table: table, Schema: "public"}
+}
+
 func WarehouseCoder(ctx context.Context) error {
-	return stat_replica.RegisterCoderSources(ctx,
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "teams", Schema: "public"},
-			Coder: &models.Team{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "inv_resolutions", Schema: "public"},
-			Coder: &models.InvResolution{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "expense_histories", Schema: "public"},
-			Coder: &models.ExpenseHistory{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "restock_costs", Schema: "public"},
-			Coder: &models.RestockCost{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "inv_transactions", Schema: "public"},
-			Coder: &models.InvTransaction{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "ads_expense_histories", Schema: "public"},
-			Coder: &models.AdsExpenseHistory{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "expense_accounts", Schema: "public"},
-			Coder: &models.ExpenseAccount{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "balance_account_histories", Schema: "public"},
-			Coder: &models.BalanceAccountHistory{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "orders", Schema: "public"},
-			Coder: &models.Order{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "marketplaces", Schema: "public"},
-			Coder: &models.Marketplace{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "order_adjustments", Schema: "public"},
-			Coder: &models.OrderAdjustment{},
-		},
-		&stat_replica.CoderReg{
-			Meta:  &stat_replica.SourceMetadata{Table: "order_timestamps", Schema: "public"},
-			Coder: &models.OrderTimestamp{},
-		},
+	return stat_replica.RegisterCoders(ctx,
+		stat_replica.CoderSource[models.Team](public("teams")),
+		stat_replica.CoderSource[models.InvResolution](public("inv_resolutions")),
+		stat_replica.CoderSource[models.ExpenseHistory](public("expense_histories")),
+		stat_replica.CoderSource[models.RestockCost](public("restock_costs")),
+		stat_replica.CoderSource[models.InvTransaction](public("inv_transactions")),
+		stat_replica.CoderSource[models.AdsExpenseHistory](public("ads_expense_histories")),
+		stat_replica.CoderSource[models.ExpenseAccount](public("expense_accounts")),
+		stat_replica.CoderSource[models.BalanceAccountHistory](public("balance_account_histories")),
+		stat_replica.CoderSource[models.Order](public("orders")),
+		stat_replica.CoderSource[models.Marketplace](public("marketplaces")),
+		stat_replica.CoderSource[models.OrderAdjustment](public("order_adjustments")),
+		stat_replica.CoderSource[models.OrderTimestamp](public("order_timestamps")),
 	)
 }
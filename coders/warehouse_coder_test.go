@@ -0,0 +1,21 @@
+package coders_test
+
+import (
+	"testing"
+
+	"github.com/pdcgo/materialize/coders"
+	"github.com/pdcgo/materialize/stat_process/models"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarehouseCoder(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := coders.WarehouseCoder(ctx)
+	assert.Nil(t, err)
+
+	code, err := stat_replica.GetCoder(ctx, "public/orders/")
+	assert.Nil(t, err)
+	_, ok := code.(*models.Order)
+	assert.True(t, ok)
+}
@@ -0,0 +1,49 @@
+package dead_letter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// HandlerPath is where RegisterHandler serves the queue, cli deadletter -addr memakai path ini.
+const HandlerPath = "/debug/deadletter"
+
+// RegisterHandler serves list, inspect, replay and drop of queue on mux, badger dipegang service jadi
+// cli tidak bisa membuka db selama service jalan.
+func RegisterHandler(mux *http.ServeMux, queue Queue) {
+	mux.HandleFunc("GET "+HandlerPath, func(w http.ResponseWriter, r *http.Request) {
+		entries, err := queue.List()
+		writeResult(w, entries, err)
+	})
+	mux.HandleFunc("GET "+HandlerPath+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		entry, err := queue.Get(r.PathValue("id"))
+		writeResult(w, entry, err)
+	})
+	mux.HandleFunc("POST "+HandlerPath+"/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		err := queue.MarkReplay(r.PathValue("id"))
+		writeResult(w, nil, err)
+	})
+	mux.HandleFunc("DELETE "+HandlerPath+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := queue.Drop(r.PathValue("id"))
+		writeResult(w, nil, err)
+	})
+}
+
+func writeResult(w http.ResponseWriter, result any, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrEntryNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,64 @@
+package dead_letter_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdcgo/materialize/dead_letter"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueHandler(t *testing.T) {
+	var bdb db_mock.BadgeDBMock
+	moretest.Suite(t, "testing dead letter handler",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&bdb),
+		},
+		func(t *testing.T) {
+			ctx := stat_replica.ContextWithCoder(t.Context())
+			queue := dead_letter.NewBadgeQueue(ctx, bdb.DB)
+			err := queue.Push("stage_a", itemMessage(1), errors.New("gagal"))
+			assert.Nil(t, err)
+
+			mux := http.NewServeMux()
+			dead_letter.RegisterHandler(mux, queue)
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			call := func(method, path string) *http.Response {
+				req, err := http.NewRequest(method, server.URL+dead_letter.HandlerPath+path, nil)
+				assert.Nil(t, err)
+				res, err := http.DefaultClient.Do(req)
+				assert.Nil(t, err)
+				return res
+			}
+
+			res := call(http.MethodGet, "")
+			entries := []*dead_letter.Entry{}
+			assert.Nil(t, json.NewDecoder(res.Body).Decode(&entries))
+			res.Body.Close()
+			assert.Len(t, entries, 1)
+
+			res = call(http.MethodPost, "/"+entries[0].ID+"/replay")
+			res.Body.Close()
+			assert.Equal(t, http.StatusNoContent, res.StatusCode)
+			entry, err := queue.Get(entries[0].ID)
+			assert.Nil(t, err)
+			assert.True(t, entry.Replay)
+
+			res = call(http.MethodDelete, "/"+entries[0].ID)
+			res.Body.Close()
+			assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+			res = call(http.MethodGet, "/"+entries[0].ID)
+			res.Body.Close()
+			assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		},
+	)
+}
@@ -0,0 +1,108 @@
+package dead_letter
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/yenstream"
+)
+
+type deadLetterNode[R any] struct {
+	ctx     *yenstream.RunnerContext
+	label   string
+	in      chan any
+	out     yenstream.NodeOut
+	queue   Queue
+	handler func(cdata *stat_replica.CdcMessage) ([]R, error)
+}
+
+// In implements yenstream.Pipeline.
+func (d *deadLetterNode[R]) In() chan any {
+	return d.in
+}
+
+// Out implements yenstream.Pipeline.
+func (d *deadLetterNode[R]) Out() yenstream.NodeOut {
+	return d.out
+}
+
+// Process implements yenstream.Pipeline.
+func (d *deadLetterNode[R]) Process() {
+	out := d.out.C()
+	defer close(out)
+
+	for data := range d.in {
+		cdata, ok := data.(*stat_replica.CdcMessage)
+		if !ok {
+			slog.Error("dead letter stage only accept cdc message", slog.String("label", d.label), slog.String("type", fmt.Sprintf("%T", data)))
+			continue
+		}
+
+		results, err := d.handler(cdata)
+		if err != nil {
+			slog.Error(err.Error(), slog.String("label", d.label), slog.String("table", cdata.SourceMetadata.Table))
+			if d.queue == nil {
+				continue
+			}
+
+			err = d.queue.Push(d.label, cdata, err)
+			if err != nil {
+				slog.Error("cannot push dead letter", slog.String("label", d.label), slog.String("err", err.Error()))
+			}
+			continue
+		}
+
+		for _, result := range results {
+			out <- result
+		}
+	}
+}
+
+// SetLabel implements yenstream.Pipeline.
+func (d *deadLetterNode[R]) SetLabel(label string) {
+	d.label = label
+}
+
+// Via implements yenstream.Pipeline.
+func (d *deadLetterNode[R]) Via(label string, pipe yenstream.Pipeline) yenstream.Pipeline {
+	d.ctx.RegisterStream(label, d, pipe)
+	return pipe
+}
+
+func newDeadLetterNode[R any](ctx *yenstream.RunnerContext, handler func(cdata *stat_replica.CdcMessage) ([]R, error)) *deadLetterNode[R] {
+	return &deadLetterNode[R]{
+		ctx:     ctx,
+		in:      make(chan any, 1),
+		out:     yenstream.NewNodeOut(ctx),
+		queue:   GetQueue(ctx),
+		handler: handler,
+	}
+}
+
+// NewMap works like yenstream.NewMap, a failing message goes to the dead letter queue in ctx instead of stopping the runner.
+func NewMap[R any](ctx *yenstream.RunnerContext, handler func(cdata *stat_replica.CdcMessage) (R, error)) yenstream.Pipeline {
+	return newDeadLetterNode(ctx, func(cdata *stat_replica.CdcMessage) ([]R, error) {
+		result, err := handler(cdata)
+		if err != nil {
+			return nil, err
+		}
+		return []R{result}, nil
+	})
+}
+
+// NewFlatMap works like yenstream.NewFlatMap with dead letter on error.
+func NewFlatMap[R any](ctx *yenstream.RunnerContext, handler func(cdata *stat_replica.CdcMessage) ([]R, error)) yenstream.Pipeline {
+	return newDeadLetterNode(ctx, handler)
+}
+
+// NewFilter works like yenstream.NewFilter with dead letter on error.
+func NewFilter(ctx *yenstream.RunnerContext, handler func(cdata *stat_replica.CdcMessage) (bool, error)) yenstream.Pipeline {
+	return newDeadLetterNode(ctx, func(cdata *stat_replica.CdcMessage) ([]*stat_replica.CdcMessage, error) {
+		ok, err := handler(cdata)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return []*stat_replica.CdcMessage{cdata}, nil
+	})
+}
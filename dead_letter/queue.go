@@ -0,0 +1,285 @@
+package dead_letter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/pdcgo/materialize/stat_replica"
+)
+
+const PREFIX_KEY = "deadletter/"
+
+var ErrEntryNotFound = errors.New("dead letter entry not found")
+
+type Entry struct {
+	ID        string                   `json:"id"`
+	Label     string                   `json:"label"`
+	Err       string                   `json:"err"`
+	Replay    bool                     `json:"replay"`
+	Timestamp int64                    `json:"timestamp"`
+	Message   *stat_replica.CdcMessage `json:"message"`
+	WALData   []byte                   `json:"wal_data,omitempty"`
+	// DecodeErr is set when data no longer fits the coder of its table, data tetap map mentah
+	// dan entry tidak ikut replay sampai coder diperbaiki.
+	DecodeErr string `json:"decode_err,omitempty"`
+}
+
+type Queue interface {
+	stat_replica.DeadLetterSink
+	Push(label string, cdata *stat_replica.CdcMessage, err error) error
+	List() ([]*Entry, error)
+	Get(id string) (*Entry, error)
+	Drop(id string) error
+	// MarkReplay flags an entry to be fed back into the pipeline by ReplayPending.
+	MarkReplay(id string) error
+	ReplayPending(handle func(cdata *stat_replica.CdcMessage) error) (int, error)
+}
+
+type badgeQueue struct {
+	ctx     context.Context
+	db      *badger.DB
+	counter atomic.Uint32
+}
+
+// Send implements stat_replica.DeadLetterSink.
+func (q *badgeQueue) Send(row *stat_replica.DeadLetterRow) error {
+	entry := Entry{
+		Label:     "stat_replica_parser",
+		Err:       row.Err,
+		Timestamp: row.Timestamp,
+		WALData:   row.WALData,
+		Message: &stat_replica.CdcMessage{
+			SourceMetadata: row.SourceMetadata,
+			ModType:        row.ModType,
+			Data:           row.Data,
+			Timestamp:      row.Timestamp,
+			Xid:            row.Xid,
+			CommitLSN:      row.CommitLSN,
+		},
+	}
+
+	return q.save(&entry)
+}
+
+// Push implements Queue.
+func (q *badgeQueue) Push(label string, cdata *stat_replica.CdcMessage, err error) error {
+	entry := Entry{
+		Label:     label,
+		Err:       err.Error(),
+		Timestamp: time.Now().UnixMicro(),
+		Message:   cdata,
+	}
+
+	return q.save(&entry)
+}
+
+func (q *badgeQueue) save(entry *Entry) error {
+	if entry.ID == "" {
+		// urut sesuai waktu masuk, counter untuk entry di nanosecond yang sama
+		entry.ID = fmt.Sprintf("%019d%05d", time.Now().UnixNano(), q.counter.Add(1)%100000)
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return q.db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(PREFIX_KEY+entry.ID), raw)
+	})
+}
+
+// List implements Queue.
+func (q *badgeQueue) List() ([]*Entry, error) {
+	result := []*Entry{}
+
+	err := q.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(PREFIX_KEY)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			raw, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			entry, err := q.decode(raw)
+			if err != nil {
+				return err
+			}
+			result = append(result, entry)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// Get implements Queue.
+func (q *badgeQueue) Get(id string) (*Entry, error) {
+	var entry *Entry
+
+	err := q.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(PREFIX_KEY + id))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return ErrEntryNotFound
+			}
+			return err
+		}
+
+		raw, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		entry, err = q.decode(raw)
+		return err
+	})
+
+	return entry, err
+}
+
+// Drop implements Queue.
+func (q *badgeQueue) Drop(id string) error {
+	_, err := q.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return q.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete([]byte(PREFIX_KEY + id))
+	})
+}
+
+// MarkReplay implements Queue.
+func (q *badgeQueue) MarkReplay(id string) error {
+	entry, err := q.Get(id)
+	if err != nil {
+		return err
+	}
+
+	entry.Replay = true
+	return q.save(entry)
+}
+
+// ReplayPending implements Queue.
+func (q *badgeQueue) ReplayPending(handle func(cdata *stat_replica.CdcMessage) error) (int, error) {
+	var count int
+
+	entries, err := q.List()
+	if err != nil {
+		return count, err
+	}
+
+	for _, entry := range entries {
+		if !entry.Replay || entry.Message == nil {
+			continue
+		}
+		if entry.DecodeErr != "" {
+			slog.Warn("dead letter not replayed", slog.String("id", entry.ID), slog.String("err", entry.DecodeErr))
+			continue
+		}
+
+		err = handle(entry.Message)
+		if err != nil {
+			return count, err
+		}
+
+		err = q.Drop(entry.ID)
+		if err != nil {
+			return count, err
+		}
+		count += 1
+	}
+
+	return count, nil
+}
+
+// decode restores message data into the registered coder of its table, data yang tidak cocok dengan
+// coder dibiarkan map mentah dan entry ditandai DecodeErr supaya list dan inspect tetap jalan.
+func (q *badgeQueue) decode(raw []byte) (*Entry, error) {
+	var entry Entry
+	err := json.Unmarshal(raw, &entry)
+	if err != nil {
+		return nil, err
+	}
+	entry.DecodeErr = ""
+
+	cdata := entry.Message
+	if cdata == nil || cdata.SourceMetadata == nil || cdata.Data == nil {
+		return &entry, nil
+	}
+
+	coder, err := stat_replica.GetCoder(q.ctx, cdata.SourceMetadata.PrefixKey())
+	if err != nil {
+		if errors.Is(err, stat_replica.ErrCoderNotFound) || errors.Is(err, stat_replica.ErrCoderMapperNotFound) {
+			return &entry, nil
+		}
+		return nil, err
+	}
+
+	data, err := decodeData(cdata.Data, coder)
+	if err != nil {
+		entry.DecodeErr = err.Error()
+		return &entry, nil
+	}
+
+	var old any
+	if cdata.OldData != nil {
+		old, err = decodeData(cdata.OldData, stat_replica.NewEmptyFromStruct(coder))
+		if err != nil {
+			entry.DecodeErr = err.Error()
+			return &entry, nil
+		}
+	}
+
+	cdata.Data = data
+	if old != nil {
+		cdata.OldData = old
+	}
+	return &entry, nil
+}
+
+func decodeData(data any, coder any) (any, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(raw, coder)
+	if err != nil {
+		return nil, fmt.Errorf("decode %T: %w", coder, err)
+	}
+	return coder, nil
+}
+
+func NewBadgeQueue(ctx context.Context, db *badger.DB) Queue {
+	return &badgeQueue{
+		ctx: ctx,
+		db:  db,
+	}
+}
+
+var queueKey = "dead_letter_queue"
+
+func ContextWithQueue(pctx context.Context, queue Queue) context.Context {
+	return context.WithValue(pctx, queueKey, queue)
+}
+
+// GetQueue returns nil when no queue is set, dead letter stages then only log the failing message.
+func GetQueue(ctx context.Context) Queue {
+	data := ctx.Value(queueKey)
+	if data == nil {
+		slog.Warn("dead letter queue not found in context")
+		return nil
+	}
+	return data.(Queue)
+}
@@ -0,0 +1,172 @@
+package dead_letter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pdcgo/materialize/dead_letter"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/yenstream"
+	"github.com/stretchr/testify/assert"
+)
+
+type Item struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func itemMessage(id int64) *stat_replica.CdcMessage {
+	return &stat_replica.CdcMessage{
+		SourceMetadata: &stat_replica.SourceMetadata{
+			Table:  "items",
+			Schema: "public",
+		},
+		ModType: stat_replica.CdcInsert,
+		Data: &Item{
+			ID:   id,
+			Name: "item",
+		},
+	}
+}
+
+func TestBadgeQueue(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := stat_replica.RegisterCoderSource(ctx, &stat_replica.SourceMetadata{
+		Table:  "items",
+		Schema: "public",
+	}, &Item{})
+	assert.Nil(t, err)
+
+	var bdb db_mock.BadgeDBMock
+	moretest.Suite(t, "testing dead letter queue",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&bdb),
+		},
+		func(t *testing.T) {
+			queue := dead_letter.NewBadgeQueue(ctx, bdb.DB)
+
+			err := queue.Push("stage_a", itemMessage(1), errors.New("gagal satu"))
+			assert.Nil(t, err)
+			err = queue.Push("stage_b", itemMessage(2), errors.New("gagal dua"))
+			assert.Nil(t, err)
+
+			entries, err := queue.List()
+			assert.Nil(t, err)
+			assert.Len(t, entries, 2)
+			assert.Equal(t, "stage_a", entries[0].Label)
+			assert.Equal(t, "gagal dua", entries[1].Err)
+
+			t.Run("testing get restore coder", func(t *testing.T) {
+				entry, err := queue.Get(entries[0].ID)
+				assert.Nil(t, err)
+				item, ok := entry.Message.Data.(*Item)
+				assert.True(t, ok)
+				assert.Equal(t, int64(1), item.ID)
+			})
+
+			t.Run("testing replay hanya yang ditandai", func(t *testing.T) {
+				err := queue.MarkReplay(entries[1].ID)
+				assert.Nil(t, err)
+
+				replayed := []*stat_replica.CdcMessage{}
+				count, err := queue.ReplayPending(func(cdata *stat_replica.CdcMessage) error {
+					replayed = append(replayed, cdata)
+					return nil
+				})
+				assert.Nil(t, err)
+				assert.Equal(t, 1, count)
+				assert.Equal(t, int64(2), replayed[0].Data.(*Item).ID)
+
+				_, err = queue.Get(entries[1].ID)
+				assert.ErrorIs(t, err, dead_letter.ErrEntryNotFound)
+			})
+
+			t.Run("testing drop", func(t *testing.T) {
+				err := queue.Drop(entries[0].ID)
+				assert.Nil(t, err)
+
+				err = queue.Drop(entries[0].ID)
+				assert.ErrorIs(t, err, dead_letter.ErrEntryNotFound)
+
+				entries, err := queue.List()
+				assert.Nil(t, err)
+				assert.Empty(t, entries)
+			})
+
+			t.Run("testing data tidak cocok coder tetap terbaca", func(t *testing.T) {
+				cdata := itemMessage(3)
+				cdata.Data = map[string]interface{}{"id": "bukan angka"}
+				err := queue.Push("stage_c", cdata, errors.New("gagal tiga"))
+				assert.Nil(t, err)
+
+				entries, err := queue.List()
+				assert.Nil(t, err)
+				assert.Len(t, entries, 1)
+				assert.NotEmpty(t, entries[0].DecodeErr)
+				assert.Equal(t, "bukan angka", entries[0].Message.Data.(map[string]interface{})["id"])
+
+				err = queue.MarkReplay(entries[0].ID)
+				assert.Nil(t, err)
+				count, err := queue.ReplayPending(func(cdata *stat_replica.CdcMessage) error {
+					return nil
+				})
+				assert.Nil(t, err)
+				assert.Zero(t, count)
+
+				entry, err := queue.Get(entries[0].ID)
+				assert.Nil(t, err)
+				assert.True(t, entry.Replay)
+			})
+		},
+	)
+}
+
+func TestDeadLetterStage(t *testing.T) {
+	var bdb db_mock.BadgeDBMock
+	moretest.Suite(t, "testing dead letter stage",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&bdb),
+		},
+		func(t *testing.T) {
+			ctx := stat_replica.ContextWithCoder(t.Context())
+			queue := dead_letter.NewBadgeQueue(ctx, bdb.DB)
+			ctx = dead_letter.ContextWithQueue(ctx, queue)
+
+			cdchan := make(chan *stat_replica.CdcMessage, 3)
+			cdchan <- itemMessage(1)
+			cdchan <- itemMessage(2)
+			cdchan <- itemMessage(3)
+			close(cdchan)
+
+			ids := []int64{}
+			err := yenstream.
+				NewRunnerContext(ctx).
+				CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+					return yenstream.
+						NewChannelSource(ctx, cdchan).
+						Via("item_id", dead_letter.NewMap(ctx, func(cdata *stat_replica.CdcMessage) (int64, error) {
+							item := cdata.Data.(*Item)
+							if item.ID == 2 {
+								return 0, errors.New("item rusak")
+							}
+							return item.ID, nil
+						})).
+						Via("collect", yenstream.NewMap(ctx, func(id int64) (int64, error) {
+							ids = append(ids, id)
+							return id, nil
+						}))
+				}).
+				Err()
+			assert.Nil(t, err)
+			assert.Equal(t, []int64{1, 3}, ids)
+
+			entries, err := queue.List()
+			assert.Nil(t, err)
+			assert.Len(t, entries, 1)
+			assert.Equal(t, "item_id", entries[0].Label)
+			assert.Equal(t, "item rusak", entries[0].Err)
+		},
+	)
+}
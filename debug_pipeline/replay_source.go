@@ -0,0 +1,68 @@
+package debug_pipeline
+
+import (
+	"log/slog"
+
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/yenstream"
+)
+
+type cdcSourceImpl struct {
+	source stat_replica.Source
+	ctx    *yenstream.RunnerContext
+	label  string
+	out    yenstream.NodeOut
+	in     chan any
+	err    error
+}
+
+// In implements yenstream.Pipeline.
+func (r *cdcSourceImpl) In() chan any {
+	return r.in
+}
+
+// Out implements yenstream.Pipeline.
+func (r *cdcSourceImpl) Out() yenstream.NodeOut {
+	return r.out
+}
+
+// Process implements yenstream.Pipeline.
+func (r *cdcSourceImpl) Process() {
+	out := r.out.C()
+	defer close(out)
+
+	r.err = r.source.Start(func(msg *stat_replica.CdcMessage) {
+		out <- msg
+	})
+	if r.err != nil {
+		slog.Error(r.err.Error(), slog.String("label", r.label))
+	}
+}
+
+// SetLabel implements yenstream.Pipeline.
+func (r *cdcSourceImpl) SetLabel(label string) {
+	r.label = label
+}
+
+// Via implements yenstream.Pipeline.
+func (r *cdcSourceImpl) Via(label string, pipe yenstream.Pipeline) yenstream.Pipeline {
+	r.ctx.RegisterStream(label, r, pipe)
+	return pipe
+}
+
+// Err returns the source error after the pipeline finished.
+func (r *cdcSourceImpl) Err() error {
+	return r.err
+}
+
+var _ yenstream.Source = (*cdcSourceImpl)(nil)
+
+// NewCdcSource emits every *stat_replica.CdcMessage of source, ex Replay, DebeziumSource atau Replica, closed when the source ends.
+func NewCdcSource(ctx *yenstream.RunnerContext, source stat_replica.Source) *cdcSourceImpl {
+	return &cdcSourceImpl{
+		source: source,
+		ctx:    ctx,
+		out:    yenstream.NewNodeOut(ctx),
+		in:     make(chan any, 1),
+	}
+}
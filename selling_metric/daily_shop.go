@@ -9,30 +9,51 @@ import (
 	"github.com/pdcgo/materialize/stat_process/metric"
 )
 
+// dailyShopAgg merges every field bertag agg, field angka baru wajib punya tag.
+var dailyShopAgg = metric.MustAgg[DailyShopMetricData]("metric/daily_shop", "Day", "TeamID", "ShopID")
+
 type DailyShopMetricData struct {
-	Day               string  `json:"day" gorm:"primaryKey"`
+	// Day berisi label window untuk rollup, ex 2025-08 untuk bulanan
+	Day string `json:"day" gorm:"primaryKey"`
+	// Window kosong untuk metric harian
+	Window            string  `json:"window,omitempty" gorm:"-"`
 	ShopID            uint    `json:"shop_id" gorm:"primaryKey"`
 	TeamID            uint    `json:"team_id"`
-	AdsSpentAmount    float64 `json:"ads_spent_amount"`
-	CancelOrderAmount float64 `json:"cancel_order_amount"`
+	AdsSpentAmount    float64 `json:"ads_spent_amount" agg:"sum"`
+	CancelOrderAmount float64 `json:"cancel_order_amount" agg:"sum"`
+
+	ReturnCreatedAmount float64 `json:"return_created_amount" agg:"sum"`
+	ReturnArrivedAmount float64 `json:"return_arrived_amount" agg:"sum"`
 
-	ReturnCreatedAmount float64 `json:"return_created_amount"`
-	ReturnArrivedAmount float64 `json:"return_arrived_amount"`
+	ProblemOrderAmount float64 `json:"problem_order_amount" agg:"sum"`
+	LostOrderAmount    float64 `json:"lost_order_amount" agg:"sum"`
 
-	ProblemOrderAmount float64 `json:"problem_order_amount"`
-	LostOrderAmount    float64 `json:"lost_order_amount"`
+	CreatedOrderAmount    float64 `json:"created_order_amount" agg:"sum"`
+	SysCreatedOrderAmount float64 `json:"sys_created_order_amount" agg:"sum"`
 
-	CreatedOrderAmount    float64 `json:"created_order_amount"`
-	SysCreatedOrderAmount float64 `json:"sys_created_order_amount"`
+	EstWithdrawalAmount float64 `json:"est_withdrawal_amount" agg:"sum"`
+	WithdrawalAmount    float64 `json:"withdrawal_amount" agg:"sum"`
+	MpAdjustmentAmount  float64 `json:"mp_adjustment_amount" agg:"sum"`
+	AdjOrderAmount      float64 `json:"adj_order_amount" agg:"sum"`
 
-	EstWithdrawalAmount float64 `json:"est_withdrawal_amount"`
-	WithdrawalAmount    float64 `json:"withdrawal_amount"`
-	MpAdjustmentAmount  float64 `json:"mp_adjustment_amount"`
-	AdjOrderAmount      float64 `json:"adj_order_amount"`
+	WarehouseFeeAmount float64 `json:"warehouse_fee_amount" agg:"sum"`
 
-	WarehouseFeeAmount float64 `json:"warehouse_fee_amount"`
+	// Final true kalau hari sudah lewat watermark, koreksi setelahnya tetap dikirim ulang
+	Final bool `json:"final" gorm:"not null;default:false"`
 
 	Freshness time.Time `json:"freshness"`
+
+	metric.DeltaOrigin `json:"-" gorm:"-"`
+}
+
+// SetFinal implements metric.FinalData.
+func (d *DailyShopMetricData) SetFinal(final bool) {
+	d.Final = final
+}
+
+// Negate implements metric.RetractData.
+func (d *DailyShopMetricData) Negate() *DailyShopMetricData {
+	return dailyShopAgg.Negate(d)
 }
 
 // SetFreshness implements gathering.CanFressness.
@@ -41,35 +62,37 @@ func (d *DailyShopMetricData) SetFreshness(n time.Time) {
 }
 
 // Merge implements metric.MetricData.
-func (d *DailyShopMetricData) Merge(dold interface{}) metric.MetricData {
-	if dold == nil {
+func (d *DailyShopMetricData) Merge(data interface{}) metric.MetricData {
+	if data == nil {
 		return d
 	}
-	old := dold.(*DailyShopMetricData)
-
-	d.AdsSpentAmount += old.AdsSpentAmount
-	d.CancelOrderAmount += old.CancelOrderAmount
-
-	d.ReturnCreatedAmount += old.ReturnCreatedAmount
-	d.ReturnArrivedAmount += old.ReturnArrivedAmount
-
-	d.ProblemOrderAmount += old.ProblemOrderAmount
-	d.LostOrderAmount += old.LostOrderAmount
-
-	d.CreatedOrderAmount += old.CreatedOrderAmount
-	d.SysCreatedOrderAmount += old.SysCreatedOrderAmount
-	d.EstWithdrawalAmount += old.EstWithdrawalAmount
-	d.WithdrawalAmount += old.WithdrawalAmount
-	d.MpAdjustmentAmount += old.MpAdjustmentAmount
-	d.AdjOrderAmount += old.AdjOrderAmount
-	d.WarehouseFeeAmount += old.WarehouseFeeAmount
-	return d
+	return dailyShopAgg.Merge(d, data.(*DailyShopMetricData))
 }
 
 // var _ gathering.CanFressness = (*DailyShopMetricData)(nil)
 
 func (d *DailyShopMetricData) Key() string {
-	return fmt.Sprintf("metric/daily_shop/%s/%d/%d", d.Day, d.TeamID, d.ShopID)
+	if d.Window != "" {
+		return dailyShopAgg.KeyWith(fmt.Sprintf("metric/%s_shop", d.Window), d)
+	}
+	return dailyShopAgg.Key(d)
+}
+
+// WindowTime implements metric.WindowData, waktu delta hanya sampai hari.
+func (d *DailyShopMetricData) WindowTime() time.Time {
+	day, err := metric.ParseDay(d.Day)
+	if err != nil {
+		return time.Time{}
+	}
+	return day
+}
+
+// InWindow implements metric.WindowData.
+func (d *DailyShopMetricData) InWindow(window metric.Window, label string) *DailyShopMetricData {
+	item := *d
+	item.Day = label
+	item.Window = window.Name()
+	return &item
 }
 
 func NewDailyShopMetric(
@@ -85,3 +108,19 @@ func NewDailyShopMetric(
 
 	return met
 }
+
+// ShopRollupTable is the postgres table of the shop rollups, label di kolom day berbeda format per window.
+const ShopRollupTable = "shop_rollup_metrics"
+
+// NewShopRollupMetric stores the shop metric summed per window, ex bulanan untuk month to date.
+func NewShopRollupMetric(
+	badgedb *badger.DB,
+	window metric.Window,
+) metric.MetricStore[*DailyShopMetricData] {
+	return metric.NewWindowStore(badgedb, window, func() *DailyShopMetricData {
+		return &DailyShopMetricData{}
+	}, func(data *DailyShopMetricData) *DailyShopMetricData {
+		data.AdjOrderAmount = data.EstWithdrawalAmount - data.WithdrawalAmount
+		return data
+	})
+}
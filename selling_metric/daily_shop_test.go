@@ -6,6 +6,7 @@ import (
 	"github.com/pdcgo/materialize/selling_metric"
 	"github.com/pdcgo/materialize/stat_process/db_mock"
 	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/metric"
 	"github.com/pdcgo/shared/pkg/moretest"
 	"github.com/stretchr/testify/assert"
 )
@@ -74,3 +75,65 @@ func TestMatric(t *testing.T) {
 	)
 
 }
+
+func TestShopRollup(t *testing.T) {
+	var bdb db_mock.BadgeDBMock
+
+	moretest.Suite(t, "test shop rollup bulanan",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&bdb),
+		},
+		func(t *testing.T) {
+			month := selling_metric.NewShopRollupMetric(bdb.DB, metric.MonthWindow)
+			daily := selling_metric.NewDailyShopMetric(bdb.DB, nil)
+			assert.NotEqual(t, daily.Name(), month.Name())
+
+			for _, day := range []string{"2025-08-01", "2025-08-15", "2025-08-31", "2025-09-01"} {
+				err := metric.MergeWindow(month, metric.MonthWindow, &selling_metric.DailyShopMetricData{
+					Day:                day,
+					ShopID:             2,
+					TeamID:             1,
+					CreatedOrderAmount: 1000,
+				})
+				assert.Nil(t, err)
+			}
+
+			result := map[string]float64{}
+			err := month.FlushCallback(func(acc any) error {
+				data := acc.(*selling_metric.DailyShopMetricData)
+				assert.Equal(t, "month", data.Window)
+				result[data.Day] = data.CreatedOrderAmount
+				return nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]float64{"2025-08": 3000, "2025-09": 1000}, result)
+
+			err = metric.MergeWindow(month, metric.MonthWindow, &selling_metric.DailyShopMetricData{ShopID: 2})
+			assert.ErrorIs(t, err, metric.ErrInvalidWindowTime)
+		},
+	)
+}
+
+// TestMetricDefinitions fails when a new numeric field has no agg tag.
+func TestMetricDefinitions(t *testing.T) {
+	_, err := metric.NewAgg[selling_metric.DailyShopMetricData]("metric/daily_shop", "Day", "TeamID", "ShopID")
+	assert.Nil(t, err)
+	_, err = metric.NewAgg[selling_metric.DailyTeamMetricData]("metric/daily_team", "Day", "TeamID")
+	assert.Nil(t, err)
+	_, err = metric.NewAgg[selling_metric.DailyBankBalance]("metric/daily_bank", "Day", "TeamID")
+	assert.Nil(t, err)
+
+	shop := &selling_metric.DailyShopMetricData{
+		Day:                "2025-08-01",
+		ShopID:             2,
+		TeamID:             1,
+		AdsSpentAmount:     100,
+		WarehouseFeeAmount: 5000,
+	}
+	assert.Equal(t, "metric/daily_shop/2025-08-01/1/2", shop.Key())
+
+	team := selling_metric.ShopToTeam(shop)
+	assert.Equal(t, "metric/daily_team/2025-08-01/1", team.Key())
+	assert.Equal(t, 100.0, team.AdsSpentAmount)
+	assert.Equal(t, 5000.0, team.WarehouseFeeAmount)
+}
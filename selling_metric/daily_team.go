@@ -1,7 +1,6 @@
 package selling_metric
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
@@ -17,29 +16,36 @@ type DailyTeamCost struct {
 	CrossProductAmount float64
 }
 
+var (
+	dailyTeamAgg = metric.MustAgg[DailyTeamMetricData]("metric/daily_team", "Day", "TeamID")
+	shopToTeam   = metric.MustProjection[DailyShopMetricData](dailyTeamAgg)
+)
+
 type DailyTeamMetricData struct {
 	Day               string  `json:"day" gorm:"primaryKey"`
 	TeamID            uint    `json:"team_id" gorm:"primaryKey"`
-	AdsSpentAmount    float64 `json:"ads_spent_amount"`
-	CancelOrderAmount float64 `json:"cancel_order_amount"`
+	AdsSpentAmount    float64 `json:"ads_spent_amount" agg:"sum"`
+	CancelOrderAmount float64 `json:"cancel_order_amount" agg:"sum"`
 
-	ReturnCreatedAmount float64 `json:"return_created_amount"`
-	ReturnArrivedAmount float64 `json:"return_arrived_amount"`
+	ReturnCreatedAmount float64 `json:"return_created_amount" agg:"sum"`
+	ReturnArrivedAmount float64 `json:"return_arrived_amount" agg:"sum"`
 
-	ProblemOrderAmount float64 `json:"problem_order_amount"`
-	LostOrderAmount    float64 `json:"lost_order_amount"`
+	ProblemOrderAmount float64 `json:"problem_order_amount" agg:"sum"`
+	LostOrderAmount    float64 `json:"lost_order_amount" agg:"sum"`
 
-	CreatedOrderAmount    float64 `json:"created_order_amount"`
-	SysCreatedOrderAmount float64 `json:"sys_created_order_amount"`
+	CreatedOrderAmount    float64 `json:"created_order_amount" agg:"sum"`
+	SysCreatedOrderAmount float64 `json:"sys_created_order_amount" agg:"sum"`
 
-	EstWithdrawalAmount float64 `json:"est_withdrawal_amount"`
-	WithdrawalAmount    float64 `json:"withdrawal_amount"`
-	MpAdjustmentAmount  float64 `json:"mp_adjustment_amount"`
-	AdjOrderAmount      float64 `json:"adj_order_amount"`
+	EstWithdrawalAmount float64 `json:"est_withdrawal_amount" agg:"sum"`
+	WithdrawalAmount    float64 `json:"withdrawal_amount" agg:"sum"`
+	MpAdjustmentAmount  float64 `json:"mp_adjustment_amount" agg:"sum"`
+	AdjOrderAmount      float64 `json:"adj_order_amount" agg:"sum"`
 
-	WarehouseFeeAmount float64 `json:"warehouse_fee_amount"`
+	WarehouseFeeAmount float64 `json:"warehouse_fee_amount" agg:"sum"`
 
 	Freshness time.Time `json:"freshness"`
+
+	metric.DeltaOrigin `json:"-" gorm:"-"`
 }
 
 // SetFreshness implements gathering.CanFressness.
@@ -48,35 +54,22 @@ func (d *DailyTeamMetricData) SetFreshness(n time.Time) {
 }
 
 // Merge implements metric.MetricData.
-func (d *DailyTeamMetricData) Merge(dold interface{}) metric.MetricData {
-	if dold == nil {
+func (d *DailyTeamMetricData) Merge(data interface{}) metric.MetricData {
+	if data == nil {
 		return d
 	}
-	old := dold.(*DailyTeamMetricData)
-
-	d.AdsSpentAmount += old.AdsSpentAmount
-	d.CancelOrderAmount += old.CancelOrderAmount
-
-	d.ReturnCreatedAmount += old.ReturnCreatedAmount
-	d.ReturnArrivedAmount += old.ReturnArrivedAmount
-
-	d.ProblemOrderAmount += old.ProblemOrderAmount
-	d.LostOrderAmount += old.LostOrderAmount
-
-	d.CreatedOrderAmount += old.CreatedOrderAmount
-	d.SysCreatedOrderAmount += old.SysCreatedOrderAmount
-	d.EstWithdrawalAmount += old.EstWithdrawalAmount
-	d.WithdrawalAmount += old.WithdrawalAmount
-	d.MpAdjustmentAmount += old.MpAdjustmentAmount
-	d.AdjOrderAmount += old.AdjOrderAmount
-	d.WarehouseFeeAmount += old.WarehouseFeeAmount
-	return d
+	return dailyTeamAgg.Merge(d, data.(*DailyTeamMetricData))
 }
 
 // var _ gathering.CanFressness = (*DailyShopMetricData)(nil)
 
 func (d *DailyTeamMetricData) Key() string {
-	return fmt.Sprintf("metric/daily_team/%s/%d", d.Day, d.TeamID)
+	return dailyTeamAgg.Key(d)
+}
+
+// ShopToTeam projects a daily shop delta to its team, semua field team yang punya rule ikut tersalin.
+func ShopToTeam(shop *DailyShopMetricData) *DailyTeamMetricData {
+	return shopToTeam.Apply(shop)
 }
 
 func NewDailyTeamMetric(
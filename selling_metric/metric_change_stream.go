@@ -1,6 +1,7 @@
 package selling_metric
 
 import (
+	"log/slog"
 	"time"
 
 	"github.com/pdcgo/materialize/stat_process/metric"
@@ -56,6 +57,11 @@ Parent:
 func (m *metricChangeStreamImpl[R]) flushData(out chan any) {
 	m.met.Change(func(acc R) {
 		out <- acc
+		// delta sudah diteruskan, consumer yang menyimpan
+		err := m.met.Ack(acc)
+		if err != nil {
+			slog.Error(err.Error(), slog.String("metric", m.met.Name()))
+		}
 	})
 }
 
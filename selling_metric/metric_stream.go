@@ -2,8 +2,6 @@ package selling_metric
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"log/slog"
 	"time"
 
@@ -33,57 +31,15 @@ func (m *metricStreamImpl[R]) DataChanges(db *badger.DB) yenstream.Pipeline {
 	return m.
 		pipe.
 		Via("data_change_metric", yenstream.NewMap(m.ctx, func(data metric.MetricData) (metric.MetricData, error) {
-			var old R
-			var err error
-			old, err = m.getItem(db, data.Key())
-			if err != nil {
-				if !errors.Is(err, badger.ErrKeyNotFound) {
-					return data, err
-				}
-			}
-			newd := data.Merge(old)
-			err = m.setItem(db, newd.Key(), newd.(R))
+			newd, err := m.metric.Commit(db, data.(R))
 			if err != nil {
 				return data, err
 			}
-			data = m.metric.Output(newd.(R))
+			data = m.metric.Output(newd)
 			return data, nil
 		}))
 }
 
-func (m *metricStreamImpl[R]) getItem(db *badger.DB, key string) (R, error) {
-	acc := m.metric.EmptyAccumulator()
-
-	err := db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(key))
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		return json.Unmarshal(val, acc)
-	})
-
-	return acc, err
-}
-
-func (m *metricStreamImpl[R]) setItem(db *badger.DB, key string, data R) error {
-	var err error
-	err = db.Update(func(txn *badger.Txn) error {
-		var raw []byte
-		raw, err = json.Marshal(data)
-		if err != nil {
-			return err
-		}
-		err = txn.Set([]byte(key), raw)
-		return err
-	})
-
-	return err
-}
-
 func NewMetricStream[R metric.MetricData](
 	ctx *yenstream.RunnerContext,
 	flushtime time.Duration,
@@ -188,6 +144,10 @@ func (mc *MetricControl) SetFreshness(n time.Duration) {
 	mc.freshness = n
 }
 
+func (mc *MetricControl) Freshness() time.Duration {
+	return mc.freshness
+}
+
 func ContextWithMetricControl(pctx context.Context) context.Context {
 	return context.WithValue(pctx, metricControlKey, &MetricControl{
 		freshness: time.Second * 5,
@@ -27,7 +27,7 @@ func (dbk *DailyBankPipeline) All(
 					WithdrawalAmount: data.WithdrawalAmount,
 				}
 				item := md
-				err := dbk.metric.Merge(md.Key(), func(acc *selling_metric.DailyBankBalance) *selling_metric.DailyBankBalance {
+				err := dbk.metric.MergeFrom(data.Origin(), md.Key(), func(acc *selling_metric.DailyBankBalance) *selling_metric.DailyBankBalance {
 					if acc == nil {
 						return &item
 					}
@@ -0,0 +1,61 @@
+package selling_pipeline_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdcgo/materialize/coders"
+	"github.com/pdcgo/materialize/debug_pipeline"
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/selling_pipeline"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/yenstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebeziumSourceAds(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := coders.WarehouseCoder(ctx)
+	assert.Nil(t, err)
+
+	var bdb db_mock.BadgeDBMock
+
+	moretest.Suite(t, "test ads dari export debezium",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&bdb),
+		},
+		func(t *testing.T) {
+			exact := exact_one.NewBadgeExactOne(ctx, bdb.DB)
+			met := selling_metric.NewDailyShopMetric(bdb.DB, exact)
+			source := stat_replica.NewDebeziumFileSource(ctx, "../test_assets/debezium/ads_expense_histories.jsonl")
+
+			found := 0
+			yenstream.
+				NewRunnerContext(ctx).
+				CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+					cdc := selling_pipeline.
+						ExactOne(ctx, exact, debug_pipeline.NewCdcSource(ctx, source))
+
+					shop := selling_pipeline.
+						NewShopDailyPipeline(ctx, bdb.DB, met, exact).
+						All(cdc)
+
+					return selling_metric.
+						NewMetricStream(ctx, time.Second, met, shop).
+						DataChanges(bdb.DB).
+						Via("testing", yenstream.NewMap(ctx, func(data *selling_metric.DailyShopMetricData) (*selling_metric.DailyShopMetricData, error) {
+							if data.Day == "2025-01-08" {
+								found += 1
+								assert.Equal(t, 9000.00, data.AdsSpentAmount)
+							}
+							return data, nil
+						}))
+				})
+
+			assert.NotZero(t, found)
+		},
+	)
+}
@@ -1,7 +1,7 @@
 package selling_pipeline
 
 import (
-	"log"
+	"log/slog"
 
 	"github.com/pdcgo/materialize/stat_process/exact_one"
 	"github.com/pdcgo/materialize/stat_process/models"
@@ -9,57 +9,80 @@ import (
 	"github.com/pdcgo/shared/yenstream"
 )
 
+// exactOneEffect is the applied marker name of the ExactOne stage.
+const exactOneEffect = "exact_one"
+
+// ExactOne saves the state and records the source position in one transaction, pesan replay yang sudah tersimpan
+// tetap diteruskan dengan old data semula supaya metric bisa memutuskan sendiri.
 func ExactOne(ctx *yenstream.RunnerContext, exact exact_one.ExactlyOnce, source yenstream.Pipeline) yenstream.Pipeline {
 
 	exactstream := source.
 		Via("save_to_badge_db", yenstream.NewFilter(ctx, func(cdata *stat_replica.CdcMessage) (bool, error) {
+			if cdata.ModType == stat_replica.CdcTruncate {
+				return false, exact.Truncate(cdata.SourceMetadata)
+			}
 
 			data, ok := cdata.Data.(exact_one.ExactHaveKey)
 			if !ok {
 				return false, nil
 			}
-			var found bool
-			old := stat_replica.NewEmptyFromStruct(data).(exact_one.ExactHaveKey)
-			change := exact.Change(data)
-			err := change.
-				Before(&found, old).
-				Err()
-
-			if err != nil {
-				return false, err
-			}
+			var found, applied, keep bool
+			old := exact_one.Empty(data)
+			err := exact.Transaction(true, func(exact exact_one.ExactlyOnce) error {
+				var err error
+				// pesan replay, state sudah tersimpan jadi old diambil dari catatan sebelumnya
+				applied, found, err = exact.Applied(exactOneEffect, cdata, old)
+				if err != nil {
+					return err
+				}
 
-			switch cdata.ModType {
-			case stat_replica.CdcBackfill:
-				if found {
-					return false, nil
+				change := exact.Change(data)
+				if !applied {
+					err = change.
+						Before(&found, old).
+						Err()
+					if err != nil {
+						return err
+					}
 				}
 
-				err = change.
-					Save().
-					Err()
+				if cdata.Present != nil {
+					err = mergeUnchanged(cdata, found, old)
+					if err != nil {
+						return err
+					}
+				}
 
-				if err != nil {
-					return false, err
+				if cdata.ModType == stat_replica.CdcBackfill && found {
+					return nil
+				}
+				keep = true
+				if applied {
+					return nil
 				}
 
-			default:
 				err = change.
 					Save().
 					Err()
-
 				if err != nil {
-					return false, err
+					return err
+				}
+
+				var before interface{}
+				if found {
+					before = old
 				}
+				return exact.MarkApplied(exactOneEffect, cdata, before)
+			})
+			if err != nil || !keep {
+				return false, err
 			}
 
-			if found {
+			// old data dari source (replica identity full) lebih akurat dari cache
+			if found && cdata.OldData == nil {
 				cdata.OldData = old
 			}
-			if err != nil {
-				log.Println(cdata.SourceMetadata.PrefixKey())
-			}
-			return true, err
+			return true, nil
 		})).
 		Via("enrich_order", yenstream.NewMap(ctx, func(cdata *stat_replica.CdcMessage) (*stat_replica.CdcMessage, error) {
 			var err error
@@ -76,7 +99,7 @@ func ExactOne(ctx *yenstream.RunnerContext, exact exact_one.ExactlyOnce, source
 					MpTotal:      float64(data.OrderMpTotal),
 				}
 
-				err = exact.Change(inv).Save().Err()
+				err = exact_one.Put(exact, inv.Key(), inv)
 				if err != nil {
 					return cdata, err
 				}
@@ -91,7 +114,7 @@ func ExactOne(ctx *yenstream.RunnerContext, exact exact_one.ExactlyOnce, source
 						MpTotal:      float64(data.OrderMpTotal),
 					}
 
-					err = exact.Change(inv).Save().Err()
+					err = exact_one.Put(exact, inv.Key(), inv)
 					if err != nil {
 						return cdata, err
 					}
@@ -105,3 +128,19 @@ func ExactOne(ctx *yenstream.RunnerContext, exact exact_one.ExactlyOnce, source
 	return exactstream
 
 }
+
+// mergeUnchanged isi kolom unchanged toast dari old data source atau data terakhir di badger
+func mergeUnchanged(cdata *stat_replica.CdcMessage, found bool, old exact_one.ExactHaveKey) error {
+	var before interface{}
+	switch {
+	case cdata.OldData != nil:
+		before = cdata.OldData
+	case found:
+		before = old
+	default:
+		slog.Warn("unchanged toast without stored data", slog.String("table", cdata.SourceMetadata.Table))
+		return nil
+	}
+
+	return stat_replica.MergeUnchanged(cdata.Data, before, cdata.Present)
+}
@@ -0,0 +1,130 @@
+package selling_pipeline_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/selling_pipeline"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/models"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/yenstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExactOneReplay(t *testing.T) {
+	var bdb db_mock.BadgeDBMock
+
+	at := time.Date(2025, 1, 8, 10, 0, 0, 0, time.Local)
+	messages := func() chan *stat_replica.CdcMessage {
+		cdchan := make(chan *stat_replica.CdcMessage, 2)
+		cdchan <- &stat_replica.CdcMessage{
+			SourceMetadata: &stat_replica.SourceMetadata{
+				Table:  "ads_expense_histories",
+				Schema: "public",
+			},
+			ModType:   stat_replica.CdcInsert,
+			CommitLSN: 100,
+			Seq:       1,
+			Data: &models.AdsExpenseHistory{
+				ID:            1,
+				TeamID:        1,
+				MarketplaceID: 1,
+				Amount:        5000,
+				At:            at,
+				CreatedAt:     at,
+			},
+		}
+		cdchan <- &stat_replica.CdcMessage{
+			SourceMetadata: &stat_replica.SourceMetadata{
+				Table:  "ads_expense_histories",
+				Schema: "public",
+			},
+			ModType:   stat_replica.CdcUpdate,
+			CommitLSN: 200,
+			Seq:       1,
+			Data: &models.AdsExpenseHistory{
+				ID:            1,
+				TeamID:        1,
+				MarketplaceID: 1,
+				Amount:        9000,
+				At:            at,
+				CreatedAt:     at,
+			},
+		}
+		close(cdchan)
+		return cdchan
+	}
+
+	moretest.Suite(t, "test replay exact one",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&bdb),
+		},
+		func(t *testing.T) {
+			exact := exact_one.NewBadgeExactOne(t.Context(), bdb.DB)
+
+			runMetric := func() {
+				met := selling_metric.NewDailyShopMetric(bdb.DB, exact)
+				yenstream.
+					NewRunnerContext(t.Context()).
+					CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+						source := selling_pipeline.ExactOne(ctx, exact, yenstream.NewChannelSource(ctx, messages()))
+						shop := selling_pipeline.
+							NewShopDailyPipeline(ctx, bdb.DB, met, exact).
+							All(source)
+
+						return selling_metric.
+							NewMetricStream(ctx, time.Second, met, shop).
+							DataChanges(bdb.DB)
+					})
+			}
+
+			stored := func() float64 {
+				item := &selling_metric.DailyShopMetricData{
+					Day:    "2025-01-08",
+					TeamID: 1,
+					ShopID: 1,
+				}
+				found, err := exact.GetItemStruct(item)
+				assert.Nil(t, err)
+				assert.True(t, found)
+				return item.AdsSpentAmount
+			}
+
+			t.Run("crash setelah state tersimpan sebelum metric", func(t *testing.T) {
+				yenstream.
+					NewRunnerContext(t.Context()).
+					CreatePipeline(func(ctx *yenstream.RunnerContext) yenstream.Pipeline {
+						return selling_pipeline.ExactOne(ctx, exact, yenstream.NewChannelSource(ctx, messages()))
+					})
+
+				found, _, err := exact.Applied("exact_one", &stat_replica.CdcMessage{CommitLSN: 200, Seq: 1}, nil)
+				assert.Nil(t, err)
+				assert.True(t, found)
+			})
+
+			t.Run("replay memakai old data semula", func(t *testing.T) {
+				runMetric()
+				assert.Equal(t, 9000.00, stored())
+			})
+
+			t.Run("replay kedua tidak double count", func(t *testing.T) {
+				runMetric()
+				assert.Equal(t, 9000.00, stored())
+			})
+
+			t.Run("prune marker sampai lsn terkonfirmasi", func(t *testing.T) {
+				count, err := exact.PruneApplied(100)
+				assert.Nil(t, err)
+				assert.Equal(t, 2, count)
+
+				applied, _, err := exact.Applied("exact_one", &stat_replica.CdcMessage{CommitLSN: 200, Seq: 1}, nil)
+				assert.Nil(t, err)
+				assert.True(t, applied)
+			})
+		},
+	)
+}
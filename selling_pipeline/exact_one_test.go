@@ -64,6 +64,26 @@ func TestExactOne(t *testing.T) {
 			},
 		}
 
+		// name unchanged toast, tidak boleh terhapus
+		cdcChan <- &stat_replica.CdcMessage{
+			SourceMetadata: &stat_replica.SourceMetadata{
+				Table:  "teams",
+				Schema: "public",
+			},
+			ModType: stat_replica.CdcUpdate,
+			Data: &models.Team{
+				ID:       2,
+				Type:     "asdasd",
+				TeamCode: "updated",
+			},
+			Present: map[string]bool{
+				"id":        true,
+				"type":      true,
+				"name":      false,
+				"team_code": true,
+			},
+		}
+
 	}()
 
 	moretest.Suite(t, "testing exact_one",
@@ -105,6 +125,7 @@ func TestExactOne(t *testing.T) {
 				assert.True(t, found)
 
 				assert.Equal(t, "oldchange", team.Name)
+				assert.Equal(t, "updated", team.TeamCode)
 			})
 		},
 	)
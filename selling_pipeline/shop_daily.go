@@ -51,11 +51,7 @@ func (ds *DailyShopPipeline) Return(source yenstream.Pipeline) yenstream.Pipelin
 			data := cdata.Data.(*models.InvTransaction)
 			result := []*selling_metric.DailyShopMetricData{}
 
-			orddata := &models.InvOrderData{
-				InvID: data.ID,
-			}
-
-			found, err := ds.exact.GetItemStruct(orddata)
+			orddata, found, err := exact_one.Get[models.InvOrderData](ds.exact, (&models.InvOrderData{InvID: data.ID}).Key())
 			if err != nil {
 				return result, err
 			}
@@ -107,11 +103,7 @@ func (ds *DailyShopPipeline) Return(source yenstream.Pipeline) yenstream.Pipelin
 		Via("dshop_return_add_metric", yenstream.NewMap(ds.ctx, func(cdata *stat_replica.CdcMessage) (*stat_replica.CdcMessage, error) {
 			data := cdata.Data.(*models.InvTransaction)
 
-			orddata := &models.InvOrderData{
-				InvID: data.ID,
-			}
-
-			found, err := ds.exact.GetItemStruct(orddata)
+			orddata, found, err := exact_one.Get[models.InvOrderData](ds.exact, (&models.InvOrderData{InvID: data.ID}).Key())
 			if err != nil {
 				return cdata, err
 			}
@@ -127,7 +119,7 @@ func (ds *DailyShopPipeline) Return(source yenstream.Pipeline) yenstream.Pipelin
 				ReturnCreatedAmount: orddata.MpTotal,
 			}
 
-			err = ds.metric.Merge(item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+			err = ds.metric.MergeAt(cdata, item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 				if acc == nil {
 					return item
 				}
@@ -158,11 +150,7 @@ func (ds *DailyShopPipeline) Lost(source yenstream.Pipeline) yenstream.Pipeline
 			var err error
 			data := cdata.Data.(*models.OrderTimestamp)
 
-			ord := &models.Order{
-				ID: data.OrderID,
-			}
-
-			found, err := ds.exact.GetItemStruct(ord)
+			ord, found, err := exact_one.Get[models.Order](ds.exact, (&models.Order{ID: data.OrderID}).Key())
 			if err != nil {
 				return cdata, err
 			}
@@ -179,7 +167,7 @@ func (ds *DailyShopPipeline) Lost(source yenstream.Pipeline) yenstream.Pipeline
 			}
 			switch data.OrderStatus {
 			case db_models.OrdProblem:
-				ds.metric.Merge(item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+				ds.metric.MergeAt(cdata, item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 					if acc == nil {
 						return item
 					}
@@ -208,11 +196,7 @@ func (ds *DailyShopPipeline) Problem(source yenstream.Pipeline) yenstream.Pipeli
 			var err error
 			data := cdata.Data.(*models.OrderTimestamp)
 
-			ord := &models.Order{
-				ID: data.OrderID,
-			}
-
-			found, err := ds.exact.GetItemStruct(ord)
+			ord, found, err := exact_one.Get[models.Order](ds.exact, (&models.Order{ID: data.OrderID}).Key())
 			if err != nil {
 				return cdata, err
 			}
@@ -229,7 +213,7 @@ func (ds *DailyShopPipeline) Problem(source yenstream.Pipeline) yenstream.Pipeli
 			}
 			switch data.OrderStatus {
 			case db_models.OrdProblem:
-				ds.metric.Merge(item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+				ds.metric.MergeAt(cdata, item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 					if acc == nil {
 						return item
 					}
@@ -258,11 +242,7 @@ func (ds *DailyShopPipeline) Cancel(source yenstream.Pipeline) yenstream.Pipelin
 			var err error
 			data := cdata.Data.(*models.OrderTimestamp)
 
-			ord := &models.Order{
-				ID: data.OrderID,
-			}
-
-			found, err := ds.exact.GetItemStruct(ord)
+			ord, found, err := exact_one.Get[models.Order](ds.exact, (&models.Order{ID: data.OrderID}).Key())
 			if err != nil {
 				return cdata, err
 			}
@@ -279,7 +259,7 @@ func (ds *DailyShopPipeline) Cancel(source yenstream.Pipeline) yenstream.Pipelin
 			}
 			switch data.OrderStatus {
 			case db_models.OrdCancel:
-				ds.metric.Merge(item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+				ds.metric.MergeAt(cdata, item.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 					if acc == nil {
 						return item
 					}
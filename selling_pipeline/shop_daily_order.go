@@ -43,7 +43,7 @@ func (ds *DailyShopPipeline) Order(cdstream yenstream.Pipeline) yenstream.Pipeli
 					return cdata, nil
 				}
 
-				err = ds.metric.Merge(newitem.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+				err = ds.metric.MergeAt(cdata, newitem.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 					if acc == nil {
 						return newitem
 					}
@@ -55,7 +55,7 @@ func (ds *DailyShopPipeline) Order(cdstream yenstream.Pipeline) yenstream.Pipeli
 					return cdata, err
 				}
 
-				err = ds.metric.Merge(sysitem.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+				err = ds.metric.MergeAt(cdata, sysitem.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 					if acc == nil {
 						return sysitem
 					}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
 	"github.com/pdcgo/materialize/stat_process/models"
 	"github.com/pdcgo/materialize/stat_replica"
 	"github.com/pdcgo/shared/db_models"
@@ -28,18 +29,13 @@ func (ds *DailyShopPipeline) WarehouseFee(cdstream yenstream.Pipeline) yenstream
 
 				return true, nil
 			})).
-		Via("dshop_warehouse_flat", yenstream.NewFlatMap(ds.ctx,
+		Via("dshop_warehouse_flat", yenstream.NewFlatMap(ds.ctx, withSource(
 			func(cdata *stat_replica.CdcMessage) ([]*selling_metric.DailyShopMetricData, error) {
 				var err error
 				var result []*selling_metric.DailyShopMetricData
 
 				data := cdata.Data.(*models.InvTransaction)
-				invord := &models.InvOrderData{
-					InvID: data.ID,
-				}
-
-				var found bool
-				found, err = ds.exact.GetItemStructKey(invord.Key(), invord)
+				invord, found, err := exact_one.Get[models.InvOrderData](ds.exact, (&models.InvOrderData{InvID: data.ID}).Key())
 				if err != nil {
 					return result, err
 				}
@@ -105,16 +101,16 @@ func (ds *DailyShopPipeline) WarehouseFee(cdstream yenstream.Pipeline) yenstream
 				}
 
 				return result, nil
-			})).
+			}))).
 		Via("dshop_ware_merge", yenstream.NewMap(ds.ctx,
-			func(met *selling_metric.DailyShopMetricData) (*selling_metric.DailyShopMetricData, error) {
-				err := ds.metric.Merge(met.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
+			func(src *sourceItem[*selling_metric.DailyShopMetricData]) (*selling_metric.DailyShopMetricData, error) {
+				met := src.item
+				err := ds.metric.MergeAt(src.cdata, met.Key(), func(acc *selling_metric.DailyShopMetricData) *selling_metric.DailyShopMetricData {
 					if acc == nil {
 						return met
 					}
 
-					acc.Merge(met)
-					return acc
+					return acc.Merge(met).(*selling_metric.DailyShopMetricData)
 				})
 
 				return met, err
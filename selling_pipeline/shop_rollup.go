@@ -0,0 +1,34 @@
+package selling_pipeline
+
+import (
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/stat_process/metric"
+	"github.com/pdcgo/shared/yenstream"
+)
+
+// ShopRollupPipeline sums the daily shop deltas into one window, ex MonthWindow untuk p&l month to date.
+type ShopRollupPipeline struct {
+	ctx    *yenstream.RunnerContext
+	window metric.Window
+	metric metric.MetricStore[*selling_metric.DailyShopMetricData]
+}
+
+func (sr *ShopRollupPipeline) All(dailyShop yenstream.Pipeline) yenstream.Pipeline {
+	return dailyShop.
+		Via("rollup_shop_"+sr.window.Name(), yenstream.NewMap(sr.ctx, func(md *selling_metric.DailyShopMetricData) (*selling_metric.DailyShopMetricData, error) {
+			err := metric.MergeWindow(sr.metric, sr.window, md)
+			return md, err
+		}))
+}
+
+func NewShopRollupPipeline(
+	ctx *yenstream.RunnerContext,
+	window metric.Window,
+	metric metric.MetricStore[*selling_metric.DailyShopMetricData],
+) *ShopRollupPipeline {
+	return &ShopRollupPipeline{
+		ctx:    ctx,
+		window: window,
+		metric: metric,
+	}
+}
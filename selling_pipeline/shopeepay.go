@@ -1,10 +1,8 @@
 package selling_pipeline
 
 import (
-	"encoding/json"
-	"log"
-
 	"github.com/dgraph-io/badger/v3"
+	"github.com/pdcgo/materialize/dead_letter"
 	"github.com/pdcgo/materialize/stat_process/exact_one"
 	"github.com/pdcgo/materialize/stat_process/metric"
 	"github.com/pdcgo/materialize/stat_process/models"
@@ -50,7 +48,7 @@ func (ds *DailyShopeepayPipeline) All(source yenstream.Pipeline) yenstream.Pipel
 func (ds *DailyShopeepayPipeline) DiffAmount(source yenstream.Pipeline) yenstream.Pipeline {
 	difCalc := NewDiffAccountCalc(ds.badgedb, ds.exact)
 	diffamount := source.
-		Via("filter_diff_amount", yenstream.NewFlatMap(ds.ctx, func(cdata *stat_replica.CdcMessage) ([]*metric.DailyShopeepayBalance, error) {
+		Via("filter_diff_amount", dead_letter.NewFlatMap(ds.ctx, withSource(func(cdata *stat_replica.CdcMessage) ([]*metric.DailyShopeepayBalance, error) {
 			var err error
 			items := []*metric.DailyShopeepayBalance{}
 			if cdata.SourceMetadata.Table != "balance_account_histories" {
@@ -59,9 +57,7 @@ func (ds *DailyShopeepayPipeline) DiffAmount(source yenstream.Pipeline) yenstrea
 
 			diffs, err := difCalc.ProcessCDC(cdata)
 			if err != nil {
-				raw, _ := json.Marshal(cdata)
-				log.Println(string(raw))
-				panic(err)
+				return items, err
 			}
 
 			for _, diff := range diffs {
@@ -79,13 +75,13 @@ func (ds *DailyShopeepayPipeline) DiffAmount(source yenstream.Pipeline) yenstrea
 			}
 
 			return items, err
-		})).
+		}))).
 		// Via("filter_debug", yenstream.NewFilter(ctx, func(item *metric.DailyShopeepayBalance) (bool, error) {
 		// 	return item.TeamID == 31, nil
 		// })).
-		Via("diff_amount_merge_metric", yenstream.NewMap(ds.ctx, func(item *metric.DailyShopeepayBalance) (*metric.DailyShopeepayBalance, error) {
-
-			err := ds.metric.Merge(item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
+		Via("diff_amount_merge_metric", yenstream.NewMap(ds.ctx, func(src *sourceItem[*metric.DailyShopeepayBalance]) (*metric.DailyShopeepayBalance, error) {
+			item := src.item
+			err := ds.metric.MergeAt(src.cdata, item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
 				if acc == nil {
 					return item
 				}
@@ -140,7 +136,7 @@ func (ds *DailyShopeepayPipeline) Refund(source yenstream.Pipeline) yenstream.Pi
 				TeamID:       data.TeamID,
 				RefundAmount: data.RefundAmount,
 			}
-			err := ds.metric.Merge(item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
+			err := ds.metric.MergeAt(cdata, item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
 				if acc == nil {
 					return item
 				}
@@ -204,7 +200,7 @@ func (ds *DailyShopeepayPipeline) Topup(source yenstream.Pipeline) yenstream.Pip
 				TeamID:      data.TeamID,
 				TopupAmount: amount,
 			}
-			err = ds.metric.Merge(item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
+			err = ds.metric.MergeAt(cdata, item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
 				if acc == nil {
 					return item
 				}
@@ -258,7 +254,7 @@ func (ds *DailyShopeepayPipeline) Cost(source yenstream.Pipeline) yenstream.Pipe
 				TeamID:     inv.TeamID,
 				CostAmount: cost,
 			}
-			err = ds.metric.Merge(item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
+			err = ds.metric.MergeAt(cdata, item.Key(), func(acc *metric.DailyShopeepayBalance) *metric.DailyShopeepayBalance {
 				if acc == nil {
 					return item
 				}
@@ -0,0 +1,23 @@
+package selling_pipeline
+
+import "github.com/pdcgo/materialize/stat_replica"
+
+// sourceItem is an item of a flat map with the message it came from, supaya merge metric tetap tahu posisi sumbernya.
+type sourceItem[T any] struct {
+	cdata *stat_replica.CdcMessage
+	item  T
+}
+
+func withSource[T any](handler func(cdata *stat_replica.CdcMessage) ([]T, error)) func(cdata *stat_replica.CdcMessage) ([]*sourceItem[T], error) {
+	return func(cdata *stat_replica.CdcMessage) ([]*sourceItem[T], error) {
+		items, err := handler(cdata)
+		result := make([]*sourceItem[T], len(items))
+		for i, item := range items {
+			result[i] = &sourceItem[T]{
+				cdata: cdata,
+				item:  item,
+			}
+		}
+		return result, err
+	}
+}
@@ -14,33 +14,18 @@ type DailyTeamPipeline struct {
 func (dt *DailyTeamPipeline) All(dailyShop yenstream.Pipeline) yenstream.Pipeline {
 	dailyTeam := dailyShop.
 		Via("merge_to_daily_team", yenstream.NewMap(dt.ctx, func(md *selling_metric.DailyShopMetricData) (*selling_metric.DailyTeamMetricData, error) {
-			dd := selling_metric.DailyTeamMetricData{
-				Day:                   md.Day,
-				TeamID:                md.TeamID,
-				AdsSpentAmount:        md.AdsSpentAmount,
-				CancelOrderAmount:     md.CancelOrderAmount,
-				ReturnCreatedAmount:   md.ReturnCreatedAmount,
-				ReturnArrivedAmount:   md.ReturnArrivedAmount,
-				ProblemOrderAmount:    md.ProblemOrderAmount,
-				LostOrderAmount:       md.LostOrderAmount,
-				CreatedOrderAmount:    md.CreatedOrderAmount,
-				SysCreatedOrderAmount: md.SysCreatedOrderAmount,
-				EstWithdrawalAmount:   md.EstWithdrawalAmount,
-				WithdrawalAmount:      md.WithdrawalAmount,
-				MpAdjustmentAmount:    md.MpAdjustmentAmount,
-				AdjOrderAmount:        md.AdjOrderAmount,
-			}
+			dd := selling_metric.ShopToTeam(md)
 
-			item := dd
-			err := dt.metric.Merge(item.Key(), func(acc *selling_metric.DailyTeamMetricData) *selling_metric.DailyTeamMetricData {
+			item := *dd
+			// origin delta daily shop sama saat dikirim ulang setelah crash, merge yang sama di skip
+			err := dt.metric.MergeFrom(md.Origin(), item.Key(), func(acc *selling_metric.DailyTeamMetricData) *selling_metric.DailyTeamMetricData {
 				if acc == nil {
 					return &item
 				}
 
-				acc.Merge(&item)
-				return acc
+				return acc.Merge(&item).(*selling_metric.DailyTeamMetricData)
 			})
-			return &dd, err
+			return dd, err
 		}))
 		// Via("test", yenstream.NewMap(dt.ctx,
 		// 	func(met *selling_metric.DailyTeamMetricData) (*selling_metric.DailyTeamMetricData, error) {
@@ -0,0 +1,209 @@
+package exact_one
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_replica"
+)
+
+// AppliedPrefix is the prefix of every applied marker, diurutkan berdasarkan posisi supaya mudah di prune.
+const AppliedPrefix = "exact_one/applied/"
+
+type appliedRecord struct {
+	Found  bool            `json:"found"`
+	Before json.RawMessage `json:"before,omitempty"`
+}
+
+// DeltaAppliedPrefix is the prefix of markers of deltas derived from a metric, origin tidak punya lsn.
+const DeltaAppliedPrefix = "exact_one/applied_delta/"
+
+// AppliedKey returns the marker key of one effect of the message at pos, effect ex nama stage atau key metric.
+func AppliedKey(pos stat_replica.Position, effect string) []byte {
+	return []byte(AppliedPrefix + pos.String() + "/" + effect)
+}
+
+// IsApplied reports whether the effect of the message at pos was committed.
+func IsApplied(txn *badger.Txn, pos stat_replica.Position, effect string) (bool, error) {
+	return hasMarker(txn, AppliedKey(pos, effect))
+}
+
+func hasMarker(txn *badger.Txn, key []byte) (bool, error) {
+	_, err := txn.Get(key)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// SetApplied writes the marker in txn with the ttl of retention, harus satu transaksi dengan efeknya.
+func SetApplied(txn *badger.Txn, retention *Retention, pos stat_replica.Position, effect string, value []byte) error {
+	return retention.set(txn, AppliedKey(pos, effect), value)
+}
+
+// DeltaAppliedKey returns the marker key of one effect of the delta at origin, ex key metric turunan.
+func DeltaAppliedKey(origin string, effect string) []byte {
+	return []byte(DeltaAppliedPrefix + origin + "/" + effect)
+}
+
+// IsDeltaApplied reports whether the effect of the delta at origin was committed.
+func IsDeltaApplied(txn *badger.Txn, origin string, effect string) (bool, error) {
+	return hasMarker(txn, DeltaAppliedKey(origin, effect))
+}
+
+// SetDeltaApplied writes the marker of a delta in txn with the ttl of retention, harus satu transaksi dengan efeknya.
+func SetDeltaApplied(txn *badger.Txn, retention *Retention, origin string, effect string, value []byte) error {
+	return retention.set(txn, DeltaAppliedKey(origin, effect), value)
+}
+
+// Applied implements ExactlyOnce.
+func (e *exactOneImpl) Applied(effect string, cdata *stat_replica.CdcMessage, before interface{}) (bool, bool, error) {
+	pos, ok := cdata.Position()
+	if !ok {
+		return false, false, nil
+	}
+
+	var applied, found bool
+	err := e.getTx(false, func(exact *exactOneImpl) error {
+		item, err := exact.tx.Get(AppliedKey(pos, effect))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		applied = true
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		record := appliedRecord{}
+		err = json.Unmarshal(val, &record)
+		if err != nil {
+			return err
+		}
+		if !record.Found || before == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(record.Before, before)
+	})
+
+	return applied, found, err
+}
+
+// MarkApplied implements ExactlyOnce.
+func (e *exactOneImpl) MarkApplied(effect string, cdata *stat_replica.CdcMessage, before interface{}) error {
+	pos, ok := cdata.Position()
+	if !ok {
+		return nil
+	}
+
+	record := appliedRecord{}
+	if before != nil {
+		raw, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		record.Found = true
+		record.Before = raw
+	}
+	val, err := json.Marshal(&record)
+	if err != nil {
+		return err
+	}
+
+	return e.getTx(true, func(exact *exactOneImpl) error {
+		return e.retention.set(exact.tx, AppliedKey(pos, effect), val)
+	})
+}
+
+// PruneApplied implements ExactlyOnce.
+func (e *exactOneImpl) PruneApplied(until pglogrepl.LSN) (int, error) {
+	keys := [][]byte{}
+	err := e.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(AppliedPrefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			key := iter.Item().KeyCopy(nil)
+			lsn, err := appliedLSN(key)
+			if err != nil {
+				return err
+			}
+			if lsn > until {
+				break
+			}
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	batch := e.db.NewWriteBatch()
+	defer batch.Cancel()
+	for _, key := range keys {
+		err = batch.Delete(key)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), batch.Flush()
+}
+
+// RunPruneApplied prunes markers up to the lsn confirmed to the source every interval until ctx done.
+func RunPruneApplied(ctx context.Context, exact ExactlyOnce, confirmed func() pglogrepl.LSN, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lsn := confirmed()
+			if lsn == 0 {
+				continue
+			}
+			count, err := exact.PruneApplied(lsn)
+			if err != nil {
+				slog.Error(err.Error(), slog.String("process", "prune applied"))
+				continue
+			}
+			if count > 0 {
+				slog.Info("applied marker pruned", slog.Int("count", count), slog.String("until", lsn.String()))
+			}
+		}
+	}
+}
+
+func appliedLSN(key []byte) (pglogrepl.LSN, error) {
+	raw := strings.TrimPrefix(string(key), AppliedPrefix)
+	lsn, _, ok := strings.Cut(raw, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid applied key %s", key)
+	}
+
+	n, err := strconv.ParseUint(lsn, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid applied key %s: %w", key, err)
+	}
+	return pglogrepl.LSN(n), nil
+}
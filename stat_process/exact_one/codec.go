@@ -0,0 +1,72 @@
+package exact_one
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec encodes the values of the generic helpers, implementasi lain (ex msgpack) cukup memenuhi interface ini.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// JSONCodec is the default codec, key tanpa codec terdaftar tetap json seperti data lama.
+var JSONCodec Codec = jsonCodec{}
+
+type codecPrefix struct {
+	prefix string
+	codec  Codec
+}
+
+// codecRegistry picks the codec of the longest matching prefix, sama seperti Retention.
+type codecRegistry struct {
+	lock     sync.RWMutex
+	prefixes []*codecPrefix
+}
+
+func (r *codecRegistry) set(prefix string, codec Codec) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, item := range r.prefixes {
+		if item.prefix == prefix {
+			item.codec = codec
+			return
+		}
+	}
+
+	r.prefixes = append(r.prefixes, &codecPrefix{prefix: prefix, codec: codec})
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+}
+
+func (r *codecRegistry) get(key string) Codec {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, item := range r.prefixes {
+		if strings.HasPrefix(key, item.prefix) {
+			return item.codec
+		}
+	}
+	return JSONCodec
+}
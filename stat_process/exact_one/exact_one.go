@@ -2,13 +2,13 @@ package exact_one
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/jackc/pglogrepl"
 	"github.com/pdcgo/materialize/stat_replica"
 )
 
@@ -26,12 +26,41 @@ type ExactlyOnce interface {
 
 	AddItemWithKey(pKey string, cdata *stat_replica.CdcMessage) (bool, error)
 	GetItem(key string) (map[string]interface{}, error)
+	// Truncate removes every item of the table.
+	Truncate(meta *stat_replica.SourceMetadata) error
+
+	// Applied reports whether effect of cdata was committed, before diisi data lama yang dicatat MarkApplied.
+	// Pesan tanpa posisi (backfill) selalu dianggap belum applied.
+	Applied(effect string, cdata *stat_replica.CdcMessage, before interface{}) (applied bool, found bool, err error)
+	// MarkApplied records effect of cdata, dipanggil di dalam Transaction bersama efeknya.
+	MarkApplied(effect string, cdata *stat_replica.CdcMessage, before interface{}) error
+	// PruneApplied removes markers of messages up to lsn, aman setelah lsn itu dikonfirmasi ke source.
+	PruneApplied(until pglogrepl.LSN) (int, error)
+	// SetRetention sets the ttl of keys written after this call, nil berarti disimpan selamanya.
+	SetRetention(retention *Retention)
+
+	// raw access untuk Get, Put, Update dan Scan
+	// Codec returns the codec of key, key tanpa codec terdaftar memakai JSONCodec.
+	Codec(key string) Codec
+	// SetCodec sets the codec of keys under prefix, dipasang sebelum data prefix itu ditulis.
+	SetCodec(prefix string, codec Codec)
+	GetRaw(key string) ([]byte, bool, error)
+	PutRaw(key string, val []byte) error
+	DeleteRaw(key string) error
+	ScanRaw(prefix string, handle func(key string, val []byte) error) error
 }
 
 type exactOneImpl struct {
-	ctx context.Context
-	db  *badger.DB
-	tx  *badger.Txn
+	ctx       context.Context
+	db        *badger.DB
+	tx        *badger.Txn
+	retention *Retention
+	codecs    *codecRegistry
+}
+
+// SetRetention implements ExactlyOnce.
+func (e *exactOneImpl) SetRetention(retention *Retention) {
+	e.retention = retention
 }
 
 // GetItemStructUntilExist implements ExactlyOnce.
@@ -65,7 +94,7 @@ func (e *exactOneImpl) Change(data ExactHaveKey) ChangeExactOne {
 		intx = false
 		tx = e.db.NewTransaction(true)
 	}
-	return NewSaveExactOne(tx, data, intx)
+	return NewSaveExactOne(tx, data, intx, e.retention, e.codecs.get(data.Key()))
 }
 
 // GetItemStructKey implements ExactlyOnce.
@@ -87,7 +116,7 @@ func (e *exactOneImpl) GetItemStructKey(key string, data ExactHaveKey) (bool, er
 			return err
 		}
 		found = true
-		return json.Unmarshal(val, data)
+		return e.codecs.get(key).Unmarshal(val, data)
 	})
 
 	return found, err
@@ -120,7 +149,7 @@ func (e *exactOneImpl) GetItemStruct(data ExactHaveKey) (bool, error) {
 			return err
 		}
 		found = true
-		return json.Unmarshal(val, data)
+		return e.codecs.get(data.Key()).Unmarshal(val, data)
 	})
 	return found, err
 }
@@ -133,12 +162,12 @@ func (e *exactOneImpl) SaveItemStruct(datas ...ExactHaveKey) error {
 
 		for _, data := range datas {
 			var raw []byte
-			raw, err = json.Marshal(data)
+			raw, err = e.codecs.get(data.Key()).Marshal(data)
 			if err != nil {
 				return err
 			}
 
-			err = exact.tx.Set([]byte(data.Key()), raw)
+			err = e.retention.set(exact.tx, []byte(data.Key()), raw)
 			if err != nil {
 				return err
 			}
@@ -158,15 +187,19 @@ func (e *exactOneImpl) getTx(update bool, handle func(exact *exactOneImpl) error
 	if update {
 		return e.db.Update(func(txn *badger.Txn) error {
 			return handle(&exactOneImpl{
-				ctx: e.ctx,
-				tx:  txn,
+				ctx:       e.ctx,
+				tx:        txn,
+				retention: e.retention,
+				codecs:    e.codecs,
 			})
 		})
 	}
 	return e.db.View(func(txn *badger.Txn) error {
 		return handle(&exactOneImpl{
-			ctx: e.ctx,
-			tx:  txn,
+			ctx:       e.ctx,
+			tx:        txn,
+			retention: e.retention,
+			codecs:    e.codecs,
 		})
 	})
 }
@@ -184,17 +217,26 @@ func (e *exactOneImpl) GetItem(key string) (map[string]interface{}, error) {
 		if err != nil {
 			return err
 		}
-		return json.Unmarshal(val, &result)
+		return e.codecs.get(key).Unmarshal(val, &result)
 	})
 
 	return result, err
 }
 
+// Truncate implements ExactlyOnce.
+func (e *exactOneImpl) Truncate(meta *stat_replica.SourceMetadata) error {
+	return e.db.DropPrefix([]byte(meta.PrefixKey()))
+}
+
 // AddItem implements ExactlyOnce.
 func (e *exactOneImpl) AddItemWithKey(pKey string, cdata *stat_replica.CdcMessage) (bool, error) {
 	var update bool
 	var err error
 
+	if cdata.ModType == stat_replica.CdcTruncate {
+		return true, e.Truncate(cdata.SourceMetadata)
+	}
+
 	err = e.db.Update(func(txn *badger.Txn) error {
 		key, err := e.extractKey(pKey, cdata)
 		if err != nil {
@@ -212,10 +254,12 @@ func (e *exactOneImpl) AddItemWithKey(pKey string, cdata *stat_replica.CdcMessag
 		}
 
 		var oldata interface{}
+		// old data dari source tidak ditimpa cache
+		fromSource := cdata.OldData != nil
 		switch cdata.ModType {
 		case stat_replica.CdcInsert:
 			update = true
-			if found {
+			if found && !fromSource {
 				oldata, err = e.oldData(cdata.SourceMetadata, item)
 				if err != nil {
 					return err
@@ -230,7 +274,7 @@ func (e *exactOneImpl) AddItemWithKey(pKey string, cdata *stat_replica.CdcMessag
 
 		case stat_replica.CdcUpdate:
 			update = true
-			if found {
+			if found && !fromSource {
 				oldata, err = e.oldData(cdata.SourceMetadata, item)
 				if err != nil {
 					return err
@@ -241,6 +285,11 @@ func (e *exactOneImpl) AddItemWithKey(pKey string, cdata *stat_replica.CdcMessag
 				// 	return err
 				// }
 			}
+
+			err = stat_replica.MergeUnchanged(cdata.Data, cdata.OldData, cdata.Present)
+			if err != nil {
+				return err
+			}
 		case stat_replica.CdcBackfill:
 			if found {
 				return nil
@@ -251,11 +300,13 @@ func (e *exactOneImpl) AddItemWithKey(pKey string, cdata *stat_replica.CdcMessag
 				return nil
 			}
 
-			oldata, err = e.oldData(cdata.SourceMetadata, item)
-			if err != nil {
-				return err
+			if !fromSource {
+				oldata, err = e.oldData(cdata.SourceMetadata, item)
+				if err != nil {
+					return err
+				}
+				cdata.OldData = oldata
 			}
-			cdata.OldData = oldata
 
 			err = txn.Delete([]byte(key))
 			if err != nil {
@@ -265,11 +316,11 @@ func (e *exactOneImpl) AddItemWithKey(pKey string, cdata *stat_replica.CdcMessag
 			return nil
 		}
 
-		raw, err := json.Marshal(cdata.Data)
+		raw, err := e.codecs.get(key).Marshal(cdata.Data)
 		if err != nil {
 			return err
 		}
-		return txn.Set([]byte(key), raw)
+		return e.retention.set(txn, []byte(key), raw)
 	})
 	return update, err
 }
@@ -288,7 +339,7 @@ func (e *exactOneImpl) oldData(meta *stat_replica.SourceMetadata, item *badger.I
 	if err != nil {
 		return result, err
 	}
-	err = json.Unmarshal(val, &result)
+	err = e.codecs.get(string(item.Key())).Unmarshal(val, &result)
 	return result, err
 }
 
@@ -328,8 +379,9 @@ func (e *exactOneImpl) extractKey(pKey string, cdata *stat_replica.CdcMessage) (
 func NewBadgeExactOne(ctx context.Context, db *badger.DB) ExactlyOnce {
 
 	return &exactOneImpl{
-		ctx: ctx,
-		db:  db,
+		ctx:    ctx,
+		db:     db,
+		codecs: &codecRegistry{},
 	}
 }
 
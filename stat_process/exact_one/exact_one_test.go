@@ -121,6 +121,48 @@ func TestExactOne(t *testing.T) {
 				assert.NotEmpty(t, deleted.Data)
 			})
 
+			t.Run("test truncate hapus semua item tabel", func(t *testing.T) {
+				for _, id := range []int{2, 3} {
+					_, err := exact.AddItemWithKey("id", &stat_replica.CdcMessage{
+						SourceMetadata: &stat_replica.SourceMetadata{
+							Table:  "test",
+							Schema: "public",
+						},
+						ModType: stat_replica.CdcInsert,
+						Data: map[string]interface{}{
+							"id": id,
+						},
+					})
+					assert.Nil(t, err)
+				}
+				_, err := exact.AddItemWithKey("id", &stat_replica.CdcMessage{
+					SourceMetadata: &stat_replica.SourceMetadata{
+						Table:  "other",
+						Schema: "public",
+					},
+					ModType: stat_replica.CdcInsert,
+					Data: map[string]interface{}{
+						"id": 2,
+					},
+				})
+				assert.Nil(t, err)
+
+				update, err := exact.AddItemWithKey("id", &stat_replica.CdcMessage{
+					SourceMetadata: &stat_replica.SourceMetadata{
+						Table:  "test",
+						Schema: "public",
+					},
+					ModType: stat_replica.CdcTruncate,
+				})
+				assert.Nil(t, err)
+				assert.True(t, update)
+
+				_, err = exact.GetItem("public/test/2")
+				assert.NotNil(t, err)
+				_, err = exact.GetItem("public/other/2")
+				assert.Nil(t, err)
+			})
+
 		},
 	)
 }
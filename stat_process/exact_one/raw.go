@@ -0,0 +1,73 @@
+package exact_one
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Codec implements ExactlyOnce.
+func (e *exactOneImpl) Codec(key string) Codec {
+	return e.codecs.get(key)
+}
+
+// SetCodec implements ExactlyOnce.
+func (e *exactOneImpl) SetCodec(prefix string, codec Codec) {
+	e.codecs.set(prefix, codec)
+}
+
+// GetRaw implements ExactlyOnce.
+func (e *exactOneImpl) GetRaw(key string) ([]byte, bool, error) {
+	var val []byte
+	var found bool
+	err := e.getTx(false, func(exact *exactOneImpl) error {
+		item, err := exact.tx.Get([]byte(key))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		found = true
+		val, err = item.ValueCopy(nil)
+		return err
+	})
+	return val, found, err
+}
+
+// PutRaw implements ExactlyOnce.
+func (e *exactOneImpl) PutRaw(key string, val []byte) error {
+	return e.getTx(true, func(exact *exactOneImpl) error {
+		return e.retention.set(exact.tx, []byte(key), val)
+	})
+}
+
+// DeleteRaw implements ExactlyOnce.
+func (e *exactOneImpl) DeleteRaw(key string) error {
+	return e.getTx(true, func(exact *exactOneImpl) error {
+		return exact.tx.Delete([]byte(key))
+	})
+}
+
+// ScanRaw implements ExactlyOnce.
+func (e *exactOneImpl) ScanRaw(prefix string, handle func(key string, val []byte) error) error {
+	return e.getTx(false, func(exact *exactOneImpl) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		iter := exact.tx.NewIterator(opts)
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = handle(string(item.Key()), val)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package exact_one
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// RetentionRule expires keys under Prefix TTL after their last write, ex "public/orders/" 180 hari.
+type RetentionRule struct {
+	Prefix string
+	TTL    time.Duration
+}
+
+// Retention picks the rule of the longest matching prefix, key tanpa rule disimpan selamanya.
+type Retention struct {
+	rules []*RetentionRule
+}
+
+func (r *Retention) Rules() []*RetentionRule {
+	return r.rules
+}
+
+func (r *Retention) TTL(key string) time.Duration {
+	if r == nil {
+		return 0
+	}
+	for _, rule := range r.rules {
+		if strings.HasPrefix(key, rule.Prefix) {
+			return rule.TTL
+		}
+	}
+	return 0
+}
+
+func (r *Retention) entry(key, val []byte) *badger.Entry {
+	entry := badger.NewEntry(key, val)
+	ttl := r.TTL(string(key))
+	if ttl > 0 {
+		entry = entry.WithTTL(ttl)
+	}
+	return entry
+}
+
+func (r *Retention) set(txn *badger.Txn, key, val []byte) error {
+	return txn.SetEntry(r.entry(key, val))
+}
+
+// Apply sets the ttl of existing keys written before the rule existed, dihitung dari sekarang.
+func (r *Retention) Apply(db *badger.DB) (int, error) {
+	count := 0
+	for _, rule := range r.rules {
+		if rule.TTL <= 0 {
+			continue
+		}
+
+		batch := db.NewWriteBatch()
+		err := db.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = []byte(rule.Prefix)
+			iter := txn.NewIterator(opts)
+			defer iter.Close()
+
+			for iter.Rewind(); iter.Valid(); iter.Next() {
+				item := iter.Item()
+				if item.ExpiresAt() != 0 {
+					continue
+				}
+				key := item.KeyCopy(nil)
+				// prefix lebih panjang punya rule sendiri
+				if r.TTL(string(key)) != rule.TTL {
+					continue
+				}
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				err = batch.SetEntry(badger.NewEntry(key, val).WithTTL(rule.TTL))
+				if err != nil {
+					return err
+				}
+				count += 1
+			}
+			return nil
+		})
+		if err != nil {
+			batch.Cancel()
+			return count, err
+		}
+
+		err = batch.Flush()
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func NewRetention(rules ...*RetentionRule) *Retention {
+	sorted := append([]*RetentionRule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+	return &Retention{
+		rules: sorted,
+	}
+}
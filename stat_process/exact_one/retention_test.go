@@ -0,0 +1,80 @@
+package exact_one_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/models"
+	"github.com/pdcgo/materialize/stat_process/stat_db"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetention(t *testing.T) {
+	var badgedb db_mock.BadgeDBMock
+
+	moretest.Suite(t, "testing retention exact one",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&badgedb),
+		},
+		func(t *testing.T) {
+			exact := exact_one.NewBadgeExactOne(t.Context(), badgedb.DB)
+
+			// ditulis sebelum ada rule
+			err := exact.Change(&models.InvOrderData{InvID: 1}).Save().Err()
+			assert.Nil(t, err)
+
+			retention := exact_one.NewRetention(
+				&exact_one.RetentionRule{Prefix: "cache/", TTL: time.Hour},
+				&exact_one.RetentionRule{Prefix: "cache/inv_order/", TTL: time.Hour * 24},
+			)
+			exact.SetRetention(retention)
+
+			t.Run("prefix terpanjang dipakai", func(t *testing.T) {
+				assert.Equal(t, time.Hour*24, retention.TTL("cache/inv_order/1"))
+				assert.Equal(t, time.Hour, retention.TTL("cache/other/1"))
+				assert.Zero(t, retention.TTL("public/orders/1"))
+			})
+
+			t.Run("key baru punya ttl", func(t *testing.T) {
+				err := exact.Change(&models.InvOrderData{InvID: 2}).Save().Err()
+				assert.Nil(t, err)
+
+				err = badgedb.DB.View(func(txn *badger.Txn) error {
+					item, err := txn.Get([]byte("cache/inv_order/2"))
+					if err != nil {
+						return err
+					}
+					expires := time.Unix(int64(item.ExpiresAt()), 0)
+					assert.WithinDuration(t, time.Now().Add(time.Hour*24), expires, time.Minute)
+					return nil
+				})
+				assert.Nil(t, err)
+			})
+
+			t.Run("stats sebelum dan sesudah apply", func(t *testing.T) {
+				stats, err := stat_db.PrefixStats(badgedb.DB, "cache/inv_order/", "public/")
+				assert.Nil(t, err)
+				assert.Equal(t, int64(2), stats[0].Keys)
+				assert.Equal(t, int64(1), stats[0].Expiring)
+				assert.NotZero(t, stats[0].ValueSize)
+				assert.Equal(t, int64(0), stats[1].Keys)
+
+				count, err := retention.Apply(badgedb.DB)
+				assert.Nil(t, err)
+				assert.Equal(t, 1, count)
+
+				stats, err = stat_db.PrefixStats(badgedb.DB, "cache/inv_order/")
+				assert.Nil(t, err)
+				assert.Equal(t, int64(2), stats[0].Expiring)
+
+				found, err := exact.GetItemStruct(&models.InvOrderData{InvID: 1})
+				assert.Nil(t, err)
+				assert.True(t, found)
+			})
+		},
+	)
+}
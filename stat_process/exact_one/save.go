@@ -1,7 +1,6 @@
 package exact_one
 
 import (
-	"encoding/json"
 	"errors"
 
 	"github.com/dgraph-io/badger/v3"
@@ -16,10 +15,12 @@ type ChangeExactOne interface {
 }
 
 type saveExactOneImpl struct {
-	intx bool
-	txn  *badger.Txn
-	err  error
-	newd ExactHaveKey
+	intx      bool
+	txn       *badger.Txn
+	err       error
+	newd      ExactHaveKey
+	retention *Retention
+	codec     Codec
 }
 
 // Delete implements ChangeExactOne.
@@ -62,7 +63,7 @@ func (s *saveExactOneImpl) Before(found *bool, data ExactHaveKey) ChangeExactOne
 		return s.setErr(err)
 	}
 
-	err = json.Unmarshal(val, data)
+	err = s.codec.Unmarshal(val, data)
 	*found = true
 	return s.setErr(err)
 }
@@ -75,12 +76,12 @@ func (s *saveExactOneImpl) Err() error {
 // Save implements SaveExactOne.
 func (s *saveExactOneImpl) Save() ChangeExactOne {
 	var err error
-	raw, err := json.Marshal(s.newd)
+	raw, err := s.codec.Marshal(s.newd)
 	if err != nil {
 		return s.setErr(err)
 	}
 
-	err = s.txn.Set([]byte(s.newd.Key()), raw)
+	err = s.retention.set(s.txn, []byte(s.newd.Key()), raw)
 	if err != nil {
 		return s.setErr(err)
 	}
@@ -108,10 +109,16 @@ func (s *saveExactOneImpl) setErr(err error) *saveExactOneImpl {
 	return s
 }
 
-func NewSaveExactOne(txn *badger.Txn, newd ExactHaveKey, intx bool) ChangeExactOne {
+// NewSaveExactOne creates a change of newd, retention boleh nil dan codec nil berarti JSONCodec.
+func NewSaveExactOne(txn *badger.Txn, newd ExactHaveKey, intx bool, retention *Retention, codec Codec) ChangeExactOne {
+	if codec == nil {
+		codec = JSONCodec
+	}
 	return &saveExactOneImpl{
-		intx: intx,
-		txn:  txn,
-		newd: newd,
+		intx:      intx,
+		txn:       txn,
+		newd:      newd,
+		retention: retention,
+		codec:     codec,
 	}
 }
@@ -0,0 +1,66 @@
+package exact_one
+
+import "reflect"
+
+// helper generic tidak bisa jadi method interface, jadi dibuat sebagai function di atas raw access ExactlyOnce.
+// Di dalam Transaction semua helper memakai transaksi yang sama.
+
+// Get decodes the value of key with the codec registered for key.
+func Get[T any](exact ExactlyOnce, key string) (*T, bool, error) {
+	raw, found, err := exact.GetRaw(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+
+	data := new(T)
+	err = exact.Codec(key).Unmarshal(raw, data)
+	return data, true, err
+}
+
+func Put[T any](exact ExactlyOnce, key string, data *T) error {
+	raw, err := exact.Codec(key).Marshal(data)
+	if err != nil {
+		return err
+	}
+	return exact.PutRaw(key, raw)
+}
+
+// Update reads, changes and writes key in one transaction, old nil kalau key belum ada.
+// Handle returning nil deletes the key.
+func Update[T any](exact ExactlyOnce, key string, handle func(old *T) *T) (*T, error) {
+	var result *T
+	err := exact.Transaction(true, func(exact ExactlyOnce) error {
+		old, _, err := Get[T](exact, key)
+		if err != nil {
+			return err
+		}
+
+		result = handle(old)
+		if result == nil {
+			return exact.DeleteRaw(key)
+		}
+		return Put(exact, key, result)
+	})
+	return result, err
+}
+
+// Scan decodes every value under prefix in key order, stop dengan mengembalikan error dari handle.
+func Scan[T any](exact ExactlyOnce, prefix string, handle func(key string, data *T) error) error {
+	return exact.ScanRaw(prefix, func(key string, raw []byte) error {
+		data := new(T)
+		err := exact.Codec(key).Unmarshal(raw, data)
+		if err != nil {
+			return err
+		}
+		return handle(key, data)
+	})
+}
+
+// Empty returns a new zero value of the type of data, untuk stage yang type datanya baru diketahui saat runtime.
+func Empty[T ExactHaveKey](data T) T {
+	typ := reflect.TypeOf(data)
+	if typ.Kind() == reflect.Pointer {
+		return reflect.New(typ.Elem()).Interface().(T)
+	}
+	return reflect.New(typ).Elem().Interface().(T)
+}
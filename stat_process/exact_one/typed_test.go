@@ -0,0 +1,126 @@
+package exact_one_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/models"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+// upperCodec is json with upper case keys, cukup untuk membedakan codec per prefix.
+type upperCodec struct{}
+
+func (upperCodec) Name() string {
+	return "upper"
+}
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	return bytes.ToUpper(raw), err
+}
+
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(bytes.ToLower(data), v)
+}
+
+func TestTypedHelper(t *testing.T) {
+	var badgedb db_mock.BadgeDBMock
+
+	moretest.Suite(t, "testing typed exact one",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&badgedb),
+		},
+		func(t *testing.T) {
+			exact := exact_one.NewBadgeExactOne(t.Context(), badgedb.DB)
+
+			t.Run("put get json kompatibel dengan GetItemStruct", func(t *testing.T) {
+				inv := &models.InvOrderData{InvID: 1, OrderID: 10, WarehouseFee: 2000}
+				err := exact_one.Put(exact, inv.Key(), inv)
+				assert.Nil(t, err)
+
+				data, found, err := exact_one.Get[models.InvOrderData](exact, inv.Key())
+				assert.Nil(t, err)
+				assert.True(t, found)
+				assert.Equal(t, inv, data)
+
+				old := &models.InvOrderData{InvID: 1}
+				found, err = exact.GetItemStruct(old)
+				assert.Nil(t, err)
+				assert.True(t, found)
+				assert.Equal(t, uint(10), old.OrderID)
+
+				_, found, err = exact_one.Get[models.InvOrderData](exact, "cache/inv_order/99")
+				assert.Nil(t, err)
+				assert.False(t, found)
+			})
+
+			t.Run("update dan delete", func(t *testing.T) {
+				key := (&models.InvOrderData{InvID: 2}).Key()
+				for i := 0; i < 3; i++ {
+					_, err := exact_one.Update(exact, key, func(old *models.InvOrderData) *models.InvOrderData {
+						if old == nil {
+							return &models.InvOrderData{InvID: 2, MpTotal: 1}
+						}
+						old.MpTotal += 1
+						return old
+					})
+					assert.Nil(t, err)
+				}
+
+				data, _, err := exact_one.Get[models.InvOrderData](exact, key)
+				assert.Nil(t, err)
+				assert.Equal(t, 3.0, data.MpTotal)
+
+				_, err = exact_one.Update(exact, key, func(old *models.InvOrderData) *models.InvOrderData {
+					return nil
+				})
+				assert.Nil(t, err)
+				_, found, err := exact_one.Get[models.InvOrderData](exact, key)
+				assert.Nil(t, err)
+				assert.False(t, found)
+			})
+
+			t.Run("scan prefix", func(t *testing.T) {
+				keys := []string{}
+				err := exact_one.Scan(exact, "cache/inv_order/", func(key string, data *models.InvOrderData) error {
+					keys = append(keys, key)
+					return nil
+				})
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"cache/inv_order/1"}, keys)
+
+				stop := errors.New("stop")
+				err = exact_one.Scan(exact, "cache/", func(key string, data *models.InvOrderData) error {
+					return stop
+				})
+				assert.ErrorIs(t, err, stop)
+			})
+
+			t.Run("codec per prefix", func(t *testing.T) {
+				exact.SetCodec("upper/", upperCodec{})
+
+				inv := &models.InvOrderData{InvID: 7, OrderID: 70, TeamID: 3, MpTotal: 125000.5}
+				err := exact_one.Put(exact, "upper/inv/7", inv)
+				assert.Nil(t, err)
+
+				raw, _, err := exact.GetRaw("upper/inv/7")
+				assert.Nil(t, err)
+				assert.Contains(t, string(raw), `"INV_ID":7`)
+
+				data, found, err := exact_one.Get[models.InvOrderData](exact, "upper/inv/7")
+				assert.Nil(t, err)
+				assert.True(t, found)
+				assert.Equal(t, inv, data)
+
+				// prefix lain tetap json
+				assert.Equal(t, exact_one.JSONCodec, exact.Codec("cache/inv_order/1"))
+			})
+		},
+	)
+}
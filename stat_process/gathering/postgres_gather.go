@@ -58,6 +58,17 @@ func (p *postgresGatherImpl) SaveItem(acc any) error {
 	return p.db.Save(facc).Error
 }
 
+// SaveItemTo saves acc to table instead of the table of its type, dipakai untuk rollup window.
+func (p *postgresGatherImpl) SaveItemTo(table string, acc any) error {
+	facc, ok := acc.(CanFressness)
+	if !ok {
+		name := reflect.TypeOf(acc).Elem().Name()
+		return fmt.Errorf("item doesnt implement freshness %s", name)
+	}
+	facc.SetFreshness(time.Now().Local())
+	return p.db.Table(table).Save(facc).Error
+}
+
 func (p *postgresGatherImpl) StartSync() error {
 
 	go func() {
@@ -102,17 +113,22 @@ func CreateDB() (*gorm.DB, error) {
 		return db, err
 	}
 
-	err = db.AutoMigrate(
+	return db, MigrateMetric(db)
+}
+
+// MigrateMetric creates the metric tables, kolom baru (ex final) ditambahkan ke tabel lama dengan default.
+func MigrateMetric(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&metric.DailyShopeepayBalance{},
 		&selling_metric.DailyShopMetricData{},
 		&selling_metric.DailyTeamMetricData{},
 		&selling_metric.DailyBankBalance{},
 	)
 	if err != nil {
-		return db, err
+		return err
 	}
 
-	return db, err
+	return db.Table(selling_metric.ShopRollupTable).AutoMigrate(&selling_metric.DailyShopMetricData{})
 }
 
 func NewPostgresGather(ctx context.Context, db *gorm.DB) *postgresGatherImpl {
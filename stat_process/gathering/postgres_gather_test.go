@@ -0,0 +1,52 @@
+package gathering_test
+
+import (
+	"testing"
+
+	"github.com/pdcgo/materialize/selling_metric"
+	"github.com/pdcgo/materialize/stat_process/gathering"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/pdcgo/shared/pkg/moretest/moretest_mock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// oldDailyShop is daily shop sebelum ada kolom final.
+type oldDailyShop struct {
+	Day    string `gorm:"primaryKey"`
+	ShopID uint   `gorm:"primaryKey"`
+	TeamID uint
+}
+
+func TestMigrateMetric(t *testing.T) {
+	var db gorm.DB
+
+	moretest.Suite(t, "testing migrate metric",
+		moretest.SetupListFunc{
+			moretest_mock.MockSqliteDatabase(&db),
+		},
+		func(t *testing.T) {
+			for _, table := range []string{"daily_shop_metric_data", selling_metric.ShopRollupTable} {
+				err := db.Table(table).AutoMigrate(&oldDailyShop{})
+				assert.Nil(t, err)
+				err = db.Table(table).Create(&oldDailyShop{Day: "2025-08-01", ShopID: 1, TeamID: 2}).Error
+				assert.Nil(t, err)
+			}
+
+			err := gathering.MigrateMetric(&db)
+			assert.Nil(t, err)
+
+			for _, table := range []string{"daily_shop_metric_data", selling_metric.ShopRollupTable} {
+				t.Run("kolom final ditambahkan ke "+table, func(t *testing.T) {
+					assert.True(t, db.Migrator().HasColumn(table, "final"))
+
+					data := selling_metric.DailyShopMetricData{}
+					err := db.Table(table).First(&data, "day = ? AND shop_id = ?", "2025-08-01", 1).Error
+					assert.Nil(t, err)
+					assert.False(t, data.Final)
+					assert.Equal(t, uint(2), data.TeamID)
+				})
+			}
+		},
+	)
+}
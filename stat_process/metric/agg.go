@@ -0,0 +1,259 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AggRule is the value of the agg struct tag.
+type AggRule string
+
+const (
+	AggSum AggRule = "sum"
+	AggMax AggRule = "max"
+	// AggLast keeps the newest non zero value, nilai dari argumen Merge yang lebih baru
+	AggLast AggRule = "last"
+	// AggCountDistinct unions a Distinct field
+	AggCountDistinct AggRule = "count_distinct"
+	// AggIgnore for fields that are not merged, ex freshness
+	AggIgnore AggRule = "-"
+)
+
+var ErrAggRule = errors.New("invalid aggregation rule")
+
+// Distinct is the set behind a count_distinct field.
+type Distinct map[string]bool
+
+func (d Distinct) Add(value any) Distinct {
+	if d == nil {
+		d = Distinct{}
+	}
+	d[fmt.Sprint(value)] = true
+	return d
+}
+
+func (d Distinct) Count() int {
+	return len(d)
+}
+
+type aggField struct {
+	index int
+	name  string
+	rule  AggRule
+}
+
+// Agg merges, keys and negates T from its agg struct tags, field numeric tanpa tag dianggap error
+// supaya field baru tidak lupa dijumlahkan.
+type Agg[T any] struct {
+	prefix string
+	keys   []aggField
+	fields []aggField
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// NewAgg reads the rules of T, keys adalah nama field key sesuai urutan di key.
+func NewAgg[T any](prefix string, keys ...string) (*Agg[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not struct", ErrAggRule, typ)
+	}
+
+	agg := Agg[T]{
+		prefix: prefix,
+	}
+
+	isKey := map[string]bool{}
+	for _, key := range keys {
+		field, ok := typ.FieldByName(key)
+		if !ok || len(field.Index) != 1 {
+			return nil, fmt.Errorf("%w: key %s.%s not found", ErrAggRule, typ.Name(), key)
+		}
+		isKey[key] = true
+		agg.keys = append(agg.keys, aggField{index: field.Index[0], name: key})
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || isKey[field.Name] {
+			continue
+		}
+
+		numeric := isNumericKind(field.Type.Kind())
+		tag, ok := field.Tag.Lookup("agg")
+		if !ok || tag == "" {
+			if numeric {
+				return nil, fmt.Errorf("%w: %s.%s numeric field without agg tag", ErrAggRule, typ.Name(), field.Name)
+			}
+			continue
+		}
+
+		rule := AggRule(tag)
+		switch rule {
+		case AggIgnore:
+			continue
+		case AggSum, AggMax:
+			if !numeric {
+				return nil, fmt.Errorf("%w: %s.%s %s on non numeric field", ErrAggRule, typ.Name(), field.Name, rule)
+			}
+		case AggLast:
+		case AggCountDistinct:
+			if field.Type != reflect.TypeOf(Distinct(nil)) {
+				return nil, fmt.Errorf("%w: %s.%s count_distinct needs metric.Distinct", ErrAggRule, typ.Name(), field.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s.%s unknown rule %s", ErrAggRule, typ.Name(), field.Name, rule)
+		}
+
+		agg.fields = append(agg.fields, aggField{index: i, name: field.Name, rule: rule})
+	}
+
+	return &agg, nil
+}
+
+// MustAgg is NewAgg for package level definitions, panic kalau rule tidak valid.
+func MustAgg[T any](prefix string, keys ...string) *Agg[T] {
+	agg, err := NewAgg[T](prefix, keys...)
+	if err != nil {
+		panic(err)
+	}
+	return agg
+}
+
+// Key joins the key fields after prefix, ex metric/daily_team/2025-08-01/1.
+func (a *Agg[T]) Key(data *T) string {
+	return a.KeyWith(a.prefix, data)
+}
+
+func (a *Agg[T]) KeyWith(prefix string, data *T) string {
+	val := reflect.ValueOf(data).Elem()
+	parts := make([]string, 0, len(a.keys)+1)
+	parts = append(parts, prefix)
+	for _, key := range a.keys {
+		parts = append(parts, fmt.Sprint(val.Field(key.index).Interface()))
+	}
+	return strings.Join(parts, "/")
+}
+
+// Merge folds data into acc and returns acc, sama dengan MetricData.Merge acc adalah accumulator yang
+// lebih lama dan data nilai yang lebih baru.
+func (a *Agg[T]) Merge(acc *T, data *T) *T {
+	if data == nil {
+		return acc
+	}
+
+	dval := reflect.ValueOf(acc).Elem()
+	oval := reflect.ValueOf(data).Elem()
+	for _, field := range a.fields {
+		dst := dval.Field(field.index)
+		src := oval.Field(field.index)
+
+		switch field.rule {
+		case AggSum:
+			switch {
+			case dst.CanInt():
+				dst.SetInt(dst.Int() + src.Int())
+			case dst.CanUint():
+				dst.SetUint(dst.Uint() + src.Uint())
+			default:
+				dst.SetFloat(dst.Float() + src.Float())
+			}
+		case AggMax:
+			switch {
+			case dst.CanInt():
+				dst.SetInt(max(dst.Int(), src.Int()))
+			case dst.CanUint():
+				dst.SetUint(max(dst.Uint(), src.Uint()))
+			default:
+				dst.SetFloat(max(dst.Float(), src.Float()))
+			}
+		case AggLast:
+			if !src.IsZero() {
+				dst.Set(src)
+			}
+		case AggCountDistinct:
+			if src.Len() == 0 {
+				continue
+			}
+			union := Distinct{}
+			for key := range src.Interface().(Distinct) {
+				union[key] = true
+			}
+			for key := range dst.Interface().(Distinct) {
+				union[key] = true
+			}
+			dst.Set(reflect.ValueOf(union))
+		}
+	}
+	return acc
+}
+
+// Negate returns a copy with the signed sum fields negated, rule lain tidak bisa ditarik.
+func (a *Agg[T]) Negate(data *T) *T {
+	item := *data
+	val := reflect.ValueOf(&item).Elem()
+	for _, field := range a.fields {
+		if field.rule != AggSum {
+			continue
+		}
+
+		dst := val.Field(field.index)
+		switch {
+		case dst.CanInt():
+			dst.SetInt(-dst.Int())
+		case dst.CanFloat():
+			dst.SetFloat(-dst.Float())
+		}
+	}
+	return &item
+}
+
+// Projection copies the key and aggregated fields of T from S by field name, ex daily shop ke daily team.
+type Projection[S any, T any] struct {
+	pairs [][2]int
+}
+
+func NewProjection[S any, T any](target *Agg[T]) (*Projection[S, T], error) {
+	styp := reflect.TypeOf((*S)(nil)).Elem()
+	ttyp := reflect.TypeOf((*T)(nil)).Elem()
+
+	proj := Projection[S, T]{}
+	fields := append(append([]aggField{}, target.keys...), target.fields...)
+	for _, field := range fields {
+		tfield := ttyp.Field(field.index)
+		sfield, ok := styp.FieldByName(field.name)
+		if !ok || len(sfield.Index) != 1 || sfield.Type != tfield.Type {
+			return nil, fmt.Errorf("%w: %s.%s has no source in %s", ErrAggRule, ttyp.Name(), field.name, styp.Name())
+		}
+		proj.pairs = append(proj.pairs, [2]int{sfield.Index[0], field.index})
+	}
+	return &proj, nil
+}
+
+func MustProjection[S any, T any](target *Agg[T]) *Projection[S, T] {
+	proj, err := NewProjection[S](target)
+	if err != nil {
+		panic(err)
+	}
+	return proj
+}
+
+func (p *Projection[S, T]) Apply(src *S) *T {
+	result := new(T)
+	sval := reflect.ValueOf(src).Elem()
+	tval := reflect.ValueOf(result).Elem()
+	for _, pair := range p.pairs {
+		tval.Field(pair[1]).Set(sval.Field(pair[0]))
+	}
+	return result
+}
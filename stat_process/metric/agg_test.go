@@ -0,0 +1,167 @@
+package metric_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/metric"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+type taggedMetric struct {
+	Day       string          `json:"day"`
+	TeamID    uint            `json:"team_id"`
+	Amount    float64         `json:"amount" agg:"sum"`
+	Qty       int             `json:"qty" agg:"sum"`
+	Peak      float64         `json:"peak" agg:"max"`
+	Name      string          `json:"name" agg:"last"`
+	Orders    metric.Distinct `json:"orders" agg:"count_distinct"`
+	Rank      int             `json:"rank" agg:"-"`
+	Freshness time.Time       `json:"freshness"`
+}
+
+type untaggedMetric struct {
+	Day    string  `json:"day"`
+	Amount float64 `json:"amount" agg:"sum"`
+	Fee    float64 `json:"fee"`
+}
+
+type projectedMetric struct {
+	Day    string  `json:"day"`
+	Amount float64 `json:"amount" agg:"sum"`
+	Fee    float64 `json:"fee" agg:"sum"`
+}
+
+func TestAgg(t *testing.T) {
+	agg, err := metric.NewAgg[taggedMetric]("metric/tagged", "Day", "TeamID")
+	assert.Nil(t, err)
+
+	t.Run("merge sesuai rule", func(t *testing.T) {
+		old := &taggedMetric{
+			Day: "2025-08-01", TeamID: 1,
+			Amount: 10, Qty: 2, Peak: 7, Name: "lama", Rank: 5,
+			Orders: metric.Distinct{}.Add(1).Add(2),
+		}
+		data := &taggedMetric{
+			Day: "2025-08-01", TeamID: 1,
+			Amount: 5, Qty: 1, Peak: 3, Rank: 1,
+			Orders: metric.Distinct{}.Add(2).Add(3),
+		}
+
+		result := agg.Merge(old, data)
+		assert.Same(t, old, result)
+		assert.Equal(t, 15.0, result.Amount)
+		assert.Equal(t, 3, result.Qty)
+		assert.Equal(t, 7.0, result.Peak)
+		assert.Equal(t, "lama", result.Name)
+		assert.Equal(t, 3, result.Orders.Count())
+		assert.Equal(t, 5, result.Rank)
+
+		data.Name = "baru"
+		assert.Equal(t, "baru", agg.Merge(old, data).Name)
+	})
+
+	t.Run("key dan negate", func(t *testing.T) {
+		data := &taggedMetric{Day: "2025-08-01", TeamID: 3, Amount: 5, Qty: 2, Peak: 4}
+		assert.Equal(t, "metric/tagged/2025-08-01/3", agg.Key(data))
+		assert.Equal(t, "metric/month_tagged/2025-08-01/3", agg.KeyWith("metric/month_tagged", data))
+
+		neg := agg.Negate(data)
+		assert.Equal(t, -5.0, neg.Amount)
+		assert.Equal(t, -2, neg.Qty)
+		assert.Equal(t, 4.0, neg.Peak)
+		assert.Equal(t, 5.0, data.Amount)
+	})
+
+	t.Run("field angka tanpa rule", func(t *testing.T) {
+		_, err := metric.NewAgg[untaggedMetric]("metric/untagged", "Day")
+		assert.ErrorIs(t, err, metric.ErrAggRule)
+		assert.Contains(t, err.Error(), "untaggedMetric.Fee")
+	})
+
+	t.Run("projection tanpa sumber", func(t *testing.T) {
+		target := metric.MustAgg[projectedMetric]("metric/projected", "Day")
+		_, err := metric.NewProjection[untaggedMetric](target)
+		assert.Nil(t, err)
+
+		_, err = metric.NewProjection[taggedMetric](target)
+		assert.ErrorIs(t, err, metric.ErrAggRule)
+
+		proj := metric.MustProjection[untaggedMetric](target)
+		result := proj.Apply(&untaggedMetric{Day: "2025-08-01", Amount: 1, Fee: 2})
+		assert.Equal(t, &projectedMetric{Day: "2025-08-01", Amount: 1, Fee: 2}, result)
+	})
+}
+
+type lastMetric struct {
+	ID     uint    `json:"id"`
+	Amount float64 `json:"amount" agg:"sum"`
+	Status string  `json:"status" agg:"last"`
+}
+
+var lastAgg = metric.MustAgg[lastMetric]("metric/last", "ID")
+
+func (l *lastMetric) Key() string {
+	return lastAgg.Key(l)
+}
+
+func (l *lastMetric) Merge(data interface{}) metric.MetricData {
+	if data == nil {
+		return l
+	}
+	return lastAgg.Merge(l, data.(*lastMetric))
+}
+
+func TestAggLastStore(t *testing.T) {
+	var db db_mock.BadgeDBMock
+	moretest.Suite(t, "testing agg last di metric store",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&db),
+		},
+		func(t *testing.T) {
+			met := metric.NewDefaultMetricStore(db.DB, func() *lastMetric {
+				return &lastMetric{}
+			}, nil)
+			merge := func(status string, amount float64) {
+				item := &lastMetric{ID: 1, Amount: amount, Status: status}
+				err := met.Merge(item.Key(), func(acc *lastMetric) *lastMetric {
+					if acc == nil {
+						return item
+					}
+					return acc.Merge(item).(*lastMetric)
+				})
+				assert.Nil(t, err)
+			}
+			collect := func() *lastMetric {
+				var result *lastMetric
+				err := met.FlushCallback(func(acc any) error {
+					result = acc.(*lastMetric)
+					return nil
+				})
+				assert.Nil(t, err)
+				return result
+			}
+
+			merge("created", 1)
+			merge("paid", 2)
+			assert.Equal(t, &lastMetric{ID: 1, Amount: 3, Status: "paid"}, collect())
+
+			// nilai tersimpan lebih lama dari delta berikutnya
+			merge("shipped", 1)
+			merge("", 1)
+			assert.Equal(t, &lastMetric{ID: 1, Amount: 5, Status: "shipped"}, collect())
+
+			changes := []*lastMetric{}
+			merge("done", 1)
+			met.Change(func(acc *lastMetric) {
+				changes = append(changes, acc)
+			})
+			total, err := met.Commit(db.DB, changes[0])
+			assert.Nil(t, err)
+			assert.Equal(t, "done", total.Status)
+			assert.Equal(t, 6.0, total.Amount)
+		},
+	)
+}
@@ -3,14 +3,23 @@ package metric
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_replica"
 )
 
 type MetricData interface {
 	Key() string
-	Merge(dold interface{}) MetricData
+	// Merge folds the newer value data into the receiver, receiver adalah accumulator yang lebih lama.
+	// Hasil dipakai sebagai accumulator baru, receiver boleh diubah.
+	Merge(data interface{}) MetricData
 }
 type MetricFlush interface {
 	Flush(toChan chan any)
@@ -27,16 +36,44 @@ type MetricStore[R MetricData] interface {
 	Output(data R) R
 	EmptyAccumulator() R
 	Merge(key string, merger func(acc R) R) error
+	// MergeAt merges like Merge but skips a message whose effect on key was already recorded.
+	MergeAt(cdata *stat_replica.CdcMessage, key string, merger func(acc R) R) error
+	// MergeFrom merges a value derived from the delta at origin, skip kalau efeknya sudah tercatat.
+	// Origin kosong sama dengan Merge.
+	MergeFrom(origin Origin, key string, merger func(acc R) R) error
+	// Change hands out the accumulated deltas, delta tetap di wal sampai di Commit atau Ack.
 	Change(handle func(acc R))
+	// Commit folds an accumulator from Change into the stored value and drops its wal entry in one transaction.
+	Commit(db *badger.DB, data R) (R, error)
+	// Ack drops the wal entry of an accumulator from Change without folding, dipakai kalau delta
+	// disimpan oleh consumer sendiri.
+	Ack(data R) error
+	// SetRetention sets the ttl of the applied markers written by MergeAt dan MergeFrom.
+	SetRetention(retention *exact_one.Retention)
+	// SetWatermark enables finality, Output menandai data yang windownya sudah final. Watermark maju
+	// dengan commit time pesan MergeAt, backfill dan merge tanpa pesan tidak memajukan watermark.
+	SetWatermark(watermark *Watermark) error
+	Watermark() *Watermark
 	Name() string
 }
 
+// defaultMetricStore writes every merge to a write ahead log in badger before it is acknowledged,
+// jadi delta yang belum di flush tidak hilang saat crash. Wal dibagi per generasi, satu generasi
+// untuk setiap Change atau flush.
 type defaultMetricStore[R MetricData] struct {
 	sync.Mutex
 	db     *badger.DB
 	data   map[string]R
-	cacc   func() R
-	output func(r R) R
+	gen    uint64
+	loaded bool
+	// wal proses sebelumnya per generasi, dikirim ulang dengan generasi asalnya supaya origin tetap sama
+	recovered []*walBatch[R]
+	// generasi accumulator yang sudah keluar dari Change, urut per key
+	pending   map[string][]uint64
+	cacc      func() R
+	output    func(r R) R
+	watermark *Watermark
+	retention *exact_one.Retention
 }
 
 func (d *defaultMetricStore[R]) Name() string {
@@ -44,7 +81,14 @@ func (d *defaultMetricStore[R]) Name() string {
 }
 
 func (d *defaultMetricStore[R]) Output(data R) R {
-	return d.output(data)
+	data = d.output(data)
+	if d.watermark == nil {
+		return data
+	}
+	if fdata, ok := any(data).(FinalData); ok {
+		fdata.SetFinal(d.watermark.Final(fdata.WindowTime()))
+	}
+	return data
 }
 
 func (d *defaultMetricStore[R]) ToSlice() []R {
@@ -61,126 +105,627 @@ func (d *defaultMetricStore[R]) EmptyAccumulator() R {
 	return d.cacc()
 }
 
+func (d *defaultMetricStore[R]) walPrefix() string {
+	return "metric_wal/" + d.Name() + "/"
+}
+
+func (d *defaultMetricStore[R]) walKey(gen uint64, key string) []byte {
+	return []byte(fmt.Sprintf("%s%016X/%s", d.walPrefix(), gen, key))
+}
+
+func (d *defaultMetricStore[R]) genKey() []byte {
+	return []byte("metric_gen/" + d.Name())
+}
+
+func (d *defaultMetricStore[R]) outboxPrefix() string {
+	return "metric_outbox/" + d.Name() + "/"
+}
+
+// walBatch is the deltas of one wal generation.
+type walBatch[R MetricData] struct {
+	gen   uint64
+	datas map[string]R
+}
+
+// load recovers deltas left in the wal by the previous process. Generasi lama tidak digabung, origin
+// delta yang dikirim ulang harus sama dengan sebelum crash. Harus dipanggil dengan lock.
+func (d *defaultMetricStore[R]) load() error {
+	if d.loaded {
+		return nil
+	}
+
+	prefix := d.walPrefix()
+	var keys int
+	err := d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(d.genKey())
+		switch {
+		case err == nil:
+			err = item.Value(func(val []byte) error {
+				gen, err := strconv.ParseUint(string(val), 16, 64)
+				d.gen = gen
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		var batch *walBatch[R]
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			gen, key, err := parseWalKey(prefix, string(item.Key()))
+			if err != nil {
+				return err
+			}
+
+			acc := d.EmptyAccumulator()
+			err = item.Value(func(val []byte) error {
+				return json.Unmarshal(val, acc)
+			})
+			if err != nil {
+				return err
+			}
+
+			if batch == nil || batch.gen != gen {
+				batch = &walBatch[R]{gen: gen, datas: map[string]R{}}
+				d.recovered = append(d.recovered, batch)
+			}
+			batch.datas[key] = acc
+			keys += 1
+			if gen >= d.gen {
+				d.gen = gen + 1
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if d.gen == 0 {
+		d.gen = 1
+	}
+	// metric_gen adalah generasi pertama yang belum pernah dipakai, generasi sekarang langsung dipakai merge
+	err = d.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(d.genKey(), []byte(strconv.FormatUint(d.gen+1, 16)))
+	})
+	if err != nil {
+		return err
+	}
+	if keys > 0 {
+		slog.Info("recovered metric wal", slog.String("metric", d.Name()), slog.Int("keys", keys))
+	}
+
+	d.loaded = true
+	return nil
+}
+
+func parseWalKey(prefix, walKey string) (uint64, string, error) {
+	raw := strings.TrimPrefix(walKey, prefix)
+	genHex, key, ok := strings.Cut(raw, "/")
+	if !ok || len(genHex) != 16 {
+		return 0, "", fmt.Errorf("invalid metric wal key %s", walKey)
+	}
+	gen, err := strconv.ParseUint(genHex, 16, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid metric wal key %s: %w", walKey, err)
+	}
+	return gen, key, nil
+}
+
 func (d *defaultMetricStore[R]) FlushCallback(handle func(acc any) error) error {
-	d.Lock()
-	datas := d.data
-	d.data = map[string]R{}
-	d.Unlock()
-
-	var err error
-	for _, data := range datas {
-		var old R
-		old, err = d.getItem(data.Key())
+	err := d.redeliver(handle)
+	if err != nil {
+		return err
+	}
+
+	batches, err := d.take(false)
+	if err != nil {
+		return err
+	}
+
+	for i, batch := range batches {
+		totals, err := d.fold(batch.datas, batch.gen)
 		if err != nil {
-			if !errors.Is(err, badger.ErrKeyNotFound) {
+			for key := range totals {
+				delete(batch.datas, key)
+			}
+			for _, rest := range batches[i+1:] {
+				err = errors.Join(err, d.putBack(rest.datas, rest.gen))
+			}
+			return errors.Join(err, d.putBack(batch.datas, batch.gen))
+		}
+
+		for key, total := range totals {
+			err = handle(d.Output(total))
+			if err != nil {
+				return err
+			}
+			err = d.db.Update(func(txn *badger.Txn) error {
+				return txn.Delete([]byte(d.outboxPrefix() + key))
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// redeliver sends totals whose delivery did not finish, sink bisa menerima data yang sama lebih dari sekali.
+func (d *defaultMetricStore[R]) redeliver(handle func(acc any) error) error {
+	prefix := d.outboxPrefix()
+	keys := []string{}
+	err := d.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			keys = append(keys, string(iter.Item().Key()))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, outboxKey := range keys {
+		total := d.EmptyAccumulator()
+		found := true
+		err = d.db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get([]byte(outboxKey))
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					found = false
+					return nil
+				}
 				return err
 			}
+			return item.Value(func(val []byte) error {
+				return json.Unmarshal(val, total)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
 		}
-		newd := data.Merge(old)
-		err = d.setItem(newd.Key(), newd.(R))
+
+		err = handle(d.Output(total))
 		if err != nil {
 			return err
 		}
-		data = d.output(newd.(R))
-		err = handle(data)
+		err = d.db.Update(func(txn *badger.Txn) error {
+			return txn.Delete([]byte(outboxKey))
+		})
 		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-// Flush implements MetricStore.
-func (d *defaultMetricStore[R]) Flush(toChan chan any) { // masih ngebug
+// foldBatch is the number of keys folded in one transaction, diperkecil kalau transaksi terlalu besar.
+const foldBatch = 512
+
+// fold merges the deltas of one generation into the stored values in batched transactions, setiap total
+// juga ditulis ke outbox sampai terkirim ke sink.
+func (d *defaultMetricStore[R]) fold(datas map[string]R, gen uint64) (map[string]R, error) {
+	keys := make([]string, 0, len(datas))
+	for key := range datas {
+		keys = append(keys, key)
+	}
+
+	totals := map[string]R{}
+	size := foldBatch
+	for len(keys) > 0 {
+		chunk := keys[:min(size, len(keys))]
+		chunkTotals := map[string]R{}
+		err := d.db.Update(func(txn *badger.Txn) error {
+			for _, key := range chunk {
+				total, err := d.mergeStored(txn, key, datas[key])
+				if err != nil {
+					return err
+				}
+				raw, err := json.Marshal(total)
+				if err != nil {
+					return err
+				}
+				err = txn.Set([]byte(key), raw)
+				if err != nil {
+					return err
+				}
+				err = txn.Delete(d.walKey(gen, key))
+				if err != nil {
+					return err
+				}
+				err = txn.Set([]byte(d.outboxPrefix()+key), raw)
+				if err != nil {
+					return err
+				}
+				chunkTotals[key] = total
+			}
+			return nil
+		})
+		if errors.Is(err, badger.ErrTxnTooBig) && size > 1 {
+			size = size / 2
+			continue
+		}
+		if err != nil {
+			return totals, err
+		}
+
+		for key, total := range chunkTotals {
+			totals[key] = total
+		}
+		keys = keys[len(chunk):]
+	}
+
+	return totals, nil
+}
+
+// putBack returns deltas that failed to fold to the current generation, wal lama dipindah di transaksi yang sama.
+func (d *defaultMetricStore[R]) putBack(datas map[string]R, gen uint64) error {
 	d.Lock()
-	datas := d.data
-	d.data = map[string]R{}
-	d.Unlock()
+	defer d.Unlock()
 
-	for _, data := range datas {
-		data = d.output(data)
-		toChan <- data
+	for key, data := range datas {
+		acc := data
+		if exist, ok := d.data[key]; ok {
+			// delta generasi lama lebih dulu dari yang ada di generasi sekarang
+			acc = data.Merge(exist).(R)
+		}
+		err := d.db.Update(func(txn *badger.Txn) error {
+			raw, err := json.Marshal(acc)
+			if err != nil {
+				return err
+			}
+			err = txn.Set(d.walKey(d.gen, key), raw)
+			if err != nil {
+				return err
+			}
+			return txn.Delete(d.walKey(gen, key))
+		})
+		if err != nil {
+			return err
+		}
+		d.data[key] = acc
 	}
+	return nil
+}
 
+func (d *defaultMetricStore[R]) mergeStored(txn *badger.Txn, key string, data R) (R, error) {
+	old := d.EmptyAccumulator()
+	item, err := txn.Get([]byte(key))
+	switch {
+	case err == nil:
+		err = item.Value(func(val []byte) error {
+			return json.Unmarshal(val, old)
+		})
+		if err != nil {
+			return old, err
+		}
+	case errors.Is(err, badger.ErrKeyNotFound):
+		// salinan delta, delta tidak diubah karena bisa dipakai stage lain
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return old, err
+		}
+		return old, json.Unmarshal(raw, old)
+	default:
+		return old, err
+	}
+
+	return old.Merge(data).(R), nil
 }
 
 // Flush implements MetricStore.
+func (d *defaultMetricStore[R]) Flush(toChan chan any) {
+	err := d.FlushCallback(func(acc any) error {
+		toChan <- acc
+		return nil
+	})
+	if err != nil {
+		slog.Error(err.Error(), slog.String("metric", d.Name()))
+	}
+}
+
+// Change implements MetricStore.
 func (d *defaultMetricStore[R]) Change(handle func(acc R)) {
+	batches, err := d.take(true)
+	if err != nil {
+		slog.Error(err.Error(), slog.String("metric", d.Name()))
+		return
+	}
+
+	for _, batch := range batches {
+		for key, data := range batch.datas {
+			if odata, ok := any(data).(OriginData); ok {
+				odata.SetOrigin(Origin{Store: d.Name(), Gen: batch.gen, Key: key})
+			}
+			handle(data)
+		}
+	}
+}
+
+// take empties the accumulators and starts a new wal generation, pending untuk Commit dan Ack.
+// Wal yang dipulihkan ikut di depan, urut generasi.
+func (d *defaultMetricStore[R]) take(pending bool) ([]*walBatch[R], error) {
 	d.Lock()
-	datas := d.data
-	d.data = map[string]R{}
-	d.Unlock()
+	defer d.Unlock()
 
-	for _, data := range datas {
-		handle(data)
+	err := d.load()
+	if err != nil {
+		return nil, err
 	}
 
+	batches := d.recovered
+	d.recovered = nil
+	if len(d.data) > 0 {
+		// generasi berikutnya dicatat terpakai dulu, origin tidak boleh dipakai ulang setelah restart
+		err = d.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(d.genKey(), []byte(strconv.FormatUint(d.gen+2, 16)))
+		})
+		if err != nil {
+			d.recovered = batches
+			return nil, err
+		}
+
+		batches = append(batches, &walBatch[R]{gen: d.gen, datas: d.data})
+		d.data = map[string]R{}
+		d.gen += 1
+	}
+
+	if pending {
+		for _, batch := range batches {
+			for key := range batch.datas {
+				d.pending[key] = append(d.pending[key], batch.gen)
+			}
+		}
+	}
+	return batches, nil
 }
 
-func (d *defaultMetricStore[R]) getItem(key string) (R, error) {
-	acc := d.EmptyAccumulator()
+func (d *defaultMetricStore[R]) popPending(key string) (uint64, bool) {
+	d.Lock()
+	defer d.Unlock()
 
-	err := d.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(key))
+	gens := d.pending[key]
+	if len(gens) == 0 {
+		return 0, false
+	}
+	if len(gens) == 1 {
+		delete(d.pending, key)
+	} else {
+		d.pending[key] = gens[1:]
+	}
+	return gens[0], true
+}
+
+// Commit implements MetricStore.
+func (d *defaultMetricStore[R]) Commit(db *badger.DB, data R) (R, error) {
+	key := data.Key()
+	gen, ok := d.popPending(key)
+
+	var result R
+	err := db.Update(func(txn *badger.Txn) error {
+		var err error
+		result, err = d.mergeStored(txn, key, data)
 		if err != nil {
 			return err
 		}
-		val, err := item.ValueCopy(nil)
+		raw, err := json.Marshal(result)
 		if err != nil {
 			return err
 		}
-		return json.Unmarshal(val, acc)
+		err = txn.Set([]byte(key), raw)
+		if err != nil || !ok {
+			return err
+		}
+		return txn.Delete(d.walKey(gen, key))
+	})
+
+	return result, err
+}
+
+// Ack implements MetricStore.
+func (d *defaultMetricStore[R]) Ack(data R) error {
+	key := data.Key()
+	gen, ok := d.popPending(key)
+	if !ok {
+		return nil
+	}
+	return d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(d.walKey(gen, key))
 	})
+}
 
-	return acc, err
+// Merge implements MetricStore.
+func (d *defaultMetricStore[R]) Merge(key string, merger func(acc R) R) error {
+	return d.merge(nil, Origin{}, key, merger)
 }
 
-func (d *defaultMetricStore[R]) setItem(key string, data R) error {
-	var err error
+// MergeAt implements MetricStore.
+func (d *defaultMetricStore[R]) MergeAt(cdata *stat_replica.CdcMessage, key string, merger func(acc R) R) error {
+	return d.merge(cdata, Origin{}, key, merger)
+}
+
+// MergeFrom implements MetricStore.
+func (d *defaultMetricStore[R]) MergeFrom(origin Origin, key string, merger func(acc R) R) error {
+	return d.merge(nil, origin, key, merger)
+}
+
+// merge writes the new accumulator to the wal before it replaces the one in memory, marker posisi
+// sumber atau origin delta ikut di transaksi yang sama. Merger menerima salinan accumulator, memory dan
+// watermark baru berubah setelah transaksi berhasil.
+func (d *defaultMetricStore[R]) merge(cdata *stat_replica.CdcMessage, origin Origin, key string, merger func(acc R) R) error {
+	d.Lock()
+	defer d.Unlock()
+
+	err := d.load()
+	if err != nil {
+		return err
+	}
+
+	var pos stat_replica.Position
+	var havePos bool
+	if cdata != nil {
+		pos, havePos = cdata.Position()
+	}
+
+	at, advance := d.commitTime(cdata)
+
+	var newacc R
+	var applied bool
 	err = d.db.Update(func(txn *badger.Txn) error {
-		var raw []byte
-		raw, err = json.Marshal(data)
+		var err error
+		switch {
+		case havePos:
+			applied, err = exact_one.IsApplied(txn, pos, key)
+		case !origin.IsZero():
+			applied, err = exact_one.IsDeltaApplied(txn, origin.String(), key)
+		}
+		if err != nil || applied {
+			return err
+		}
+
+		acc, ok := d.data[key]
+		if ok {
+			acc, err = d.clone(acc)
+			if err != nil {
+				return err
+			}
+		}
+		newacc = merger(acc)
+		raw, err := json.Marshal(newacc)
 		if err != nil {
 			return err
 		}
-		err = txn.Set([]byte(key), raw)
-		return err
+		if advance {
+			rawAt, err := at.MarshalText()
+			if err != nil {
+				return err
+			}
+			err = txn.Set(d.watermarkKey(), rawAt)
+			if err != nil {
+				return err
+			}
+		}
+		err = txn.Set(d.walKey(d.gen, key), raw)
+		if err != nil {
+			return err
+		}
+		switch {
+		case havePos:
+			return exact_one.SetApplied(txn, d.retention, pos, key, nil)
+		case !origin.IsZero():
+			return exact_one.SetDeltaApplied(txn, d.retention, origin.String(), key, nil)
+		}
+		return nil
 	})
+	if err != nil || applied {
+		return err
+	}
 
-	return err
+	d.data[key] = newacc
+	d.observe(newacc, at, advance)
+	return nil
 }
 
-// Merge implements MetricStore.
-func (d *defaultMetricStore[R]) Merge(key string, merger func(acc R) R) error {
+// clone copies acc through json, sama seperti wal, supaya merger tidak mengubah accumulator di memory.
+func (d *defaultMetricStore[R]) clone(acc R) (R, error) {
+	result := d.EmptyAccumulator()
+	raw, err := json.Marshal(acc)
+	if err != nil {
+		return result, err
+	}
+	return result, json.Unmarshal(raw, result)
+}
+
+// SetRetention implements MetricStore.
+func (d *defaultMetricStore[R]) SetRetention(retention *exact_one.Retention) {
 	d.Lock()
 	defer d.Unlock()
+	d.retention = retention
+}
 
-	// var err error
-	var acc R
-	// var ok bool
-
-	acc, _ = d.data[key]
-	// log.Println(acc, ok, "asdasdasdasdasd")
-	// if !ok {
-	// 	acc, err = d.getItem(key)
-	// 	if err != nil {
-	// 		if !errors.Is(err, badger.ErrKeyNotFound) {
-	// 			return err
-	// 		}
-	// 	}
-	// }
-
-	newacc := merger(acc)
-	// log.Println(newacc, ok, "after")
-	// if err != nil {
-	// 	return err
-	// }
+func (d *defaultMetricStore[R]) watermarkKey() []byte {
+	return []byte("metric_watermark/" + d.Name())
+}
 
-	d.data[key] = newacc
+// SetWatermark implements MetricStore.
+func (d *defaultMetricStore[R]) SetWatermark(watermark *Watermark) error {
+	err := d.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(d.watermarkKey())
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			var current time.Time
+			err := current.UnmarshalText(val)
+			if err != nil {
+				return err
+			}
+			watermark.Observe(current)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.watermark = watermark
 	return nil
 }
 
+// Watermark implements MetricStore.
+func (d *defaultMetricStore[R]) Watermark() *Watermark {
+	return d.watermark
+}
+
+// commitTime returns the commit time of cdata and whether it moves the watermark. Event time tidak dipakai,
+// backfill yang tidak urut hari akan memfinalkan hari yang belum selesai. Backfill tidak punya commit time
+// jadi watermark tertahan sampai stream berjalan.
+func (d *defaultMetricStore[R]) commitTime(cdata *stat_replica.CdcMessage) (time.Time, bool) {
+	if d.watermark == nil || cdata == nil || cdata.CommitTimestamp == 0 {
+		return time.Time{}, false
+	}
+	at := time.UnixMicro(cdata.CommitTimestamp)
+	return at, at.After(d.watermark.Current())
+}
+
+// observe counts late merges and moves the watermark to at, dipanggil setelah merge tersimpan.
+func (d *defaultMetricStore[R]) observe(acc R, at time.Time, advance bool) {
+	if d.watermark == nil {
+		return
+	}
+
+	if fdata, ok := any(acc).(FinalData); ok && d.watermark.Final(fdata.WindowTime()) {
+		d.watermark.addLate()
+		slog.Warn("late metric data", slog.String("metric", d.Name()), slog.String("key", acc.Key()))
+	}
+	if advance {
+		d.watermark.Observe(at)
+	}
+}
+
 func NewDefaultMetricStore[R MetricData](db *badger.DB, emptyAcc func() R, output func(R) R) MetricStore[R] {
 	if output == nil {
 		output = func(r R) R {
@@ -188,9 +733,10 @@ func NewDefaultMetricStore[R MetricData](db *badger.DB, emptyAcc func() R, outpu
 		}
 	}
 	return &defaultMetricStore[R]{
-		db:     db,
-		data:   map[string]R{},
-		cacc:   emptyAcc,
-		output: output,
+		db:      db,
+		data:    map[string]R{},
+		pending: map[string][]uint64{},
+		cacc:    emptyAcc,
+		output:  output,
 	}
 }
@@ -2,6 +2,7 @@ package metric_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"testing"
@@ -16,6 +17,8 @@ type Tcount struct {
 	ID     uint    `json:"id"`
 	Day    string  `json:"day"`
 	Amount float64 `json:"amount"`
+
+	metric.DeltaOrigin
 }
 
 // Merge implements metric.MetricData.
@@ -76,3 +79,175 @@ func TestMetricStore(t *testing.T) {
 		},
 	)
 }
+
+func mergeCount(met metric.MetricStore[*Tcount], id uint, amount float64) error {
+	return met.Merge(fmt.Sprintf("%d", id), func(acc *Tcount) *Tcount {
+		if acc == nil {
+			return &Tcount{ID: id, Amount: amount}
+		}
+		acc.Amount += amount
+		return acc
+	})
+}
+
+func TestMetricStoreWal(t *testing.T) {
+	var db db_mock.BadgeDBMock
+	newStore := func() metric.MetricStore[*Tcount] {
+		return metric.NewDefaultMetricStore(db.DB, func() *Tcount {
+			return &Tcount{}
+		}, nil)
+	}
+	collect := func(met metric.MetricStore[*Tcount]) (map[uint]float64, error) {
+		result := map[uint]float64{}
+		err := met.FlushCallback(func(acc any) error {
+			data := acc.(*Tcount)
+			result[data.ID] = data.Amount
+			return nil
+		})
+		return result, err
+	}
+
+	moretest.Suite(t, "testing metric store write ahead log",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&db),
+		},
+		func(t *testing.T) {
+			t.Run("crash sebelum flush delta tidak hilang", func(t *testing.T) {
+				met := newStore()
+				assert.Nil(t, mergeCount(met, 1, 2))
+				assert.Nil(t, mergeCount(met, 1, 3))
+				assert.Nil(t, mergeCount(met, 2, 1))
+
+				// proses mati, store baru di db yang sama
+				recovered := newStore()
+				assert.Nil(t, mergeCount(recovered, 2, 4))
+
+				result, err := collect(recovered)
+				assert.Nil(t, err)
+				assert.Equal(t, map[uint]float64{1: 5, 2: 5}, result)
+
+				// wal sudah kosong setelah di fold
+				result, err = collect(newStore())
+				assert.Nil(t, err)
+				assert.Empty(t, result)
+			})
+
+			t.Run("sink gagal dikirim ulang", func(t *testing.T) {
+				met := newStore()
+				assert.Nil(t, mergeCount(met, 3, 7))
+
+				err := met.FlushCallback(func(acc any) error {
+					return errors.New("sink down")
+				})
+				assert.NotNil(t, err)
+
+				result, err := collect(newStore())
+				assert.Nil(t, err)
+				assert.Equal(t, map[uint]float64{3: 7}, result)
+
+				// total tersimpan tidak dihitung dua kali
+				assert.Nil(t, mergeCount(met, 3, 1))
+				result, err = collect(met)
+				assert.Nil(t, err)
+				assert.Equal(t, map[uint]float64{3: 8}, result)
+			})
+
+			t.Run("change belum di commit dipulihkan", func(t *testing.T) {
+				met := newStore()
+				assert.Nil(t, mergeCount(met, 4, 2))
+				assert.Nil(t, mergeCount(met, 5, 3))
+
+				changes := []*Tcount{}
+				met.Change(func(acc *Tcount) {
+					changes = append(changes, acc)
+				})
+				assert.Len(t, changes, 2)
+
+				for _, change := range changes {
+					if change.ID != 4 {
+						continue
+					}
+					total, err := met.Commit(db.DB, change)
+					assert.Nil(t, err)
+					assert.Equal(t, float64(2), total.Amount)
+				}
+
+				// id 5 belum di commit waktu crash
+				recovered := newStore()
+				changes = []*Tcount{}
+				recovered.Change(func(acc *Tcount) {
+					changes = append(changes, acc)
+				})
+				assert.Len(t, changes, 1)
+				assert.Equal(t, uint(5), changes[0].ID)
+
+				total, err := recovered.Commit(db.DB, changes[0])
+				assert.Nil(t, err)
+				assert.Equal(t, float64(3), total.Amount)
+
+				changes = []*Tcount{}
+				newStore().Change(func(acc *Tcount) {
+					changes = append(changes, acc)
+				})
+				assert.Empty(t, changes)
+			})
+
+			t.Run("delta dikirim ulang dengan origin yang sama", func(t *testing.T) {
+				met := newStore()
+				target := metric.NewDefaultMetricStore(db.DB, func() *Tcount {
+					return &Tcount{ID: 99}
+				}, nil)
+				assert.Nil(t, mergeCount(met, 6, 2))
+				assert.Nil(t, mergeCount(met, 7, 1))
+
+				apply := func(changes []*Tcount) {
+					for _, change := range changes {
+						item := &Tcount{ID: 100, Amount: change.Amount}
+						err := target.MergeFrom(change.Origin(), item.Key(), func(acc *Tcount) *Tcount {
+							if acc == nil {
+								return item
+							}
+							acc.Amount += item.Amount
+							return acc
+						})
+						assert.Nil(t, err)
+					}
+				}
+
+				first := []*Tcount{}
+				met.Change(func(acc *Tcount) {
+					first = append(first, acc)
+				})
+				assert.Len(t, first, 2)
+				apply(first)
+
+				// crash sebelum commit, delta yang sama keluar lagi dengan generasi asalnya
+				assert.Nil(t, mergeCount(met, 6, 5))
+				recovered := newStore()
+				again := []*Tcount{}
+				recovered.Change(func(acc *Tcount) {
+					again = append(again, acc)
+				})
+				assert.Len(t, again, 3)
+				apply(again)
+
+				result, err := collect(target)
+				assert.Nil(t, err)
+				assert.Equal(t, map[uint]float64{100: 8}, result)
+
+				// generasi baru setelah restart tidak memakai ulang origin lama
+				for _, change := range again {
+					_, err := recovered.Commit(db.DB, change)
+					assert.Nil(t, err)
+				}
+				restarted := newStore()
+				assert.Nil(t, mergeCount(restarted, 6, 4))
+				restarted.Change(func(acc *Tcount) {
+					for _, prev := range again {
+						assert.Greater(t, acc.Origin().Gen, prev.Origin().Gen)
+					}
+				})
+			})
+		},
+	)
+}
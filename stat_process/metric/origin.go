@@ -0,0 +1,37 @@
+package metric
+
+import "fmt"
+
+// Origin identifies one delta handed out by Change, delta yang dikirim ulang setelah crash punya origin yang sama.
+type Origin struct {
+	Store string
+	Gen   uint64
+	Key   string
+}
+
+func (o Origin) IsZero() bool {
+	return o.Store == ""
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%s/%016X/%s", o.Store, o.Gen, o.Key)
+}
+
+// OriginData is a metric that carries the origin of the delta, dipakai MergeFrom di stage turunan.
+type OriginData interface {
+	Origin() Origin
+	SetOrigin(origin Origin)
+}
+
+// DeltaOrigin is embedded in metric data to implement OriginData, tidak ikut disimpan.
+type DeltaOrigin struct {
+	origin Origin
+}
+
+func (d *DeltaOrigin) Origin() Origin {
+	return d.origin
+}
+
+func (d *DeltaOrigin) SetOrigin(origin Origin) {
+	d.origin = origin
+}
@@ -0,0 +1,76 @@
+package metric
+
+import (
+	"github.com/pdcgo/materialize/stat_replica"
+)
+
+// RetractData is a metric whose contribution can be retracted.
+type RetractData[R MetricData] interface {
+	MetricData
+	// Negate returns a copy with every amount negated
+	Negate() R
+}
+
+// Contribute maps one row to the metric items it adds, nil row berarti tidak ada row.
+type Contribute[R MetricData] func(row any) ([]R, error)
+
+// MergeRetract merges -contribute(OldData) and +contribute(Data) of one message, netted per key.
+// Delete hanya menarik row lama. Merge memakai MergeAt jadi aman saat replay.
+func MergeRetract[R RetractData[R]](store MetricStore[R], cdata *stat_replica.CdcMessage, contribute Contribute[R]) ([]R, error) {
+	oldRow, newRow := cdata.OldData, cdata.Data
+	if cdata.ModType == stat_replica.CdcDelete {
+		if oldRow == nil {
+			oldRow = newRow
+		}
+		newRow = nil
+	}
+
+	keys := []string{}
+	net := map[string]R{}
+	add := func(item R) {
+		key := item.Key()
+		exist, ok := net[key]
+		if !ok {
+			keys = append(keys, key)
+			net[key] = item
+			return
+		}
+		net[key] = exist.Merge(item).(R)
+	}
+
+	if !isNilData(oldRow) {
+		items, err := contribute(oldRow)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			add(item.Negate())
+		}
+	}
+
+	if !isNilData(newRow) {
+		items, err := contribute(newRow)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			add(item)
+		}
+	}
+
+	result := make([]R, 0, len(keys))
+	for _, key := range keys {
+		item := net[key]
+		err := store.MergeAt(cdata, key, func(acc R) R {
+			if isNilData(acc) {
+				return item
+			}
+			return acc.Merge(item).(R)
+		})
+		if err != nil {
+			return result, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,277 @@
+package metric_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/exact_one"
+	"github.com/pdcgo/materialize/stat_process/metric"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+type Tday struct {
+	Day    string  `json:"day"`
+	Amount float64 `json:"amount"`
+	Final  bool    `json:"final"`
+}
+
+// Merge implements metric.MetricData.
+func (t *Tday) Merge(dold interface{}) metric.MetricData {
+	if dold == nil {
+		return t
+	}
+	t.Amount += dold.(*Tday).Amount
+	return t
+}
+
+// Key implements metric.MetricData.
+func (t *Tday) Key() string {
+	return fmt.Sprintf("tday/%s", t.Day)
+}
+
+// Negate implements metric.RetractData.
+func (t *Tday) Negate() *Tday {
+	return &Tday{Day: t.Day, Amount: -t.Amount}
+}
+
+// WindowTime implements metric.FinalData.
+func (t *Tday) WindowTime() time.Time {
+	day, _ := metric.ParseDay(t.Day)
+	return day
+}
+
+// SetFinal implements metric.FinalData.
+func (t *Tday) SetFinal(final bool) {
+	t.Final = final
+}
+
+type trow struct {
+	day    string
+	amount float64
+}
+
+func tdayContribution(row any) ([]*Tday, error) {
+	data := row.(*trow)
+	return []*Tday{{Day: data.day, Amount: data.amount}}, nil
+}
+
+func TestMergeRetract(t *testing.T) {
+	var db db_mock.BadgeDBMock
+	moretest.Suite(t, "testing retraction",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&db),
+		},
+		func(t *testing.T) {
+			met := metric.NewDefaultMetricStore(db.DB, func() *Tday {
+				return &Tday{}
+			}, nil)
+
+			collect := func() map[string]float64 {
+				result := map[string]float64{}
+				err := met.FlushCallback(func(acc any) error {
+					data := acc.(*Tday)
+					result[data.Day] = data.Amount
+					return nil
+				})
+				assert.Nil(t, err)
+				return result
+			}
+
+			var seq uint32
+			message := func(mod stat_replica.ModificationType, old, data *trow) *stat_replica.CdcMessage {
+				seq += 1
+				cdata := &stat_replica.CdcMessage{
+					ModType:   mod,
+					CommitLSN: pglogrepl.LSN(0x100),
+					Seq:       seq,
+					Data:      data,
+				}
+				if old != nil {
+					cdata.OldData = old
+				}
+				return cdata
+			}
+
+			_, err := metric.MergeRetract(met, message(stat_replica.CdcInsert, nil, &trow{"2025-08-01", 100}), tdayContribution)
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]float64{"2025-08-01": 100}, collect())
+
+			t.Run("hari pindah ditarik dari hari lama", func(t *testing.T) {
+				cdata := message(stat_replica.CdcUpdate, &trow{"2025-08-01", 100}, &trow{"2025-08-03", 120})
+				items, err := metric.MergeRetract(met, cdata, tdayContribution)
+				assert.Nil(t, err)
+				assert.Len(t, items, 2)
+				assert.Equal(t, map[string]float64{"2025-08-01": 0, "2025-08-03": 120}, collect())
+
+				// replay pesan yang sama tidak dihitung lagi
+				_, err = metric.MergeRetract(met, cdata, tdayContribution)
+				assert.Nil(t, err)
+				assert.Empty(t, collect())
+			})
+
+			t.Run("hari sama hanya selisih", func(t *testing.T) {
+				items, err := metric.MergeRetract(met, message(stat_replica.CdcUpdate, &trow{"2025-08-03", 120}, &trow{"2025-08-03", 150}), tdayContribution)
+				assert.Nil(t, err)
+				assert.Len(t, items, 1)
+				assert.Equal(t, float64(30), items[0].Amount)
+				assert.Equal(t, map[string]float64{"2025-08-03": 150}, collect())
+			})
+
+			t.Run("marker memakai retention", func(t *testing.T) {
+				met.SetRetention(exact_one.NewRetention(
+					&exact_one.RetentionRule{Prefix: exact_one.AppliedPrefix, TTL: time.Hour},
+				))
+				defer met.SetRetention(nil)
+
+				cdata := message(stat_replica.CdcInsert, nil, &trow{"2025-08-05", 10})
+				_, err := metric.MergeRetract(met, cdata, tdayContribution)
+				assert.Nil(t, err)
+
+				pos, _ := cdata.Position()
+				err = db.DB.View(func(txn *badger.Txn) error {
+					item, err := txn.Get(exact_one.AppliedKey(pos, "tday/2025-08-05"))
+					if err != nil {
+						return err
+					}
+					expires := time.Unix(int64(item.ExpiresAt()), 0)
+					assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+					return nil
+				})
+				assert.Nil(t, err)
+				collect()
+			})
+
+			t.Run("delete tanpa old data", func(t *testing.T) {
+				_, err := metric.MergeRetract(met, message(stat_replica.CdcDelete, nil, &trow{"2025-08-03", 150}), tdayContribution)
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]float64{"2025-08-03": 0}, collect())
+			})
+		},
+	)
+}
+
+func TestWatermark(t *testing.T) {
+	var db db_mock.BadgeDBMock
+	moretest.Suite(t, "testing watermark",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&db),
+		},
+		func(t *testing.T) {
+			newStore := func() metric.MetricStore[*Tday] {
+				met := metric.NewDefaultMetricStore(db.DB, func() *Tday {
+					return &Tday{}
+				}, nil)
+				err := met.SetWatermark(metric.NewWatermark(metric.DayWindow, time.Hour*24))
+				assert.Nil(t, err)
+				return met
+			}
+
+			var seq uint32
+			// commit kosong berarti pesan backfill
+			merge := func(met metric.MetricStore[*Tday], day string, amount float64, commit string) {
+				seq += 1
+				cdata := &stat_replica.CdcMessage{ModType: stat_replica.CdcBackfill}
+				if commit != "" {
+					at, err := time.ParseInLocation("2006-01-02T15:04", commit, metric.Jakarta)
+					assert.Nil(t, err)
+					cdata.ModType = stat_replica.CdcInsert
+					cdata.CommitLSN = pglogrepl.LSN(0x200 + seq)
+					cdata.CommitTimestamp = at.UnixMicro()
+				}
+
+				item := &Tday{Day: day, Amount: amount}
+				err := met.MergeAt(cdata, item.Key(), func(acc *Tday) *Tday {
+					if acc == nil {
+						return item
+					}
+					acc.Amount += item.Amount
+					return acc
+				})
+				assert.Nil(t, err)
+			}
+			collect := func(met metric.MetricStore[*Tday]) map[string]bool {
+				result := map[string]bool{}
+				err := met.FlushCallback(func(acc any) error {
+					data := acc.(*Tday)
+					result[data.Day] = data.Final
+					return nil
+				})
+				assert.Nil(t, err)
+				return result
+			}
+
+			met := newStore()
+
+			t.Run("backfill tidak urut tidak memfinalkan hari", func(t *testing.T) {
+				for _, day := range []string{"2025-08-05", "2025-08-01", "2025-08-04", "2025-08-02", "2025-08-03"} {
+					merge(met, day, 10, "")
+				}
+				assert.True(t, met.Watermark().Current().IsZero())
+				assert.Equal(t, map[string]bool{
+					"2025-08-01": false,
+					"2025-08-02": false,
+					"2025-08-03": false,
+					"2025-08-04": false,
+					"2025-08-05": false,
+				}, collect(met))
+				assert.Zero(t, met.Watermark().Late())
+			})
+
+			t.Run("stream memajukan watermark dengan commit time", func(t *testing.T) {
+				// lateness satu hari, 08-04 final setelah commit 08-06 siang
+				merge(met, "2025-08-04", 5, "2025-08-06T12:00")
+				merge(met, "2025-08-05", 5, "2025-08-06T12:01")
+				assert.Zero(t, met.Watermark().Late())
+
+				// koreksi ke hari yang sudah final tetap dihitung sebagai data terlambat
+				merge(met, "2025-08-04", 1, "2025-08-06T12:02")
+				assert.Equal(t, map[string]bool{"2025-08-04": true, "2025-08-05": false}, collect(met))
+				assert.Equal(t, int64(1), met.Watermark().Late())
+			})
+
+			t.Run("watermark tersimpan untuk proses berikutnya", func(t *testing.T) {
+				recovered := newStore()
+				at, _ := time.ParseInLocation("2006-01-02T15:04", "2025-08-06T12:02", metric.Jakarta)
+				assert.True(t, at.Equal(recovered.Watermark().Current()))
+
+				merge(recovered, "2025-08-05", 1, "")
+				assert.Equal(t, map[string]bool{"2025-08-05": false}, collect(recovered))
+			})
+
+			t.Run("merge gagal tidak mengubah accumulator dan watermark", func(t *testing.T) {
+				merge(met, "2025-08-07", 3, "2025-08-07T08:00")
+				before := met.Watermark().Current()
+
+				at, _ := time.ParseInLocation("2006-01-02T15:04", "2025-08-09T08:00", metric.Jakarta)
+				cdata := &stat_replica.CdcMessage{
+					ModType:         stat_replica.CdcInsert,
+					CommitLSN:       pglogrepl.LSN(0x900),
+					CommitTimestamp: at.UnixMicro(),
+				}
+				// NaN tidak bisa di marshal, transaksi merge gagal
+				err := met.MergeAt(cdata, "tday/2025-08-07", func(acc *Tday) *Tday {
+					acc.Amount = math.NaN()
+					return acc
+				})
+				assert.NotNil(t, err)
+				assert.True(t, before.Equal(met.Watermark().Current()))
+
+				amounts := map[string]float64{}
+				err = met.FlushCallback(func(acc any) error {
+					data := acc.(*Tday)
+					amounts[data.Day] = data.Amount
+					return nil
+				})
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]float64{"2025-08-07": 3}, amounts)
+			})
+		},
+	)
+}
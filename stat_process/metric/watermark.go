@@ -0,0 +1,80 @@
+package metric
+
+import (
+	"sync"
+	"time"
+)
+
+// FinalData is a metric that can be marked final by a watermark.
+type FinalData interface {
+	WindowTime() time.Time
+	SetFinal(final bool)
+}
+
+// Watermark tracks the latest source commit time merged into a metric. Window dianggap final kalau watermark
+// dikurangi allowed lateness sudah melewati akhir window, setelah itu sink tidak perlu menunggu koreksi lagi.
+type Watermark struct {
+	sync.Mutex
+	window   Window
+	lateness time.Duration
+	current  time.Time
+	late     int64
+}
+
+// Observe moves the watermark forward, return true kalau watermark berubah.
+func (w *Watermark) Observe(t time.Time) bool {
+	w.Lock()
+	defer w.Unlock()
+
+	if !t.After(w.current) {
+		return false
+	}
+	w.current = t
+	return true
+}
+
+func (w *Watermark) Current() time.Time {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.current
+}
+
+// Final reports whether the window containing t is closed.
+func (w *Watermark) Final(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.current.IsZero() {
+		return false
+	}
+	return !w.current.Add(-w.lateness).Before(w.window.End(t))
+}
+
+// Late counts merges into windows that were already final. Data terlambat tetap dihitung,
+// row yang sudah final dikirim ulang ke sink sebagai koreksi.
+func (w *Watermark) Late() int64 {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.late
+}
+
+func (w *Watermark) addLate() {
+	w.Lock()
+	defer w.Unlock()
+
+	w.late += 1
+}
+
+// NewWatermark creates a watermark for window with allowed lateness, ex DayWindow dan 48 jam.
+func NewWatermark(window Window, lateness time.Duration) *Watermark {
+	return &Watermark{
+		window:   window,
+		lateness: lateness,
+	}
+}
@@ -0,0 +1,183 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// Jakarta is the timezone of every window, fallback ke +7 kalau tzdata tidak tersedia.
+var Jakarta = loadJakarta()
+
+func loadJakarta() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
+}
+
+var ErrInvalidWindowTime = errors.New("invalid window time")
+
+// Window groups times into periods, Label unik antar window sehingga bisa dipakai di key.
+type Window interface {
+	Name() string
+	Start(t time.Time) time.Time
+	// End is the start of the next window
+	End(t time.Time) time.Time
+	Label(t time.Time) string
+}
+
+type calendarWindow struct {
+	name   string
+	layout string
+	start  func(t time.Time) time.Time
+	next   func(start time.Time) time.Time
+}
+
+func (c *calendarWindow) Name() string {
+	return c.name
+}
+
+func (c *calendarWindow) Start(t time.Time) time.Time {
+	return c.start(t.In(Jakarta))
+}
+
+func (c *calendarWindow) End(t time.Time) time.Time {
+	return c.next(c.Start(t))
+}
+
+func (c *calendarWindow) Label(t time.Time) string {
+	start := c.Start(t)
+	if c.layout == "" {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	}
+	return start.Format(c.layout)
+}
+
+var (
+	HourWindow Window = &calendarWindow{
+		name:   "hour",
+		layout: "2006-01-02T15",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, Jakarta)
+		},
+		next: func(start time.Time) time.Time {
+			return start.Add(time.Hour)
+		},
+	}
+	DayWindow Window = &calendarWindow{
+		name:   "day",
+		layout: "2006-01-02",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Jakarta)
+		},
+		next: func(start time.Time) time.Time {
+			return start.AddDate(0, 0, 1)
+		},
+	}
+	// WeekWindow is the ISO week, mulai hari senin
+	WeekWindow Window = &calendarWindow{
+		name: "week",
+		start: func(t time.Time) time.Time {
+			offset := (int(t.Weekday()) + 6) % 7
+			return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, Jakarta)
+		},
+		next: func(start time.Time) time.Time {
+			return start.AddDate(0, 0, 7)
+		},
+	}
+	MonthWindow Window = &calendarWindow{
+		name:   "month",
+		layout: "2006-01",
+		start: func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, Jakarta)
+		},
+		next: func(start time.Time) time.Time {
+			return start.AddDate(0, 1, 0)
+		},
+	}
+)
+
+type tumblingWindow struct {
+	size time.Duration
+}
+
+func (w *tumblingWindow) Name() string {
+	return "tumbling_" + w.size.String()
+}
+
+// Start aligns to the jakarta wall clock, window 15m mulai di menit 0, 15, 30 dan 45.
+func (w *tumblingWindow) Start(t time.Time) time.Time {
+	t = t.In(Jakarta)
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(w.size).Add(-shift).In(Jakarta)
+}
+
+func (w *tumblingWindow) End(t time.Time) time.Time {
+	return w.Start(t).Add(w.size)
+}
+
+func (w *tumblingWindow) Label(t time.Time) string {
+	return w.Start(t).Format("2006-01-02T15:04")
+}
+
+// TumblingWindow splits time into fixed size windows without overlap.
+func TumblingWindow(size time.Duration) Window {
+	return &tumblingWindow{
+		size: size,
+	}
+}
+
+// ParseDay parses the Day field of the daily metrics in jakarta time.
+func ParseDay(day string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", day, Jakarta)
+}
+
+// WindowData is a metric that can be rolled up to any window, cukup satu definisi untuk semua rollup.
+type WindowData[R MetricData] interface {
+	MetricData
+	// WindowTime is the event time of the delta
+	WindowTime() time.Time
+	// InWindow returns a copy of the delta keyed by window and label, label kosong untuk accumulator kosong.
+	InWindow(window Window, label string) R
+}
+
+// NewWindowStore creates the store of one window, nama store ikut nama window supaya wal tidak bentrok.
+func NewWindowStore[R WindowData[R]](db *badger.DB, window Window, emptyAcc func() R, output func(R) R) MetricStore[R] {
+	return NewDefaultMetricStore(db, func() R {
+		return emptyAcc().InWindow(window, "")
+	}, output)
+}
+
+// MergeWindow adds a delta to the window containing its event time, delta dengan origin di skip kalau
+// sudah pernah masuk.
+func MergeWindow[R WindowData[R]](store MetricStore[R], window Window, delta R) error {
+	at := delta.WindowTime()
+	if at.IsZero() {
+		return fmt.Errorf("%w: %s", ErrInvalidWindowTime, delta.Key())
+	}
+
+	var origin Origin
+	if odata, ok := any(delta).(OriginData); ok {
+		origin = odata.Origin()
+	}
+
+	item := delta.InWindow(window, window.Label(at))
+	return store.MergeFrom(origin, item.Key(), func(acc R) R {
+		if isNilData(acc) {
+			return item
+		}
+		return acc.Merge(item).(R)
+	})
+}
+
+func isNilData(data any) bool {
+	val := reflect.ValueOf(data)
+	return !val.IsValid() || (val.Kind() == reflect.Pointer && val.IsNil())
+}
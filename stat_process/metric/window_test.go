@@ -0,0 +1,41 @@
+package metric_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdcgo/materialize/stat_process/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowLabel(t *testing.T) {
+	// 2025-08-31 17:30 UTC sudah 2025-09-01 00:30 di jakarta
+	at := time.Date(2025, 8, 31, 17, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		window metric.Window
+		label  string
+		start  time.Time
+	}{
+		{metric.HourWindow, "2025-09-01T00", time.Date(2025, 9, 1, 0, 0, 0, 0, metric.Jakarta)},
+		{metric.DayWindow, "2025-09-01", time.Date(2025, 9, 1, 0, 0, 0, 0, metric.Jakarta)},
+		{metric.WeekWindow, "2025-W36", time.Date(2025, 9, 1, 0, 0, 0, 0, metric.Jakarta)},
+		{metric.MonthWindow, "2025-09", time.Date(2025, 9, 1, 0, 0, 0, 0, metric.Jakarta)},
+		{metric.TumblingWindow(time.Minute * 15), "2025-09-01T00:30", time.Date(2025, 9, 1, 0, 30, 0, 0, metric.Jakarta)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.window.Name(), func(t *testing.T) {
+			assert.Equal(t, c.label, c.window.Label(at))
+			assert.True(t, c.start.Equal(c.window.Start(at)))
+		})
+	}
+
+	t.Run("iso week di awal tahun", func(t *testing.T) {
+		// minggu 2027-01-03 masih week 53 tahun 2026
+		day, err := metric.ParseDay("2027-01-03")
+		assert.Nil(t, err)
+		assert.Equal(t, "2026-W53", metric.WeekWindow.Label(day))
+		assert.Equal(t, "2026-12-28", metric.DayWindow.Label(metric.WeekWindow.Start(day)))
+	})
+}
@@ -0,0 +1,450 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_replica"
+)
+
+const snapshotMagic = "MATSNAP1\n"
+
+// batas badger, dipakai supaya file rusak tidak membuat alokasi besar
+const (
+	maxKeySize   = 65000
+	maxValueSize = 1 << 30
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrSlotAhead is returned when the slot already confirmed past the snapshot lsn, wal di antaranya
+// sudah dibuang source jadi stream tidak bisa lanjut dari snapshot.
+var ErrSlotAhead = errors.New("replication slot confirmed past snapshot lsn")
+
+type ErrSnapshotCorrupt struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func IsSnapshotCorrupt(err error) bool {
+	var cerr *ErrSnapshotCorrupt
+	return errors.As(err, &cerr)
+}
+
+// Error implements error.
+func (e *ErrSnapshotCorrupt) Error() string {
+	return fmt.Sprintf("snapshot %s corrupt: %s", e.ID, e.Reason)
+}
+
+// Manifest describes one snapshot, LSN adalah checkpoint slot di dalam snapshot yang sama.
+type Manifest struct {
+	ID        string        `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Slot      string        `json:"slot"`
+	LSN       pglogrepl.LSN `json:"lsn"`
+	Keys      int64         `json:"keys"`
+	// ukuran file terkompresi
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+type record struct {
+	key       []byte
+	value     []byte
+	expiresAt uint64
+	userMeta  byte
+}
+
+// Store keeps snapshots in one directory, <id>.snap berisi data dan <id>.json manifest.
+type Store struct {
+	dir string
+}
+
+func (s *Store) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".snap")
+}
+
+func (s *Store) manifestPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Create writes every live key of db from one read transaction, jadi data dan checkpoint lsn konsisten
+// walaupun stream tetap berjalan.
+func (s *Store) Create(db *badger.DB, slot string) (*Manifest, error) {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	created := time.Now().UTC()
+	manifest := Manifest{
+		ID:        created.Format("20060102T150405.000000000Z"),
+		CreatedAt: created,
+		Slot:      slot,
+	}
+
+	tmpPath := s.dataPath(manifest.ID) + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(file, hash))
+	buf := bufio.NewWriter(gz)
+
+	err = db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(stat_replica.CheckpointKey(slot))
+		switch {
+		case err == nil:
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			manifest.LSN, err = pglogrepl.ParseLSN(string(val))
+			if err != nil {
+				return err
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		_, err = buf.WriteString(snapshotMagic)
+		if err != nil {
+			return err
+		}
+
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = writeRecord(buf, &record{
+				key:       item.Key(),
+				value:     val,
+				expiresAt: item.ExpiresAt(),
+				userMeta:  item.UserMeta(),
+			})
+			if err != nil {
+				return err
+			}
+			manifest.Keys += 1
+		}
+		return nil
+	})
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Size = info.Size()
+	manifest.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	err = os.Rename(tmpPath, s.dataPath(manifest.ID))
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, s.writeManifest(&manifest)
+}
+
+func (s *Store) writeManifest(manifest *Manifest) error {
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := s.manifestPath(manifest.ID) + ".tmp"
+	err = os.WriteFile(tmpPath, raw, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.manifestPath(manifest.ID))
+}
+
+func (s *Store) Get(id string) (*Manifest, error) {
+	raw, err := os.ReadFile(s.manifestPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+		}
+		return nil, err
+	}
+
+	manifest := Manifest{}
+	err = json.Unmarshal(raw, &manifest)
+	if err != nil {
+		return nil, &ErrSnapshotCorrupt{ID: id, Reason: err.Error()}
+	}
+	return &manifest, nil
+}
+
+// List returns the snapshots from the oldest.
+func (s *Store) List() ([]*Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Manifest{}
+	for _, path := range paths {
+		manifest, err := s.Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, manifest)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// Latest returns the newest snapshot, ErrSnapshotNotFound kalau belum ada.
+func (s *Store) Latest() (*Manifest, error) {
+	manifests, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, ErrSnapshotNotFound
+	}
+	return manifests[len(manifests)-1], nil
+}
+
+// Verify checks the checksum and decodes every record against the manifest.
+func (s *Store) Verify(id string) (*Manifest, error) {
+	manifest, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, s.read(manifest, func(rec *record) error {
+		return nil
+	})
+}
+
+// Restore replaces every key of db with the snapshot, db dikosongkan dulu. Snapshot diverifikasi
+// sebelum ada yang dihapus.
+func (s *Store) Restore(id string, db *badger.DB) (*Manifest, error) {
+	manifest, err := s.Verify(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DropAll()
+	if err != nil {
+		return nil, err
+	}
+
+	now := uint64(time.Now().Unix())
+	batch := db.NewWriteBatch()
+	err = s.read(manifest, func(rec *record) error {
+		if rec.expiresAt != 0 && rec.expiresAt <= now {
+			return nil
+		}
+		entry := badger.NewEntry(rec.key, rec.value).WithMeta(rec.userMeta)
+		entry.ExpiresAt = rec.expiresAt
+		return batch.SetEntry(entry)
+	})
+	if err != nil {
+		batch.Cancel()
+		return nil, err
+	}
+	return manifest, batch.Flush()
+}
+
+// RestoreSlot restores snapshot id for the stream of slot and commits manifest.LSN as its checkpoint,
+// stream lalu lanjut dari lsn itu tanpa backfill. Ini hanya benar dengan slot permanen yang masih ada
+// dan confirmed_flush_lsn nya belum melewati lsn snapshot, slot dicek lewat query sebelum db dihapus.
+func (s *Store) RestoreSlot(ctx context.Context, id string, db *badger.DB, slot string, query stat_replica.SlotQuery) (*Manifest, error) {
+	manifest, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := query(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+	if stats.ConfirmedFlushLSN > manifest.LSN {
+		return nil, fmt.Errorf("%w: slot %s at %s, snapshot %s at %s", ErrSlotAhead, slot, stats.ConfirmedFlushLSN, manifest.ID, manifest.LSN)
+	}
+
+	manifest, err = s.Restore(id, db)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, stat_replica.NewBadgeCheckpoint(db, slot).Commit(manifest.LSN)
+}
+
+func (s *Store) Delete(id string) error {
+	err := os.Remove(s.manifestPath(id))
+	if err != nil {
+		return err
+	}
+	err = os.Remove(s.dataPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) read(manifest *Manifest, handle func(rec *record) error) error {
+	corrupt := func(reason string) error {
+		return &ErrSnapshotCorrupt{ID: manifest.ID, Reason: reason}
+	}
+
+	file, err := os.Open(s.dataPath(manifest.ID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return corrupt("data file missing")
+		}
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	tee := io.TeeReader(file, hash)
+	gz, err := gzip.NewReader(tee)
+	if err != nil {
+		return corrupt(err.Error())
+	}
+	reader := bufio.NewReader(gz)
+
+	magic := make([]byte, len(snapshotMagic))
+	_, err = io.ReadFull(reader, magic)
+	if err != nil || string(magic) != snapshotMagic {
+		return corrupt("invalid header")
+	}
+
+	var keys int64
+	for {
+		rec, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return corrupt(err.Error())
+		}
+		keys += 1
+		err = handle(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	// sisa byte gzip ikut di hash
+	_, err = io.Copy(io.Discard, tee)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != manifest.Checksum {
+		return corrupt("checksum mismatch")
+	}
+	if keys != manifest.Keys {
+		return corrupt(fmt.Sprintf("expected %d keys got %d", manifest.Keys, keys))
+	}
+	return nil
+}
+
+func writeRecord(w io.Writer, rec *record) error {
+	buf := make([]byte, 0, len(rec.key)+len(rec.value)+3*binary.MaxVarintLen64+1)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.key)))
+	buf = append(buf, rec.key...)
+	buf = binary.AppendUvarint(buf, uint64(len(rec.value)))
+	buf = append(buf, rec.value...)
+	buf = binary.AppendUvarint(buf, rec.expiresAt)
+	buf = append(buf, rec.userMeta)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readRecord(r *bufio.Reader) (*record, error) {
+	keyLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		// EOF tepat di batas record berarti selesai
+		return nil, err
+	}
+
+	if keyLen > maxKeySize {
+		return nil, fmt.Errorf("key size %d too large", keyLen)
+	}
+
+	rec := record{}
+	rec.key = make([]byte, keyLen)
+	_, err = io.ReadFull(r, rec.key)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	valLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if valLen > maxValueSize {
+		return nil, fmt.Errorf("value size %d too large", valLen)
+	}
+	rec.value = make([]byte, valLen)
+	_, err = io.ReadFull(r, rec.value)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	rec.expiresAt, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	rec.userMeta, err = r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return &rec, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func NewStore(dir string) *Store {
+	return &Store{
+		dir: dir,
+	}
+}
@@ -0,0 +1,134 @@
+package snapshot_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_process/snapshot"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	var source, target db_mock.BadgeDBMock
+
+	moretest.Suite(t, "testing snapshot restore",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&source),
+			db_mock.NewBadgeDBMock(&target),
+		},
+		func(t *testing.T) {
+			dir := t.TempDir()
+			store := snapshot.NewStore(dir)
+
+			checkpoint := stat_replica.NewBadgeCheckpoint(source.DB, "stat_slot")
+			err := checkpoint.Commit(0x1A2B3C)
+			assert.Nil(t, err)
+
+			err = source.DB.Update(func(txn *badger.Txn) error {
+				err := txn.Set([]byte("metric/daily_shop/2025-01-08/1/1"), []byte(`{"ads_spent_amount":9000}`))
+				if err != nil {
+					return err
+				}
+				return txn.SetEntry(badger.NewEntry([]byte("exact/2025-01-08/1"), []byte(`{}`)).WithTTL(time.Hour))
+			})
+			assert.Nil(t, err)
+
+			manifest, err := store.Create(source.DB, "stat_slot")
+			assert.Nil(t, err)
+			assert.Equal(t, int64(0x1A2B3C), int64(manifest.LSN))
+			assert.Equal(t, int64(3), manifest.Keys)
+
+			// ditulis setelah snapshot, tidak boleh ikut
+			err = checkpoint.Commit(0x1A2B4C)
+			assert.Nil(t, err)
+
+			t.Run("list dan verify", func(t *testing.T) {
+				manifests, err := store.List()
+				assert.Nil(t, err)
+				assert.Len(t, manifests, 1)
+
+				latest, err := store.Latest()
+				assert.Nil(t, err)
+				assert.Equal(t, manifest.ID, latest.ID)
+
+				_, err = store.Verify(manifest.ID)
+				assert.Nil(t, err)
+			})
+
+			t.Run("restore ke db baru lanjut dari lsn snapshot", func(t *testing.T) {
+				_, err := store.Restore(manifest.ID, target.DB)
+				assert.Nil(t, err)
+
+				lsn, err := stat_replica.NewBadgeCheckpoint(target.DB, "stat_slot").LastLSN()
+				assert.Nil(t, err)
+				assert.Equal(t, manifest.LSN, lsn)
+
+				err = target.DB.View(func(txn *badger.Txn) error {
+					item, err := txn.Get([]byte("metric/daily_shop/2025-01-08/1/1"))
+					if err != nil {
+						return err
+					}
+					val, err := item.ValueCopy(nil)
+					assert.Equal(t, `{"ads_spent_amount":9000}`, string(val))
+
+					item, err = txn.Get([]byte("exact/2025-01-08/1"))
+					if err != nil {
+						return err
+					}
+					assert.NotZero(t, item.ExpiresAt())
+					return err
+				})
+				assert.Nil(t, err)
+			})
+
+			t.Run("restore slot hanya kalau slot belum lewat lsn snapshot", func(t *testing.T) {
+				confirmed := manifest.LSN + 0x10
+				query := func(ctx context.Context, slotName string) (*stat_replica.SlotStats, error) {
+					return &stat_replica.SlotStats{SlotName: slotName, ConfirmedFlushLSN: confirmed}, nil
+				}
+
+				_, err := store.RestoreSlot(t.Context(), manifest.ID, target.DB, "new_slot", query)
+				assert.ErrorIs(t, err, snapshot.ErrSlotAhead)
+				lsn, err := stat_replica.NewBadgeCheckpoint(target.DB, "new_slot").LastLSN()
+				assert.Nil(t, err)
+				assert.Equal(t, pglogrepl.LSN(0), lsn)
+
+				confirmed = manifest.LSN - 0x10
+				_, err = store.RestoreSlot(t.Context(), manifest.ID, target.DB, "new_slot", query)
+				assert.Nil(t, err)
+				lsn, err = stat_replica.NewBadgeCheckpoint(target.DB, "new_slot").LastLSN()
+				assert.Nil(t, err)
+				assert.Equal(t, manifest.LSN, lsn)
+			})
+
+			t.Run("file rusak ditolak sebelum db dihapus", func(t *testing.T) {
+				path := filepath.Join(dir, manifest.ID+".snap")
+				raw, err := os.ReadFile(path)
+				assert.Nil(t, err)
+				raw[len(raw)/2] ^= 0xFF
+				err = os.WriteFile(path, raw, 0644)
+				assert.Nil(t, err)
+
+				_, err = store.Restore(manifest.ID, target.DB)
+				assert.True(t, snapshot.IsSnapshotCorrupt(err))
+
+				lsn, err := stat_replica.NewBadgeCheckpoint(target.DB, "stat_slot").LastLSN()
+				assert.Nil(t, err)
+				assert.Equal(t, manifest.LSN, lsn)
+			})
+
+			t.Run("snapshot tidak ada", func(t *testing.T) {
+				_, err := store.Verify("missing")
+				assert.ErrorIs(t, err, snapshot.ErrSnapshotNotFound)
+			})
+		},
+	)
+}
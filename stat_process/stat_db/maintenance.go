@@ -0,0 +1,80 @@
+package stat_db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// RunValueLogGC rewrites value log files every interval until ctx done, key yang expired baru benar benar
+// hilang dari disk setelah gc ini.
+func RunValueLogGC(ctx context.Context, db *badger.DB, interval time.Duration, discardRatio float64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count := 0
+			for {
+				err := db.RunValueLogGC(discardRatio)
+				if err != nil {
+					if !errors.Is(err, badger.ErrNoRewrite) && !errors.Is(err, badger.ErrRejected) {
+						slog.Error(err.Error(), slog.String("process", "value log gc"))
+					}
+					break
+				}
+				count += 1
+			}
+			if count > 0 {
+				slog.Info("value log gc", slog.Int("rewrite", count))
+			}
+		}
+	}
+}
+
+type PrefixStat struct {
+	Prefix string `json:"prefix"`
+	Keys   int64  `json:"keys"`
+	// key yang punya ttl
+	Expiring  int64 `json:"expiring"`
+	KeySize   int64 `json:"key_size"`
+	ValueSize int64 `json:"value_size"`
+}
+
+// PrefixStats counts keys and bytes under every prefix, ukuran value dari value log tanpa membaca isinya.
+func PrefixStats(db *badger.DB, prefixes ...string) ([]*PrefixStat, error) {
+	result := make([]*PrefixStat, len(prefixes))
+	err := db.View(func(txn *badger.Txn) error {
+		for i, prefix := range prefixes {
+			stat := PrefixStat{
+				Prefix: prefix,
+			}
+
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = []byte(prefix)
+			iter := txn.NewIterator(opts)
+			for iter.Rewind(); iter.Valid(); iter.Next() {
+				item := iter.Item()
+				stat.Keys += 1
+				stat.KeySize += int64(len(item.Key()))
+				stat.ValueSize += item.ValueSize()
+				if item.ExpiresAt() != 0 {
+					stat.Expiring += 1
+				}
+			}
+			iter.Close()
+
+			result[i] = &stat
+		}
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,270 @@
+package stat_replica
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/jackc/pglogrepl"
+)
+
+// Checkpoint stores the LSN of the last transaction the handler fully processed.
+type Checkpoint interface {
+	LastLSN() (pglogrepl.LSN, error)
+	Commit(lsn pglogrepl.LSN) error
+}
+
+type badgeCheckpoint struct {
+	db   *badger.DB
+	key  []byte
+	slot string
+}
+
+// LastLSN implements Checkpoint.
+func (b *badgeCheckpoint) LastLSN() (pglogrepl.LSN, error) {
+	var lsn pglogrepl.LSN
+
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(b.key)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		lsn, err = pglogrepl.ParseLSN(string(val))
+		return err
+	})
+
+	return lsn, err
+}
+
+// Commit implements Checkpoint.
+func (b *badgeCheckpoint) Commit(lsn pglogrepl.LSN) error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(b.key, []byte(lsn.String()))
+	})
+}
+
+// LastRelation implements RelationStore.
+func (b *badgeCheckpoint) LastRelation(relationID uint32) (*pglogrepl.RelationMessageV2, error) {
+	var rel *pglogrepl.RelationMessageV2
+
+	err := b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(RelationKey(b.slot, relationID))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		rel = &pglogrepl.RelationMessageV2{}
+		return json.Unmarshal(val, rel)
+	})
+
+	return rel, err
+}
+
+// SaveRelation implements RelationStore.
+func (b *badgeCheckpoint) SaveRelation(rel *pglogrepl.RelationMessageV2) error {
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(RelationKey(b.slot, rel.RelationID), raw)
+	})
+}
+
+// CheckpointKey is the badger key of the slot checkpoint, ikut tersimpan di snapshot state.
+func CheckpointKey(slotName string) []byte {
+	return []byte(fmt.Sprintf("replication/lsn/%s", slotName))
+}
+
+// RelationKey is the badger key of the last relation of relationID seen by the slot.
+func RelationKey(slotName string, relationID uint32) []byte {
+	return []byte(fmt.Sprintf("replication/relation/%s/%d", slotName, relationID))
+}
+
+// NewBadgeCheckpoint stores the lsn and the last relation per oid of slotName, relation dipakai
+// parser untuk mendeteksi perubahan schema setelah restart.
+func NewBadgeCheckpoint(db *badger.DB, slotName string) Checkpoint {
+	return &badgeCheckpoint{
+		db:   db,
+		key:  CheckpointKey(slotName),
+		slot: slotName,
+	}
+}
+
+type memoryCheckpoint struct {
+	sync.Mutex
+	lsn pglogrepl.LSN
+}
+
+// LastLSN implements Checkpoint.
+func (m *memoryCheckpoint) LastLSN() (pglogrepl.LSN, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.lsn, nil
+}
+
+// Commit implements Checkpoint.
+func (m *memoryCheckpoint) Commit(lsn pglogrepl.LSN) error {
+	m.Lock()
+	defer m.Unlock()
+	m.lsn = lsn
+	return nil
+}
+
+func NewMemoryCheckpoint() Checkpoint {
+	return &memoryCheckpoint{}
+}
+
+// ConfirmedCheckpoint is a Checkpoint whose confirmed position lags Commit, replication mengirim
+// Confirmed ke source sebagai flush position.
+type ConfirmedCheckpoint interface {
+	Checkpoint
+	Confirmed() pglogrepl.LSN
+}
+
+type sinkMark struct {
+	seq uint64
+	lsn pglogrepl.LSN
+}
+
+// SinkCheckpoint confirms a lsn only after the sink finished every message dispatched before it.
+// Commit dari replication hanya mencatat posisi dispatch, lsn baru disimpan ke inner saat semua
+// pesan yang di Track sebelum posisi itu sudah Done.
+type SinkCheckpoint struct {
+	sync.Mutex
+	inner     Checkpoint
+	seq       uint64
+	pending   map[*CdcMessage]uint64
+	marks     []sinkMark
+	confirmed pglogrepl.LSN
+}
+
+// LastLSN implements Checkpoint.
+func (s *SinkCheckpoint) LastLSN() (pglogrepl.LSN, error) {
+	lsn, err := s.inner.LastLSN()
+	if err != nil {
+		return lsn, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if lsn > s.confirmed {
+		s.confirmed = lsn
+	}
+	return lsn, nil
+}
+
+// Commit implements Checkpoint.
+func (s *SinkCheckpoint) Commit(lsn pglogrepl.LSN) error {
+	s.Lock()
+	defer s.Unlock()
+
+	last := len(s.marks) - 1
+	if last >= 0 && s.marks[last].seq == s.seq {
+		// tidak ada pesan baru, lsn terakhir yang berlaku
+		s.marks[last].lsn = lsn
+	} else {
+		s.marks = append(s.marks, sinkMark{seq: s.seq, lsn: lsn})
+	}
+	return s.advance()
+}
+
+// Confirmed implements ConfirmedCheckpoint.
+func (s *SinkCheckpoint) Confirmed() pglogrepl.LSN {
+	s.Lock()
+	defer s.Unlock()
+	return s.confirmed
+}
+
+// Track registers msg before it is handed to the pipeline, dipanggil di handler replication.
+func (s *SinkCheckpoint) Track(msg *CdcMessage) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.seq += 1
+	s.pending[msg] = s.seq
+}
+
+// Done is called by the sink after msgs were fully processed, pesan yang tidak di Track diabaikan.
+func (s *SinkCheckpoint) Done(msgs ...*CdcMessage) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, msg := range msgs {
+		delete(s.pending, msg)
+	}
+	return s.advance()
+}
+
+// advance confirms the last mark without pending message before it, harus dipanggil dengan lock.
+func (s *SinkCheckpoint) advance() error {
+	low := s.seq + 1
+	for _, seq := range s.pending {
+		if seq < low {
+			low = seq
+		}
+	}
+
+	var lsn pglogrepl.LSN
+	n := 0
+	for n < len(s.marks) && s.marks[n].seq < low {
+		lsn = s.marks[n].lsn
+		n += 1
+	}
+	if lsn > s.confirmed {
+		err := s.inner.Commit(lsn)
+		if err != nil {
+			return err
+		}
+		s.confirmed = lsn
+	}
+	s.marks = s.marks[n:]
+	return nil
+}
+
+// LastRelation implements RelationStore, inner tanpa RelationStore berarti relation tidak disimpan.
+func (s *SinkCheckpoint) LastRelation(relationID uint32) (*pglogrepl.RelationMessageV2, error) {
+	store, ok := s.inner.(RelationStore)
+	if !ok {
+		return nil, nil
+	}
+	return store.LastRelation(relationID)
+}
+
+// SaveRelation implements RelationStore.
+func (s *SinkCheckpoint) SaveRelation(rel *pglogrepl.RelationMessageV2) error {
+	store, ok := s.inner.(RelationStore)
+	if !ok {
+		return nil
+	}
+	return store.SaveRelation(rel)
+}
+
+func NewSinkCheckpoint(inner Checkpoint) *SinkCheckpoint {
+	return &SinkCheckpoint{
+		inner:   inner,
+		pending: map[*CdcMessage]uint64{},
+	}
+}
+
+var _ RelationStore = (*badgeCheckpoint)(nil)
+var _ RelationStore = (*SinkCheckpoint)(nil)
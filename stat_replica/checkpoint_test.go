@@ -0,0 +1,95 @@
+package stat_replica_test
+
+import (
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBadgeCheckpoint(t *testing.T) {
+	var badgedb db_mock.BadgeDBMock
+
+	moretest.Suite(t, "testing checkpoint lsn",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&badgedb),
+		},
+		func(t *testing.T) {
+			checkpoint := stat_replica.NewBadgeCheckpoint(badgedb.DB, "stat_slot")
+
+			t.Run("testing checkpoint kosong", func(t *testing.T) {
+				lsn, err := checkpoint.LastLSN()
+				assert.Nil(t, err)
+				assert.Equal(t, pglogrepl.LSN(0), lsn)
+			})
+
+			t.Run("testing commit lsn", func(t *testing.T) {
+				err := checkpoint.Commit(pglogrepl.LSN(0x16B3748))
+				assert.Nil(t, err)
+
+				t.Run("testing resume dari checkpoint baru", func(t *testing.T) {
+					resume := stat_replica.NewBadgeCheckpoint(badgedb.DB, "stat_slot")
+					lsn, err := resume.LastLSN()
+					assert.Nil(t, err)
+					assert.Equal(t, pglogrepl.LSN(0x16B3748), lsn)
+				})
+
+				t.Run("testing slot lain tidak tercampur", func(t *testing.T) {
+					other := stat_replica.NewBadgeCheckpoint(badgedb.DB, "other_slot")
+					lsn, err := other.LastLSN()
+					assert.Nil(t, err)
+					assert.Equal(t, pglogrepl.LSN(0), lsn)
+				})
+			})
+		},
+	)
+}
+
+func TestSinkCheckpoint(t *testing.T) {
+	inner := stat_replica.NewMemoryCheckpoint()
+	checkpoint := stat_replica.NewSinkCheckpoint(inner)
+
+	first := &stat_replica.CdcMessage{CommitLSN: 0x100, Seq: 1}
+	second := &stat_replica.CdcMessage{CommitLSN: 0x200, Seq: 1}
+
+	checkpoint.Track(first)
+	assert.Nil(t, checkpoint.Commit(0x150))
+	checkpoint.Track(second)
+	assert.Nil(t, checkpoint.Commit(0x250))
+
+	t.Run("testing belum selesai di sink tidak dikonfirmasi", func(t *testing.T) {
+		assert.Equal(t, pglogrepl.LSN(0), checkpoint.Confirmed())
+		lsn, err := inner.LastLSN()
+		assert.Nil(t, err)
+		assert.Equal(t, pglogrepl.LSN(0), lsn)
+	})
+
+	t.Run("testing selesai tidak urut", func(t *testing.T) {
+		assert.Nil(t, checkpoint.Done(second))
+		assert.Equal(t, pglogrepl.LSN(0), checkpoint.Confirmed())
+
+		assert.Nil(t, checkpoint.Done(first))
+		assert.Equal(t, pglogrepl.LSN(0x250), checkpoint.Confirmed())
+		lsn, err := inner.LastLSN()
+		assert.Nil(t, err)
+		assert.Equal(t, pglogrepl.LSN(0x250), lsn)
+	})
+
+	t.Run("testing keepalive tanpa pesan langsung dikonfirmasi", func(t *testing.T) {
+		assert.Nil(t, checkpoint.Commit(0x300))
+		assert.Equal(t, pglogrepl.LSN(0x300), checkpoint.Confirmed())
+	})
+
+	t.Run("testing pesan tanpa posisi tidak menahan checkpoint", func(t *testing.T) {
+		change := &stat_replica.CdcMessage{ModType: stat_replica.CdcSchemaChange}
+		checkpoint.Track(change)
+		assert.Nil(t, checkpoint.Commit(0x400))
+		assert.Equal(t, pglogrepl.LSN(0x300), checkpoint.Confirmed())
+
+		assert.Nil(t, checkpoint.Done(change))
+		assert.Equal(t, pglogrepl.LSN(0x400), checkpoint.Confirmed())
+	})
+}
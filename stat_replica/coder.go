@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type Coder interface {
@@ -12,7 +15,9 @@ type Coder interface {
 }
 
 type coderMapper struct {
-	data map[string]interface{}
+	data map[string]*coderSpec
+	// tabel sumber coder, dipakai untuk publication
+	sources map[string]*SourceMetadata
 }
 
 var CODER_KEY = "coder_mapper"
@@ -21,7 +26,8 @@ var ErrCoderNotFound = errors.New("coder not found")
 
 func ContextWithCoder(ctx context.Context) context.Context {
 	mapper := coderMapper{
-		data: map[string]interface{}{},
+		data:    map[string]*coderSpec{},
+		sources: map[string]*SourceMetadata{},
 	}
 
 	return context.WithValue(ctx, CODER_KEY, &mapper)
@@ -45,10 +51,39 @@ func RegisterCoderSources(ctx context.Context, codregs ...*CoderReg) error {
 	return err
 }
 
+// CoderRegistration is a deferred typed registration, see CoderSource.
+type CoderRegistration func(ctx context.Context) error
+
+func CoderSource[T any](meta *SourceMetadata) CoderRegistration {
+	return func(ctx context.Context) error {
+		return RegisterTypedCoder[T](ctx, meta)
+	}
+}
+
+func RegisterCoders(ctx context.Context, regs ...CoderRegistration) error {
+	for _, reg := range regs {
+		err := reg(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterTypedCoder registers struct T as the coder of a table, GetCoder then returns a new *T.
+func RegisterTypedCoder[T any](ctx context.Context, meta *SourceMetadata) error {
+	return registerCoderType(ctx, meta.PrefixKey(), meta, reflect.TypeFor[T]())
+}
+
 func RegisterCoderSource(ctx context.Context, meta *SourceMetadata, coder interface{}) error {
-	var err error
-	key := meta.PrefixKey()
+	return registerCoderType(ctx, meta.PrefixKey(), meta, reflect.TypeOf(coder))
+}
+
+func RegisterCoder(ctx context.Context, key string, coder interface{}) error {
+	return registerCoderType(ctx, key, nil, reflect.TypeOf(coder))
+}
 
+func registerCoderType(ctx context.Context, key string, meta *SourceMetadata, typ reflect.Type) error {
 	mapper, ok := ctx.Value(CODER_KEY).(*coderMapper)
 	if !ok {
 		return ErrCoderMapperNotFound
@@ -59,41 +94,220 @@ func RegisterCoderSource(ctx context.Context, meta *SourceMetadata, coder interf
 		return fmt.Errorf("coder %s already registered", key)
 	}
 
-	mapper.data[key] = coder
-	return err
-}
+	spec, err := getCoderSpec(typ)
+	if err != nil {
+		return fmt.Errorf("coder %s: %w", key, err)
+	}
 
-func RegisterCoder(ctx context.Context, key string, coder interface{}) error {
-	var err error
+	mapper.data[key] = spec
+	if meta != nil {
+		mapper.sources[key] = meta
+	}
+	return nil
+}
 
+// CoderSources lists the tables registered with a coder, sorted by schema and table.
+func CoderSources(ctx context.Context) ([]*SourceMetadata, error) {
 	mapper, ok := ctx.Value(CODER_KEY).(*coderMapper)
 	if !ok {
-		return ErrCoderMapperNotFound
+		return nil, ErrCoderMapperNotFound
 	}
 
-	_, ok = mapper.data[key]
-	if ok {
-		return fmt.Errorf("coder %s already registered", key)
+	keys := make([]string, 0, len(mapper.sources))
+	for key := range mapper.sources {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	mapper.data[key] = coder
-	return err
+	result := make([]*SourceMetadata, len(keys))
+	for i, key := range keys {
+		result[i] = mapper.sources[key]
+	}
+	return result, nil
+}
+
+// CoderColumns returns the columns read by the coder of meta, in field order.
+func CoderColumns(ctx context.Context, meta *SourceMetadata) ([]string, error) {
+	spec, err := registeredSpec(ctx, meta.PrefixKey())
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(spec.fields))
+	for i, field := range spec.fields {
+		columns[i] = field.column
+	}
+	return columns, nil
 }
 
 func GetCoder(ctx context.Context, key string) (interface{}, error) {
+	spec, err := registeredSpec(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return reflect.New(spec.typ).Interface(), nil
+}
+
+func registeredSpec(ctx context.Context, key string) (*coderSpec, error) {
 	mapper, ok := ctx.Value(CODER_KEY).(*coderMapper)
 	if !ok {
 		return nil, ErrCoderMapperNotFound
 	}
 
-	var coder interface{}
-	coder, ok = mapper.data[key]
+	spec, ok := mapper.data[key]
 	if !ok {
-		return coder, ErrCoderNotFound
+		return nil, ErrCoderNotFound
+	}
+	return spec, nil
+}
+
+// CoderReport is the difference between table columns and coder fields.
+type CoderReport struct {
+	// column tanpa field di coder, datanya dibuang
+	Unmapped []string `json:"unmapped"`
+	// field coder yang tidak ada kolomnya, selalu zero value
+	Extra []string `json:"extra"`
+}
+
+func (r *CoderReport) Ok() bool {
+	return len(r.Unmapped) == 0 && len(r.Extra) == 0
+}
+
+// ValidateCoder checks the columns of a relation against the coder registered for meta.
+func ValidateCoder(ctx context.Context, meta *SourceMetadata, columns []string) (*CoderReport, error) {
+	spec, err := registeredSpec(ctx, meta.PrefixKey())
+	if err != nil {
+		return nil, err
 	}
 
-	newcoder := NewEmptyFromStruct(coder)
-	return newcoder, nil
+	return spec.check(columns), nil
+}
+
+type coderField struct {
+	index  int
+	name   string
+	column string
+	alias  string
+}
+
+// lookup reads the field value from a column keyed map, by column name then json alias.
+func lookupColumn[V any](field *coderField, data map[string]V) (V, bool) {
+	val, ok := data[field.column]
+	if ok || field.alias == "" {
+		return val, ok
+	}
+	val, ok = data[field.alias]
+	return val, ok
+}
+
+type coderSpec struct {
+	typ    reflect.Type
+	fields []*coderField
+	// column dan alias json ke field
+	columns map[string]*coderField
+}
+
+func (spec *coderSpec) hasColumn(column string) bool {
+	_, ok := spec.columns[column]
+	return ok
+}
+
+func (spec *coderSpec) check(columns []string) *CoderReport {
+	report := CoderReport{
+		Unmapped: []string{},
+		Extra:    []string{},
+	}
+
+	used := map[*coderField]bool{}
+	for _, column := range columns {
+		field, ok := spec.columns[column]
+		if !ok {
+			report.Unmapped = append(report.Unmapped, column)
+			continue
+		}
+		used[field] = true
+	}
+
+	for _, field := range spec.fields {
+		if !used[field] {
+			report.Extra = append(report.Extra, field.name)
+		}
+	}
+
+	return &report
+}
+
+var coderSpecs sync.Map
+
+// getCoderSpec reads column mapping of a struct, db tag first then snake case field name, json tag is an alias.
+func getCoderSpec(typ reflect.Type) (*coderSpec, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	cached, ok := coderSpecs.Load(typ)
+	if ok {
+		return cached.(*coderSpec), nil
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("coder must be a struct, got %s", typ)
+	}
+
+	spec := coderSpec{
+		typ:     typ,
+		fields:  []*coderField{},
+		columns: map[string]*coderField{},
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		column := tagName(structField.Tag.Get("db"))
+		if column == "-" {
+			continue
+		}
+		if column == "" {
+			column = toSnakeCase(structField.Name)
+		}
+
+		field := coderField{
+			index:  i,
+			name:   structField.Name,
+			column: column,
+		}
+
+		exist, ok := spec.columns[column]
+		if ok && exist.column == column {
+			return nil, fmt.Errorf("column %s mapped to %s and %s", column, exist.name, field.name)
+		}
+		spec.fields = append(spec.fields, &field)
+		spec.columns[column] = &field
+	}
+
+	for _, field := range spec.fields {
+		alias := tagName(typ.Field(field.index).Tag.Get("json"))
+		if alias == "" || alias == "-" {
+			continue
+		}
+		if _, ok := spec.columns[alias]; ok {
+			continue
+		}
+		field.alias = alias
+		spec.columns[alias] = field
+	}
+
+	cached, _ = coderSpecs.LoadOrStore(typ, &spec)
+	return cached.(*coderSpec), nil
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
 }
 
 func NewEmptyFromStruct(input interface{}) interface{} {
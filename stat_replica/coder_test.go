@@ -46,3 +46,80 @@ func TestCoder(t *testing.T) {
 	})
 
 }
+
+type TaggedCoder struct {
+	ID          uint   `json:"id"`
+	Description string `json:"desc"`
+	ShipmentFee int64  `json:"shipping_fee" db:"ship_fee"`
+	Internal    string `db:"-"`
+}
+
+func TestRegisterCoderTyped(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	meta := &stat_replica.SourceMetadata{Table: "tagged", Schema: "public"}
+
+	err := stat_replica.RegisterCoders(ctx, stat_replica.CoderSource[TaggedCoder](meta))
+	assert.Nil(t, err)
+
+	t.Run("testing register dua kali", func(t *testing.T) {
+		err := stat_replica.RegisterTypedCoder[TaggedCoder](ctx, meta)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("testing get coder typed", func(t *testing.T) {
+		code, err := stat_replica.GetCoder(ctx, meta.PrefixKey())
+		assert.Nil(t, err)
+		_, ok := code.(*TaggedCoder)
+		assert.True(t, ok)
+	})
+
+	t.Run("testing mapping tag", func(t *testing.T) {
+		code := TaggedCoder{}
+		err := stat_replica.MapToStruct(map[string]interface{}{
+			"id":          uint32(3),
+			"description": "kolom asli",
+			"ship_fee":    int64(1000),
+			"internal":    "tidak dipakai",
+		}, &code)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(3), code.ID)
+		assert.Equal(t, "kolom asli", code.Description)
+		assert.Equal(t, int64(1000), code.ShipmentFee)
+		assert.Empty(t, code.Internal)
+
+		t.Run("testing alias json", func(t *testing.T) {
+			code := TaggedCoder{}
+			err := stat_replica.MapToStruct(map[string]interface{}{
+				"desc": "dari json",
+			}, &code)
+			assert.Nil(t, err)
+			assert.Equal(t, "dari json", code.Description)
+		})
+	})
+
+	t.Run("testing validasi kolom relasi", func(t *testing.T) {
+		report, err := stat_replica.ValidateCoder(ctx, meta, []string{"id", "description", "created_at"})
+		assert.Nil(t, err)
+		assert.False(t, report.Ok())
+		assert.Equal(t, []string{"created_at"}, report.Unmapped)
+		assert.Equal(t, []string{"ShipmentFee"}, report.Extra)
+
+		report, err = stat_replica.ValidateCoder(ctx, meta, []string{"id", "description", "ship_fee"})
+		assert.Nil(t, err)
+		assert.True(t, report.Ok())
+	})
+
+	t.Run("testing coder bukan struct", func(t *testing.T) {
+		err := stat_replica.RegisterTypedCoder[string](ctx, &stat_replica.SourceMetadata{Table: "str", Schema: "public"})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("testing kolom dobel", func(t *testing.T) {
+		type Double struct {
+			Name  string
+			Other string `db:"name"`
+		}
+		err := stat_replica.RegisterTypedCoder[Double](ctx, &stat_replica.SourceMetadata{Table: "double", Schema: "public"})
+		assert.NotNil(t, err)
+	})
+}
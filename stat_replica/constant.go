@@ -2,5 +2,11 @@ package stat_replica
 
 const OUTPUT_PLUGIN = "pgoutput"
 
+// batas memory per transaksi streaming sebelum di spill ke disk
+const STREAM_MEMORY_LIMIT = 16 << 20
+
+// selisih wal server dan yang sudah di flush sebelum replica dianggap lagging
+const REPLICA_LAG_THRESHOLD = 64 << 20
+
 // const SLOT_NAME = "stat_repl_slot"
 // const PUB_NAME = "stat_publication"
@@ -19,6 +19,16 @@ import (
 )
 
 func ConnectProdDatabase(ctx context.Context) (*pgconn.PgConn, error) {
+	return connectProd(ctx, true)
+}
+
+// ConnectProdQueryDatabase opens a normal connection, koneksi replication hanya menerima simple query
+// jadi query dengan bind parameter harus lewat sini.
+func ConnectProdQueryDatabase(ctx context.Context) (*pgconn.PgConn, error) {
+	return connectProd(ctx, false)
+}
+
+func connectProd(ctx context.Context, replication bool) (*pgconn.PgConn, error) {
 	var cfg configs.AppConfig
 	var sec *secret.Secret
 	var err error
@@ -35,7 +45,9 @@ func ConnectProdDatabase(ctx context.Context) (*pgconn.PgConn, error) {
 	}
 	// cfg.Database.DBInstance = "'/cloudsql/" + cfg.Database.DBInstance + "'"
 	dsn := cfg.Database.ToDsn("stat_streaming")
-	dsn += " replication=database"
+	if replication {
+		dsn += " replication=database"
+	}
 
 	// dsn := "host=/cloudsql/project:region:instance user=postgres password=yourpass dbname=yourdb sslmode=disable"
 	dbconf, err := pgconn.ParseConfig(dsn)
@@ -0,0 +1,427 @@
+package stat_replica
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// placeholder debezium untuk kolom toast yang tidak berubah
+const debeziumUnavailable = "__debezium_unavailable_value"
+
+type debeziumField struct {
+	Field      string            `json:"field"`
+	Type       string            `json:"type"`
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
+	Fields     []*debeziumField  `json:"fields"`
+}
+
+type debeziumSourceInfo struct {
+	DB     string `json:"db"`
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	TxID   uint32 `json:"txId"`
+	TsMs   int64  `json:"ts_ms"`
+}
+
+type debeziumPayload struct {
+	Before      map[string]interface{} `json:"before"`
+	After       map[string]interface{} `json:"after"`
+	Source      debeziumSourceInfo     `json:"source"`
+	Op          string                 `json:"op"`
+	TsMs        int64                  `json:"ts_ms"`
+	Transaction *struct {
+		ID         string `json:"id"`
+		TotalOrder uint32 `json:"total_order"`
+	} `json:"transaction"`
+}
+
+// DebeziumDecoder converts Debezium postgres connector json events to CdcMessage, dengan atau tanpa schema envelope.
+type DebeziumDecoder struct {
+	ctx context.Context
+}
+
+// Decode returns nil for events without a row change, ex tombstone dan logical message.
+func (d *DebeziumDecoder) Decode(raw []byte) (*CdcMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var envelope map[string]json.RawMessage
+	err := json.Unmarshal(raw, &envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema *debeziumField
+	payloadRaw, ok := envelope["payload"]
+	if ok {
+		if schemaRaw, ok := envelope["schema"]; ok && string(schemaRaw) != "null" {
+			schema = &debeziumField{}
+			err = json.Unmarshal(schemaRaw, schema)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		payloadRaw = raw
+	}
+	if string(payloadRaw) == "null" {
+		return nil, nil
+	}
+
+	payload := debeziumPayload{}
+	decoder := json.NewDecoder(bytes.NewReader(payloadRaw))
+	decoder.UseNumber()
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// database dikosongkan sama seperti hasil parser pgoutput
+	meta := &SourceMetadata{
+		Table:  payload.Source.Table,
+		Schema: payload.Source.Schema,
+	}
+
+	cdc := CdcMessage{
+		SourceMetadata:  meta,
+		Timestamp:       payload.TsMs * 1000,
+		Xid:             payload.Source.TxID,
+		CommitTimestamp: payload.Source.TsMs * 1000,
+	}
+	if payload.Transaction != nil {
+		cdc.CommitLSN = debeziumCommitLSN(payload.Transaction.ID)
+		cdc.Seq = payload.Transaction.TotalOrder
+	}
+
+	var row map[string]interface{}
+	switch payload.Op {
+	case "c":
+		cdc.ModType = CdcInsert
+		row = payload.After
+	case "r":
+		cdc.ModType = CdcBackfill
+		row = payload.After
+	case "u":
+		cdc.ModType = CdcUpdate
+		row = payload.After
+	case "d":
+		cdc.ModType = CdcDelete
+		row = payload.Before
+	case "t":
+		cdc.ModType = CdcTruncate
+		cdc.Data = &TruncateData{
+			Relations: []*SourceMetadata{meta},
+		}
+		return &cdc, nil
+	default:
+		return nil, nil
+	}
+
+	columns := debeziumColumns(schema)
+	dataMap, present, err := d.decodeRow(meta, row, columns)
+	if err != nil {
+		return nil, err
+	}
+	cdc.Present = present
+	cdc.Data, err = mapToCoder(d.ctx, dataMap, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	// before hanya lengkap kalau REPLICA IDENTITY FULL, sama seperti old tuple pgoutput
+	if payload.Op == "u" && payload.Before != nil {
+		oldMap, _, err := d.decodeRow(meta, payload.Before, columns)
+		if err != nil {
+			return nil, err
+		}
+		cdc.OldData, err = mapToCoder(d.ctx, oldMap, meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &cdc, nil
+}
+
+func (d *DebeziumDecoder) decodeRow(meta *SourceMetadata, row map[string]interface{}, columns map[string]*debeziumField) (map[string]interface{}, map[string]bool, error) {
+	dataMap := map[string]interface{}{}
+	var present map[string]bool
+
+	for column, val := range row {
+		if val == debeziumUnavailable {
+			if present == nil {
+				present = map[string]bool{}
+				for name := range row {
+					present[name] = true
+				}
+			}
+			present[column] = false
+			continue
+		}
+
+		decoded, err := debeziumValue(columns[column], val)
+		if err != nil {
+			return nil, nil, &ErrDecodeColumn{
+				Schema: meta.Schema,
+				Table:  meta.Table,
+				Column: column,
+				Err:    err,
+			}
+		}
+		dataMap[column] = decoded
+	}
+
+	err := d.coerceToCoder(meta, dataMap)
+	return dataMap, present, err
+}
+
+// coerceToCoder converts values that json cannot type without schema, waktu dianggap micro timestamp
+// atau RFC3339 dan decimal string ke float.
+func (d *DebeziumDecoder) coerceToCoder(meta *SourceMetadata, dataMap map[string]interface{}) error {
+	spec, err := registeredSpec(d.ctx, meta.PrefixKey())
+	if err != nil {
+		return nil
+	}
+
+	timeType := reflect.TypeOf(time.Time{})
+	for _, field := range spec.fields {
+		typ := spec.typ.Field(field.index).Type
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		column := field.column
+		val, ok := dataMap[column]
+		if !ok && field.alias != "" {
+			column = field.alias
+			val, ok = dataMap[column]
+		}
+		if !ok || val == nil {
+			continue
+		}
+
+		switch {
+		case typ == timeType:
+			switch v := val.(type) {
+			case int64:
+				dataMap[column] = time.UnixMicro(v).UTC()
+			case string:
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return fmt.Errorf("column %s: %w", column, err)
+				}
+				dataMap[column] = t
+			}
+		case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+			if v, ok := val.(string); ok {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return fmt.Errorf("column %s: %w", column, err)
+				}
+				dataMap[column] = f
+			}
+		}
+	}
+	return nil
+}
+
+// debeziumColumns returns the row fields of the envelope schema, nil without schema.
+func debeziumColumns(schema *debeziumField) map[string]*debeziumField {
+	if schema == nil {
+		return nil
+	}
+
+	columns := map[string]*debeziumField{}
+	for _, field := range schema.Fields {
+		if field.Field != "after" && field.Field != "before" {
+			continue
+		}
+		for _, column := range field.Fields {
+			columns[column.Field] = column
+		}
+	}
+	return columns
+}
+
+func debeziumValue(field *debeziumField, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	var name string
+	if field != nil {
+		name = field.Name
+	}
+
+	switch name {
+	case "io.debezium.time.Timestamp":
+		n, err := debeziumInt(val)
+		return time.UnixMilli(n).UTC(), err
+	case "io.debezium.time.MicroTimestamp":
+		n, err := debeziumInt(val)
+		return time.UnixMicro(n).UTC(), err
+	case "io.debezium.time.NanoTimestamp":
+		n, err := debeziumInt(val)
+		return time.Unix(0, n).UTC(), err
+	case "io.debezium.time.ZonedTimestamp":
+		return time.Parse(time.RFC3339Nano, fmt.Sprint(val))
+	case "io.debezium.time.Date":
+		n, err := debeziumInt(val)
+		return time.Unix(n*86400, 0).UTC(), err
+	case "org.apache.kafka.connect.data.Decimal":
+		scale, _ := strconv.Atoi(field.Parameters["scale"])
+		return debeziumDecimal(fmt.Sprint(val), scale)
+	case "io.debezium.data.VariableScaleDecimal":
+		v, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid variable scale decimal %v", val)
+		}
+		scale, err := debeziumInt(v["scale"])
+		if err != nil {
+			return nil, err
+		}
+		return debeziumDecimal(fmt.Sprint(v["value"]), int(scale))
+	}
+
+	if n, ok := val.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		return n.Float64()
+	}
+	return val, nil
+}
+
+func debeziumInt(val interface{}) (int64, error) {
+	n, ok := val.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected number got %T", val)
+	}
+	return n.Int64()
+}
+
+// debeziumDecimal decodes base64 big endian unscaled value, hasilnya sama dengan numeric dari pgoutput.
+func debeziumDecimal(raw string, scale int) (pgtype.Numeric, error) {
+	b, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return pgtype.Numeric{}, err
+	}
+
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return pgtype.Numeric{Int: n, Exp: int32(-scale), Valid: true}, nil
+}
+
+// debeziumCommitLSN reads the lsn of transaction id "txId:lsn".
+func debeziumCommitLSN(id string) pglogrepl.LSN {
+	_, lsn, ok := strings.Cut(id, ":")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseUint(lsn, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pglogrepl.LSN(n)
+}
+
+func NewDebeziumDecoder(ctx context.Context) *DebeziumDecoder {
+	return &DebeziumDecoder{
+		ctx: ctx,
+	}
+}
+
+// DebeziumSource reads json lines of Debezium change events, ex hasil export topic kafka.
+type DebeziumSource struct {
+	ctx     context.Context
+	open    []func() (io.ReadCloser, error)
+	decoder *DebeziumDecoder
+}
+
+// Start implements Source.
+func (s *DebeziumSource) Start(handler ReplicationHandler) error {
+	for _, open := range s.open {
+		reader, err := open()
+		if err != nil {
+			return err
+		}
+
+		err = s.read(reader, handler)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DebeziumSource) read(reader io.Reader, handler ReplicationHandler) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		cdc, err := s.decoder.Decode(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("debezium event line %d: %w", line, err)
+		}
+		if cdc != nil {
+			handler(cdc)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// NewDebeziumFileSource reads the files in order.
+func NewDebeziumFileSource(ctx context.Context, paths ...string) *DebeziumSource {
+	open := make([]func() (io.ReadCloser, error), len(paths))
+	for i, path := range paths {
+		open[i] = func() (io.ReadCloser, error) {
+			return os.Open(path)
+		}
+	}
+
+	return &DebeziumSource{
+		ctx:     ctx,
+		open:    open,
+		decoder: NewDebeziumDecoder(ctx),
+	}
+}
+
+// NewDebeziumSource reads events from reader, ex stdout kafka console consumer.
+func NewDebeziumSource(ctx context.Context, reader io.Reader) *DebeziumSource {
+	return &DebeziumSource{
+		ctx: ctx,
+		open: []func() (io.ReadCloser, error){
+			func() (io.ReadCloser, error) {
+				return io.NopCloser(reader), nil
+			},
+		},
+		decoder: NewDebeziumDecoder(ctx),
+	}
+}
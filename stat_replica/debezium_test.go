@@ -0,0 +1,146 @@
+package stat_replica_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+type DebeziumExpense struct {
+	ID        uint      `json:"id"`
+	Amount    float64   `json:"amount"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func TestDebeziumDecoder(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	meta := &stat_replica.SourceMetadata{Table: "expenses", Schema: "public"}
+	err := stat_replica.RegisterTypedCoder[DebeziumExpense](ctx, meta)
+	assert.Nil(t, err)
+
+	decoder := stat_replica.NewDebeziumDecoder(ctx)
+	created := time.Date(2025, 1, 8, 3, 0, 0, 0, time.UTC)
+
+	t.Run("testing envelope dengan schema", func(t *testing.T) {
+		event := `{
+			"schema": {"type": "struct", "fields": [
+				{"field": "after", "type": "struct", "fields": [
+					{"field": "id", "type": "int64"},
+					{"field": "amount", "type": "bytes", "name": "org.apache.kafka.connect.data.Decimal", "parameters": {"scale": "2"}},
+					{"field": "note", "type": "string"},
+					{"field": "created_at", "type": "int64", "name": "io.debezium.time.MicroTimestamp"}
+				]}
+			]},
+			"payload": {
+				"before": null,
+				"after": {"id": 7, "amount": "AQ==", "note": "ads", "created_at": 1736305200000000},
+				"source": {"db": "appdb", "schema": "public", "table": "expenses", "txId": 751, "ts_ms": 1736305200000},
+				"op": "c",
+				"ts_ms": 1736305200100,
+				"transaction": {"id": "751:24023512", "total_order": 3}
+			}
+		}`
+
+		cdc, err := decoder.Decode([]byte(event))
+		assert.Nil(t, err)
+		assert.Equal(t, stat_replica.CdcInsert, cdc.ModType)
+		assert.Equal(t, meta, cdc.SourceMetadata)
+		assert.Equal(t, uint32(751), cdc.Xid)
+		assert.Equal(t, int64(24023512), int64(cdc.CommitLSN))
+		assert.Equal(t, uint32(3), cdc.Seq)
+		assert.Equal(t, int64(1736305200000000), cdc.CommitTimestamp)
+		assert.Nil(t, cdc.Present)
+
+		data := cdc.Data.(*DebeziumExpense)
+		assert.Equal(t, uint(7), data.ID)
+		assert.Equal(t, 0.01, data.Amount)
+		assert.Equal(t, "ads", data.Note)
+		assert.True(t, created.Equal(data.CreatedAt))
+	})
+
+	t.Run("testing tanpa schema dan kolom toast tidak berubah", func(t *testing.T) {
+		event := `{"before": {"id": 7, "amount": "10.5", "note": "lama", "created_at": "2025-01-08T03:00:00Z"},
+			"after": {"id": 7, "amount": "12.5", "note": "__debezium_unavailable_value", "created_at": "2025-01-08T03:00:00Z"},
+			"source": {"schema": "public", "table": "expenses", "txId": 752},
+			"op": "u"}`
+
+		cdc, err := decoder.Decode([]byte(event))
+		assert.Nil(t, err)
+		assert.Equal(t, stat_replica.CdcUpdate, cdc.ModType)
+		assert.False(t, cdc.IsPresent("note"))
+		assert.True(t, cdc.IsPresent("amount"))
+
+		data := cdc.Data.(*DebeziumExpense)
+		assert.Equal(t, 12.5, data.Amount)
+		assert.True(t, created.Equal(data.CreatedAt))
+
+		old := cdc.OldData.(*DebeziumExpense)
+		assert.Equal(t, "lama", old.Note)
+
+		err = stat_replica.MergeUnchanged(cdc.Data, cdc.OldData, cdc.Present)
+		assert.Nil(t, err)
+		assert.Equal(t, "lama", data.Note)
+	})
+
+	t.Run("testing delete truncate dan tombstone", func(t *testing.T) {
+		cdc, err := decoder.Decode([]byte(`{"before": {"id": 7}, "after": null, "source": {"schema": "public", "table": "others"}, "op": "d"}`))
+		assert.Nil(t, err)
+		assert.Equal(t, stat_replica.CdcDelete, cdc.ModType)
+		assert.Equal(t, map[string]interface{}{"id": int64(7)}, cdc.Data)
+
+		cdc, err = decoder.Decode([]byte(`{"source": {"schema": "public", "table": "expenses"}, "op": "t"}`))
+		assert.Nil(t, err)
+		assert.True(t, cdc.IsControl())
+		assert.Equal(t, meta, cdc.Data.(*stat_replica.TruncateData).Relations[0])
+
+		cdc, err = decoder.Decode([]byte("null"))
+		assert.Nil(t, err)
+		assert.Nil(t, cdc)
+	})
+
+	t.Run("testing decimal negatif", func(t *testing.T) {
+		event := map[string]interface{}{
+			"schema": map[string]interface{}{"fields": []interface{}{
+				map[string]interface{}{"field": "after", "fields": []interface{}{
+					map[string]interface{}{"field": "amount", "name": "org.apache.kafka.connect.data.Decimal", "parameters": map[string]string{"scale": "1"}},
+				}},
+			}},
+			"payload": map[string]interface{}{
+				"after":  map[string]interface{}{"amount": "/w=="},
+				"source": map[string]interface{}{"schema": "public", "table": "others"},
+				"op":     "c",
+			},
+		}
+		raw, _ := json.Marshal(event)
+
+		cdc, err := decoder.Decode(raw)
+		assert.Nil(t, err)
+		num := cdc.Data.(map[string]interface{})["amount"].(pgtype.Numeric)
+		f, err := num.Float64Value()
+		assert.Nil(t, err)
+		assert.Equal(t, -0.1, f.Float64)
+	})
+
+	t.Run("testing source dari reader", func(t *testing.T) {
+		lines := strings.Join([]string{
+			`{"after": {"id": 1}, "source": {"schema": "public", "table": "expenses"}, "op": "r"}`,
+			``,
+			`{"after": {"id": 2}, "source": {"schema": "public", "table": "expenses"}, "op": "c"}`,
+		}, "\n")
+
+		msgs := []*stat_replica.CdcMessage{}
+		err := stat_replica.NewDebeziumSource(ctx, strings.NewReader(lines)).Start(func(msg *stat_replica.CdcMessage) {
+			msgs = append(msgs, msg)
+		})
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 2)
+		assert.Equal(t, stat_replica.CdcBackfill, msgs[0].ModType)
+		assert.Equal(t, uint(2), msgs[1].Data.(*DebeziumExpense).ID)
+	})
+}
@@ -0,0 +1,89 @@
+package stat_replica
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pglogrepl"
+)
+
+type ErrUnknownRelation struct {
+	RelationID uint32 `json:"relation_id"`
+}
+
+// Error implements error.
+func (e *ErrUnknownRelation) Error() string {
+	return fmt.Sprintf("unknown relation ID %d", e.RelationID)
+}
+
+func IsUnknownRelation(err error) bool {
+	var cerr *ErrUnknownRelation
+	return errors.As(err, &cerr)
+}
+
+type ErrDecodeColumn struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	OID    uint32 `json:"oid"`
+	Err    error  `json:"-"`
+}
+
+// Error implements error.
+func (e *ErrDecodeColumn) Error() string {
+	return fmt.Sprintf("error decoding column %s.%s.%s oid %d: %s", e.Schema, e.Table, e.Column, e.OID, e.Err)
+}
+
+func (e *ErrDecodeColumn) Unwrap() error {
+	return e.Err
+}
+
+func IsDecodeColumn(err error) bool {
+	var cerr *ErrDecodeColumn
+	return errors.As(err, &cerr)
+}
+
+type ErrCoderMapping struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Err    error  `json:"-"`
+}
+
+// Error implements error.
+func (e *ErrCoderMapping) Error() string {
+	return fmt.Sprintf("error mapping %s.%s to coder: %s", e.Schema, e.Table, e.Err)
+}
+
+func (e *ErrCoderMapping) Unwrap() error {
+	return e.Err
+}
+
+func IsCoderMapping(err error) bool {
+	var cerr *ErrCoderMapping
+	return errors.As(err, &cerr)
+}
+
+// ErrorPolicy decides what the parser does with a row it cannot decode.
+type ErrorPolicy string
+
+const (
+	ErrorPolicyFail       ErrorPolicy = "fail"
+	ErrorPolicySkip       ErrorPolicy = "skip"
+	ErrorPolicyDeadLetter ErrorPolicy = "dead_letter"
+)
+
+// DeadLetterRow is a row the parser gave up on, with the raw pgoutput message so it can be parsed again later.
+type DeadLetterRow struct {
+	SourceMetadata *SourceMetadata        `json:"source_metadata"`
+	ModType        ModificationType       `json:"mod_type"`
+	Data           map[string]interface{} `json:"data"`
+	WALData        []byte                 `json:"wal_data"`
+	Err            string                 `json:"err"`
+	Xid            uint32                 `json:"xid"`
+	CommitLSN      pglogrepl.LSN          `json:"commit_lsn"`
+	Timestamp      int64                  `json:"timestamp"`
+}
+
+type DeadLetterSink interface {
+	Send(row *DeadLetterRow) error
+}
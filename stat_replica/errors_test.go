@@ -0,0 +1,91 @@
+package stat_replica_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryDeadLetter struct {
+	rows []*stat_replica.DeadLetterRow
+}
+
+// Send implements stat_replica.DeadLetterSink.
+func (m *memoryDeadLetter) Send(row *stat_replica.DeadLetterRow) error {
+	m.rows = append(m.rows, row)
+	return nil
+}
+
+type BadStreamItem struct {
+	ID   int32
+	Name int
+}
+
+func unknownRelationInsert() []byte {
+	raw := []byte{'I'}
+	raw = binary.BigEndian.AppendUint32(raw, 404)
+	raw = append(raw, 'N')
+	raw = binary.BigEndian.AppendUint16(raw, 1)
+	raw = append(raw, 'n')
+	return raw
+}
+
+func TestParserErrorPolicy(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+
+	t.Run("testing default policy fail tidak mematikan proses", func(t *testing.T) {
+		parser := stat_replica.NewV2Parser(ctx)
+		msgs, err := parser.Parse(unknownRelationInsert())
+		assert.Nil(t, msgs)
+		assert.True(t, stat_replica.IsUnknownRelation(err))
+	})
+
+	t.Run("testing policy skip", func(t *testing.T) {
+		parser := stat_replica.NewV2Parser(ctx)
+		parser.SetErrorPolicy(stat_replica.ErrorPolicySkip, nil)
+		msgs, err := parser.Parse(unknownRelationInsert())
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("testing policy dead letter coder mapping", func(t *testing.T) {
+		ctx := stat_replica.ContextWithCoder(t.Context())
+		err := stat_replica.RegisterCoderSource(ctx,
+			&stat_replica.SourceMetadata{Table: "stream_items", Schema: "public"},
+			&BadStreamItem{},
+		)
+		assert.Nil(t, err)
+
+		sink := memoryDeadLetter{}
+		parser := stat_replica.NewV2StreamParser(ctx, stat_replica.NewStreamBuffer(t.TempDir(), stat_replica.STREAM_MEMORY_LIMIT))
+		parser.SetErrorPolicy(stat_replica.ErrorPolicyDeadLetter, &sink)
+
+		msgs := parseStreamSample(t, parser)
+		assert.Empty(t, msgs)
+		assert.Len(t, sink.rows, 3)
+
+		for _, row := range sink.rows {
+			assert.Equal(t, "stream_items", row.SourceMetadata.Table)
+			assert.Equal(t, stat_replica.CdcInsert, row.ModType)
+			assert.NotEmpty(t, row.WALData)
+			assert.NotEmpty(t, row.Data)
+			assert.NotEmpty(t, row.Err)
+		}
+		assert.Equal(t, uint32(1000), sink.rows[1].Xid)
+	})
+
+	t.Run("testing error decode kolom bawa nama tabel dan kolom", func(t *testing.T) {
+		err := &stat_replica.ErrDecodeColumn{
+			Schema: "public",
+			Table:  "order_adjustments",
+			Column: "amount",
+			OID:    1700,
+			Err:    assert.AnError,
+		}
+		assert.True(t, stat_replica.IsDecodeColumn(err))
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "public.order_adjustments.amount")
+	})
+}
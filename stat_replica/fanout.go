@@ -0,0 +1,199 @@
+package stat_replica
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pglogrepl"
+)
+
+type BackpressurePolicy string
+
+const (
+	BackpressureBlock BackpressurePolicy = "block"
+	BackpressureDrop  BackpressurePolicy = "drop"
+)
+
+// HandlerRoute scopes a handler, empty Tables or ModTypes match everything.
+type HandlerRoute struct {
+	Tables   []*SourceMetadata
+	ModTypes []ModificationType
+	// 0 dipanggil langsung di loop replication
+	BufferSize   int
+	Backpressure BackpressurePolicy
+}
+
+func (r *HandlerRoute) match(msg *CdcMessage) bool {
+	if len(r.ModTypes) > 0 {
+		found := false
+		for _, modType := range r.ModTypes {
+			if modType == msg.ModType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Tables) == 0 {
+		return true
+	}
+	if msg.SourceMetadata == nil {
+		return false
+	}
+	for _, meta := range r.Tables {
+		if meta.Schema == msg.SourceMetadata.Schema && meta.Table == msg.SourceMetadata.Table {
+			return true
+		}
+	}
+	return false
+}
+
+type fanoutItem struct {
+	msg *CdcMessage
+	// marker lsn transaksi selesai, msg kosong
+	lsn pglogrepl.LSN
+}
+
+type routedHandler struct {
+	handler ReplicationHandler
+	route   *HandlerRoute
+	items   chan *fanoutItem
+	done    atomic.Uint64
+	dropped atomic.Uint64
+}
+
+func (h *routedHandler) tracked() bool {
+	return h.items != nil && h.route.Backpressure != BackpressureDrop
+}
+
+func (h *routedHandler) send(item *fanoutItem) {
+	if h.route.Backpressure != BackpressureDrop {
+		h.items <- item
+		return
+	}
+
+	select {
+	case h.items <- item:
+	default:
+		if item.msg != nil {
+			h.dropped.Add(1)
+		}
+	}
+}
+
+func (h *routedHandler) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for item := range h.items {
+		if item.msg == nil {
+			h.done.Store(uint64(item.lsn))
+			continue
+		}
+		h.handler(item.msg)
+	}
+}
+
+// Fanout sends a message to every matching handler, so a slow buffered handler does not hold the others.
+type Fanout struct {
+	wg       sync.WaitGroup
+	handlers []*routedHandler
+	started  bool
+}
+
+func (f *Fanout) Add(handler ReplicationHandler, route *HandlerRoute) {
+	if route == nil {
+		route = &HandlerRoute{}
+	}
+	if route.Backpressure == "" {
+		route.Backpressure = BackpressureBlock
+	}
+
+	h := routedHandler{
+		handler: handler,
+		route:   route,
+	}
+	if route.BufferSize > 0 {
+		h.items = make(chan *fanoutItem, route.BufferSize)
+	}
+	f.handlers = append(f.handlers, &h)
+}
+
+// Start runs every buffered handler in its own goroutine.
+func (f *Fanout) Start() {
+	if f.started {
+		return
+	}
+	f.started = true
+
+	for _, h := range f.handlers {
+		if h.items == nil {
+			continue
+		}
+		f.wg.Add(1)
+		go h.run(&f.wg)
+	}
+}
+
+func (f *Fanout) Dispatch(msg *CdcMessage) {
+	for _, h := range f.handlers {
+		if !h.route.match(msg) {
+			continue
+		}
+		if h.items == nil {
+			h.handler(msg)
+			continue
+		}
+		h.send(&fanoutItem{msg: msg})
+	}
+}
+
+// Mark tells buffered handlers every message up to lsn has been dispatched.
+func (f *Fanout) Mark(lsn pglogrepl.LSN) {
+	for _, h := range f.handlers {
+		if h.items == nil {
+			continue
+		}
+		h.send(&fanoutItem{lsn: lsn})
+	}
+}
+
+// Confirmed returns the highest lsn every blocking handler has processed, max lsn.
+func (f *Fanout) Confirmed(lsn pglogrepl.LSN) pglogrepl.LSN {
+	for _, h := range f.handlers {
+		if !h.tracked() {
+			continue
+		}
+		done := pglogrepl.LSN(h.done.Load())
+		if done < lsn {
+			lsn = done
+		}
+	}
+	return lsn
+}
+
+// Close waits buffered handlers to drain.
+func (f *Fanout) Close() {
+	if !f.started {
+		return
+	}
+	for _, h := range f.handlers {
+		if h.items != nil {
+			close(h.items)
+		}
+	}
+	f.wg.Wait()
+
+	for _, h := range f.handlers {
+		dropped := h.dropped.Load()
+		if dropped > 0 {
+			slog.Warn("replication handler dropped messages", slog.Uint64("dropped", dropped))
+		}
+	}
+}
+
+func NewFanout() *Fanout {
+	return &Fanout{}
+}
@@ -0,0 +1,108 @@
+package stat_replica_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func tableMessage(table string, modType stat_replica.ModificationType) *stat_replica.CdcMessage {
+	return &stat_replica.CdcMessage{
+		SourceMetadata: &stat_replica.SourceMetadata{Table: table, Schema: "public"},
+		ModType:        modType,
+	}
+}
+
+func TestFanout(t *testing.T) {
+	t.Run("testing routing tabel dan mod type", func(t *testing.T) {
+		fanout := stat_replica.NewFanout()
+
+		all := 0
+		orders := 0
+		deletes := 0
+		fanout.Add(func(msg *stat_replica.CdcMessage) { all += 1 }, nil)
+		fanout.Add(func(msg *stat_replica.CdcMessage) { orders += 1 }, &stat_replica.HandlerRoute{
+			Tables: []*stat_replica.SourceMetadata{{Table: "orders", Schema: "public"}},
+		})
+		fanout.Add(func(msg *stat_replica.CdcMessage) { deletes += 1 }, &stat_replica.HandlerRoute{
+			ModTypes: []stat_replica.ModificationType{stat_replica.CdcDelete},
+		})
+
+		fanout.Start()
+		fanout.Dispatch(tableMessage("orders", stat_replica.CdcInsert))
+		fanout.Dispatch(tableMessage("orders", stat_replica.CdcDelete))
+		fanout.Dispatch(tableMessage("teams", stat_replica.CdcInsert))
+		fanout.Close()
+
+		assert.Equal(t, 3, all)
+		assert.Equal(t, 2, orders)
+		assert.Equal(t, 1, deletes)
+	})
+
+	t.Run("testing handler lambat tidak menahan handler lain", func(t *testing.T) {
+		fanout := stat_replica.NewFanout()
+
+		release := make(chan struct{})
+		var mu sync.Mutex
+		slow := 0
+		fast := 0
+
+		fanout.Add(func(msg *stat_replica.CdcMessage) {
+			<-release
+			mu.Lock()
+			slow += 1
+			mu.Unlock()
+		}, &stat_replica.HandlerRoute{BufferSize: 10})
+		fanout.Add(func(msg *stat_replica.CdcMessage) {
+			fast += 1
+		}, nil)
+
+		fanout.Start()
+		for i := 0; i < 5; i++ {
+			fanout.Dispatch(tableMessage("orders", stat_replica.CdcInsert))
+		}
+		fanout.Mark(pglogrepl.LSN(100))
+
+		assert.Equal(t, 5, fast)
+
+		t.Run("testing lsn belum dikonfirmasi sebelum handler selesai", func(t *testing.T) {
+			assert.Equal(t, pglogrepl.LSN(0), fanout.Confirmed(100))
+		})
+
+		close(release)
+		assert.Eventually(t, func() bool {
+			return fanout.Confirmed(100) == 100
+		}, time.Second, time.Millisecond)
+
+		fanout.Close()
+		assert.Equal(t, 5, slow)
+	})
+
+	t.Run("testing drop saat buffer penuh", func(t *testing.T) {
+		fanout := stat_replica.NewFanout()
+
+		release := make(chan struct{})
+		received := 0
+		fanout.Add(func(msg *stat_replica.CdcMessage) {
+			<-release
+			received += 1
+		}, &stat_replica.HandlerRoute{BufferSize: 1, Backpressure: stat_replica.BackpressureDrop})
+
+		fanout.Start()
+		for i := 0; i < 5; i++ {
+			fanout.Dispatch(tableMessage("orders", stat_replica.CdcInsert))
+		}
+		fanout.Mark(pglogrepl.LSN(100))
+
+		// handler drop tidak menahan checkpoint
+		assert.Equal(t, pglogrepl.LSN(100), fanout.Confirmed(100))
+
+		close(release)
+		fanout.Close()
+		assert.Less(t, received, 5)
+	})
+}
@@ -2,8 +2,10 @@ package stat_replica
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/jackc/pglogrepl"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -22,13 +24,31 @@ func (i *InitReplica) Initialize(slotTemporary bool) *InitReplica {
 	if err != nil {
 		return i.setErr(err)
 	}
-	if !exist {
-		slog.Info("create publication", slog.String("pubname", i.cfg.PublicationName))
-		err = i.createPublication(i.cfg.PublicationName)
+
+	switch {
+	case i.cfg.PublicationReadOnly:
+		if !exist {
+			return i.setErr(fmt.Errorf("%w: %s", ErrPublicationNotFound, i.cfg.PublicationName))
+		}
+	case !exist:
+		tables, err := i.publicationTables()
+		if err != nil {
+			return i.setErr(err)
+		}
+		slog.Info("create publication", slog.String("pubname", i.cfg.PublicationName), slog.Int("tables", len(tables)))
+		err = i.createPublication(i.cfg.PublicationName, tables)
+		if err != nil {
+			return i.setErr(err)
+		}
+	default:
+		tables, err := i.publicationTables()
+		if err != nil {
+			return i.setErr(err)
+		}
+		err = i.reconcilePublication(i.cfg.PublicationName, tables)
 		if err != nil {
 			return i.setErr(err)
 		}
-
 	}
 
 	slog.Info("check slot")
@@ -142,19 +162,209 @@ func (i *InitReplica) deletePublication(name string) error {
 	return nil
 }
 
-func (i *InitReplica) createPublication(name string) error {
-	query := fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", name)
+func (i *InitReplica) createPublication(name string, tables []*PublicationTable) error {
+	if len(tables) == 0 {
+		return i.exec(fmt.Sprintf("CREATE PUBLICATION %s FOR ALL TABLES", name))
+	}
+
+	err := i.checkPublicationFilter(tables)
+	if err != nil {
+		return err
+	}
+	return i.exec(fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", name, publicationTableClause(tables)))
+}
+
+func (i *InitReplica) publicationTables() ([]*PublicationTable, error) {
+	if len(i.cfg.Tables) > 0 {
+		return i.cfg.Tables, nil
+	}
+
+	tables, err := PublicationTables(i.ctx)
+	if errors.Is(err, ErrCoderMapperNotFound) {
+		return nil, nil
+	}
+	return tables, err
+}
+
+// reconcilePublication compares an existing publication with the configured tables. Publication yang
+// sudah ada tidak diubah, pgoutput membaca publication dari snapshot katalog historis jadi drop atau
+// set table membuat wal yang belum terkirim gagal dengan "publication does not exist" atau kehilangan
+// tabel. Tabel yang kurang hanya ditambah dengan ADD TABLE kalau cfg.PublicationAddTables.
+func (i *InitReplica) reconcilePublication(name string, tables []*PublicationTable) error {
+	if len(tables) == 0 {
+		// tidak ada tabel yang dikonfigurasi, publication lama dibiarkan
+		return nil
+	}
+
+	allTables, err := i.publicationAllTables(name)
+	if err != nil {
+		return err
+	}
+	if allTables {
+		// semua tabel sudah terpublish, column list dan row filter tidak berlaku
+		for _, table := range tables {
+			if table.filtered() {
+				slog.Warn("publication FOR ALL TABLES ignores table filter", slog.String("pubname", name), slog.String("table", table.key()))
+			}
+		}
+		return nil
+	}
+
+	current, err := i.publicationCurrentTables(name)
+	if err != nil {
+		return err
+	}
+
+	added, removed, changed := publicationChanged(current, tables)
+	if !changed {
+		return nil
+	}
+
+	if len(removed) > 0 {
+		// tabel tanpa coder tetap dibiarkan, bisa jadi dipakai consumer lain
+		slog.Info("publication has tables without coder", slog.String("pubname", name), slog.Any("tables", removed))
+	}
+	filterChanged := publicationFilterChanged(current, tables)
+	if len(filterChanged) > 0 {
+		slog.Warn("publication table filter differs, alter manually", slog.String("pubname", name), slog.Any("tables", filterChanged))
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if !i.cfg.PublicationAddTables {
+		slog.Error("publication missing tables", slog.String("pubname", name), slog.Any("tables", added))
+		return fmt.Errorf("%w: %s missing %s", ErrPublicationMismatch, name, strings.Join(added, ", "))
+	}
+
+	err = i.checkPublicationFilter(tables)
+	if err != nil {
+		return err
+	}
+
+	addTables := []*PublicationTable{}
+	missing := map[string]bool{}
+	for _, key := range added {
+		missing[key] = true
+	}
+	for _, table := range tables {
+		if missing[table.key()] {
+			addTables = append(addTables, table)
+		}
+	}
+
+	slog.Info("alter publication add table", slog.String("pubname", name), slog.Any("tables", added))
+	return i.exec(fmt.Sprintf("ALTER PUBLICATION %s ADD TABLE %s", name, publicationTableClause(addTables)))
+}
+
+func (i *InitReplica) checkPublicationFilter(tables []*PublicationTable) error {
+	filtered := false
+	for _, table := range tables {
+		if table.filtered() {
+			filtered = true
+			break
+		}
+	}
+	if !filtered {
+		return nil
+	}
+
+	version, err := i.serverVersion()
+	if err != nil {
+		return err
+	}
+	if version < 15 {
+		return ErrPublicationFilterUnsupported
+	}
+	return nil
+}
+
+func (i *InitReplica) serverVersion() (int, error) {
+	return majorVersion(i.conn.ParameterStatus("server_version"))
+}
+
+func (i *InitReplica) publicationAllTables(name string) (bool, error) {
+	rows, err := i.query(fmt.Sprintf("SELECT puballtables FROM pg_publication WHERE pubname = '%s'", name))
+	if err != nil {
+		return false, err
+	}
+	if len(rows) == 0 {
+		return false, fmt.Errorf("publication %s not found", name)
+	}
+	return string(rows[0][0]) == "t", nil
+}
+
+// publicationCurrentTables reads tables of the publication with their column list and row filter.
+func (i *InitReplica) publicationCurrentTables(name string) ([]*PublicationTable, error) {
+	version, err := i.serverVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	filterColumns := "NULL, NULL"
+	if version >= 15 {
+		filterColumns = `(
+			SELECT array_agg(a.attname ORDER BY a.attnum)
+			FROM pg_attribute a
+			WHERE a.attrelid = c.oid AND a.attnum = ANY(pr.prattrs)
+		), pg_get_expr(pr.prqual, pr.prrelid)`
+	}
+
+	rows, err := i.query(fmt.Sprintf(`
+		SELECT n.nspname, c.relname, %s
+		FROM pg_publication p
+		JOIN pg_publication_rel pr ON pr.prpubid = p.oid
+		JOIN pg_class c ON c.oid = pr.prrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE p.pubname = '%s'
+	`, filterColumns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*PublicationTable, len(rows))
+	for j, row := range rows {
+		tables[j] = &PublicationTable{
+			SourceMetadata: &SourceMetadata{
+				Schema: string(row[0]),
+				Table:  string(row[1]),
+			},
+			Columns:   parseTextArray(string(row[2])),
+			RowFilter: string(row[3]),
+		}
+	}
+	return tables, nil
+}
+
+func (i *InitReplica) exec(query string) error {
 	res := i.conn.Exec(i.ctx, query)
 	data, err := res.ReadAll()
 	if err != nil {
 		return err
 	}
-	if len(data) > 0 && data[0].Err != nil {
-		return data[0].Err
+	for _, result := range data {
+		if result.Err != nil {
+			return result.Err
+		}
 	}
 	return nil
 }
 
+func (i *InitReplica) query(query string) ([][][]byte, error) {
+	res := i.conn.Exec(i.ctx, query)
+	data, err := res.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0].Err != nil {
+		return nil, data[0].Err
+	}
+	return data[0].Rows, nil
+}
+
 func (i *InitReplica) publicationExist(name string) (bool, error) {
 	query := fmt.Sprintf("SELECT * FROM pg_publication where pubname = '%s'", name)
 	// query := "SELECT * FROM pg_publication"
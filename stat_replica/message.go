@@ -1,6 +1,10 @@
 package stat_replica
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/jackc/pglogrepl"
+)
 
 // {
 //   "sourceMetadata": {
@@ -37,8 +41,18 @@ const (
 	CdcDelete   ModificationType = "delete"
 	CdcInsert   ModificationType = "insert"
 	CdcBackfill ModificationType = "backfill"
+	CdcTruncate ModificationType = "truncate"
+	// Data berisi *SchemaChange
+	CdcSchemaChange ModificationType = "schema_change"
 )
 
+// TruncateData is the Data of a CdcTruncate message, one message is sent for every truncated relation.
+type TruncateData struct {
+	Relations       []*SourceMetadata `json:"relations"`
+	Cascade         bool              `json:"cascade"`
+	RestartIdentity bool              `json:"restart_identity"`
+}
+
 type ChangeItem struct {
 	Field string      `json:"field"`
 	Old   interface{} `json:"old"`
@@ -52,4 +66,64 @@ type CdcMessage struct {
 	OldData        interface{}      `json:"old_data"`
 	// Changes        []*ChangeItem    `json:"changes"`
 	Timestamp int64 `json:"timestamp"`
+
+	// source transaction, kosong untuk data backfill
+	Xid             uint32        `json:"xid"`
+	CommitLSN       pglogrepl.LSN `json:"commit_lsn"`
+	CommitTimestamp int64         `json:"commit_timestamp"`
+	// urutan pesan di dalam transaksi, dimulai dari 1
+	Seq uint32 `json:"seq,omitempty"`
+	// nama replication origin, kosong kalau perubahan asli dari source
+	Origin string `json:"origin,omitempty"`
+	// Present is nil when every column was sent, otherwise false marks an unchanged TOAST column.
+	Present map[string]bool `json:"present,omitempty"`
+}
+
+// IsControl is true for messages without a row in Data, ex truncate and schema change.
+func (c *CdcMessage) IsControl() bool {
+	return c.ModType == CdcTruncate || c.ModType == CdcSchemaChange
+}
+
+// Position returns the place of the message in the source log, false for backfill yang tidak punya transaksi.
+func (c *CdcMessage) Position() (Position, bool) {
+	if c.CommitLSN == 0 {
+		return Position{}, false
+	}
+	return Position{
+		LSN: c.CommitLSN,
+		Seq: c.Seq,
+	}, true
+}
+
+func (c *CdcMessage) IsPresent(column string) bool {
+	if c.Present == nil {
+		return true
+	}
+	return c.Present[column]
+}
+
+// Position identifies one message of the source, commit lsn transaksi lalu urutan di dalamnya.
+type Position struct {
+	LSN pglogrepl.LSN `json:"lsn"`
+	Seq uint32        `json:"seq"`
+}
+
+// String is fixed width hex so positions sort the same as bytes, dipakai sebagai key badger.
+func (p Position) String() string {
+	return fmt.Sprintf("%016X/%08X", uint64(p.LSN), p.Seq)
+}
+
+func (p Position) Less(other Position) bool {
+	if p.LSN != other.LSN {
+		return p.LSN < other.LSN
+	}
+	return p.Seq < other.Seq
+}
+
+// CdcTransaction is every change of one committed source transaction, in WAL order.
+type CdcTransaction struct {
+	Xid             uint32        `json:"xid"`
+	CommitLSN       pglogrepl.LSN `json:"commit_lsn"`
+	CommitTimestamp int64         `json:"commit_timestamp"`
+	Messages        []*CdcMessage `json:"messages"`
 }
@@ -2,10 +2,11 @@ package stat_replica
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"log/slog"
+	"os"
 	"reflect"
 	"strings"
 	"time"
@@ -16,7 +17,14 @@ import (
 )
 
 type Parser interface {
-	Parse(walData []byte) (*CdcMessage, error)
+	Parse(walData []byte) ([]*CdcMessage, error)
+	SetErrorPolicy(policy ErrorPolicy, sink DeadLetterSink)
+	// RegisterType adds a custom type, ex composite codec, before its TYPE message arrives.
+	RegisterType(t *pgtype.Type)
+	// SetTypeLookup resolves the types of TYPE messages, tanpa lookup custom type tidak didaftarkan.
+	SetTypeLookup(lookup TypeLookup)
+	// SetRelationStore persists relations, tanpa store relation hanya disimpan di memory.
+	SetRelationStore(store RelationStore)
 }
 
 type v2ParseImpl struct {
@@ -24,193 +32,591 @@ type v2ParseImpl struct {
 	typemap   *pgtype.Map
 	inStream  *bool
 	relations map[uint32]*pglogrepl.RelationMessageV2
+	begin     *pglogrepl.BeginMessage
+	origin    string
+	stream    StreamBuffer
+	streamXid uint32
+	seq       uint32
+
+	policy     ErrorPolicy
+	deadLetter DeadLetterSink
+	typeLookup TypeLookup
+	relStore   RelationStore
+}
+
+func (v *v2ParseImpl) setTransaction(cdc *CdcMessage) {
+	cdc.Origin = v.origin
+	if v.begin == nil {
+		return
+	}
+	v.seq += 1
+	cdc.Seq = v.seq
+	cdc.Xid = v.begin.Xid
+	cdc.CommitLSN = v.begin.FinalLSN
+	cdc.CommitTimestamp = v.begin.CommitTime.UnixMicro()
 }
 
 func (v *v2ParseImpl) convertToCoder(dataMap map[string]interface{}, cdc *CdcMessage) error {
-	code, err := GetCoder(v.ctx, cdc.SourceMetadata.PrefixKey())
+	data, err := v.mapToCoder(dataMap, cdc.SourceMetadata)
+	cdc.Data = data
+	return err
+}
+
+func (v *v2ParseImpl) mapToCoder(dataMap map[string]interface{}, meta *SourceMetadata) (interface{}, error) {
+	return mapToCoder(v.ctx, dataMap, meta)
+}
+
+// mapToCoder returns the registered coder of the table filled with dataMap, or dataMap itself when no coder registered.
+func mapToCoder(ctx context.Context, dataMap map[string]interface{}, meta *SourceMetadata) (interface{}, error) {
+	code, err := GetCoder(ctx, meta.PrefixKey())
 	if err != nil {
 		if !errors.Is(err, ErrCoderNotFound) {
-			return err
-		} else {
-			cdc.Data = dataMap
-			return nil
+			return dataMap, err
 		}
+		return dataMap, nil
 	}
-	cdc.Data = code
 
 	err = MapToStruct(dataMap, code)
 	if err != nil {
-		meta := cdc.SourceMetadata
 		slog.Error(
 			err.Error(),
 			slog.String("table", meta.Table),
 			slog.String("schema", meta.Schema),
 			slog.String("database", meta.Database),
 		)
-		return err
+		// data mentah tetap dibawa, dipakai dead letter
+		return dataMap, &ErrCoderMapping{
+			Schema: meta.Schema,
+			Table:  meta.Table,
+			Err:    err,
+		}
 	}
 
-	return nil
+	return code, nil
 }
 
 // Parse implements Parser.
-func (v *v2ParseImpl) Parse(walData []byte) (*CdcMessage, error) {
+func (v *v2ParseImpl) Parse(walData []byte) ([]*CdcMessage, error) {
 	logicalMsg, err := pglogrepl.ParseV2(walData, *v.inStream)
 	if err != nil {
 		return nil, fmt.Errorf("Parse Logical Message Failed %s", err.Error())
 	}
+
+	if *v.inStream {
+		return v.bufferStream(logicalMsg, walData)
+	}
+
 	switch logicalMsg := logicalMsg.(type) {
 	case *pglogrepl.RelationMessageV2:
-		v.relations[logicalMsg.RelationID] = logicalMsg
+		cdc, err := v.updateRelation(logicalMsg)
+		if err != nil {
+			return nil, err
+		}
+		if cdc != nil {
+			v.setTransaction(cdc)
+			return []*CdcMessage{cdc}, nil
+		}
 
 	case *pglogrepl.BeginMessage:
 		// Indicates the beginning of a group of changes in a transaction. This is only sent for committed transactions. You won't get any events from rolled back transactions.
+		v.begin = logicalMsg
+		v.origin = ""
+		v.seq = 0
 
 	case *pglogrepl.CommitMessage:
+		v.begin = nil
+		v.origin = ""
 
-	case *pglogrepl.InsertMessageV2:
-		rel, ok := v.relations[logicalMsg.RelationID]
-		if !ok {
-			log.Fatalf("unknown relation ID %d", logicalMsg.RelationID)
+	case *pglogrepl.InsertMessageV2, *pglogrepl.UpdateMessageV2, *pglogrepl.DeleteMessageV2:
+		cdc, err := v.parseChange(logicalMsg, v.relations)
+		if cdc != nil {
+			v.setTransaction(cdc)
+		}
+		if err != nil {
+			return nil, v.handleError(cdc, walData, err)
 		}
+		return []*CdcMessage{cdc}, nil
 
-		cdc := CdcMessage{
-			SourceMetadata: &SourceMetadata{
-				Table:    rel.RelationName,
-				Schema:   rel.Namespace,
-				Database: "",
-			},
-			ModType:   CdcInsert,
-			Data:      map[string]interface{}{},
-			Timestamp: time.Now().UnixMicro(),
+	case *pglogrepl.TruncateMessageV2:
+		result, err := v.parseTruncate(&logicalMsg.TruncateMessage, v.relations)
+		if err != nil {
+			return nil, v.handleError(nil, walData, err)
 		}
+		for _, cdc := range result {
+			v.setTransaction(cdc)
+		}
+		return result, nil
+
+	case *pglogrepl.TypeMessageV2:
+		v.registerTypeMessage(&logicalMsg.TypeMessage)
 
-		dataMap := map[string]interface{}{}
+	case *pglogrepl.OriginMessage:
+		v.origin = logicalMsg.Name
 
-		for idx, col := range logicalMsg.Tuple.Columns {
-			colName := rel.Columns[idx].Name
-			switch col.DataType {
-			case 'n': // null
-				dataMap[colName] = nil
-			case 'u': // unchanged toast
-				// This TOAST value was not changed. TOAST values are not stored in the tuple, and logical replication doesn't want to spend a disk read to fetch its value for you.
-			case 't': //text
-				val, err := decodeTextColumnData(v.typemap, col.Data, rel.Columns[idx].DataType)
-				if err != nil {
-					log.Fatalln("error decoding column data:", err)
-				}
-				dataMap[colName] = val
+	case *pglogrepl.LogicalDecodingMessageV2:
+		// log.Printf("Logical decoding message: %q, %q, %d", logicalMsg.Prefix, logicalMsg.Content, logicalMsg.Xid)
+
+	case *pglogrepl.StreamStartMessageV2:
+		*v.inStream = true
+		v.streamXid = logicalMsg.Xid
+		if logicalMsg.FirstSegment == 1 {
+			// sisa buffer dari koneksi sebelumnya, transaksi dikirim ulang dari awal
+			err = v.stream.Discard(logicalMsg.Xid, logicalMsg.Xid)
+			if err != nil {
+				return nil, err
 			}
 		}
 
-		err = v.convertToCoder(dataMap, &cdc)
-		return &cdc, err
+	case *pglogrepl.StreamStopMessageV2:
+		*v.inStream = false
+
+	case *pglogrepl.StreamCommitMessageV2:
+		return v.releaseStream(logicalMsg)
+
+	case *pglogrepl.StreamAbortMessageV2:
+		return nil, v.stream.Discard(logicalMsg.Xid, logicalMsg.SubXid)
+
+	default:
+		return nil, fmt.Errorf("Unknown message type in pgoutput stream: %T", logicalMsg)
+	}
+
+	return nil, nil
+}
+
+// bufferStream keeps changes of an in-progress transaction until its stream commit.
+func (v *v2ParseImpl) bufferStream(logicalMsg pglogrepl.Message, walData []byte) ([]*CdcMessage, error) {
+	switch logicalMsg := logicalMsg.(type) {
+	case *pglogrepl.StreamStopMessageV2:
+		*v.inStream = false
+		return nil, nil
+
+	case *pglogrepl.RelationMessageV2:
+		// relation dari stream baru dipakai saat stream commit, stream yang di abort tidak merubah relation
+		return nil, v.stream.Append(v.streamXid, logicalMsg.Xid, walData)
+
+	case *pglogrepl.InsertMessageV2:
+		return nil, v.stream.Append(v.streamXid, logicalMsg.Xid, walData)
 
 	case *pglogrepl.UpdateMessageV2:
+		return nil, v.stream.Append(v.streamXid, logicalMsg.Xid, walData)
+
+	case *pglogrepl.DeleteMessageV2:
+		return nil, v.stream.Append(v.streamXid, logicalMsg.Xid, walData)
+
+	case *pglogrepl.TruncateMessageV2:
+		return nil, v.stream.Append(v.streamXid, logicalMsg.Xid, walData)
+
+	case *pglogrepl.TypeMessageV2:
+		v.registerTypeMessage(&logicalMsg.TypeMessage)
+	}
+
+	return nil, nil
+}
 
-		rel, ok := v.relations[logicalMsg.RelationID]
+func (v *v2ParseImpl) releaseStream(commit *pglogrepl.StreamCommitMessageV2) ([]*CdcMessage, error) {
+	result := []*CdcMessage{}
+	var seq uint32
+	setCommit := func(cdc *CdcMessage) {
+		seq += 1
+		cdc.Seq = seq
+		cdc.Xid = commit.Xid
+		cdc.CommitLSN = commit.CommitLSN
+		cdc.CommitTimestamp = commit.CommitTime.UnixMicro()
+	}
+
+	err := v.stream.Release(commit.Xid, func(walData []byte) error {
+		logicalMsg, err := pglogrepl.ParseV2(walData, true)
+		if err != nil {
+			return fmt.Errorf("Parse Streamed Message Failed %s", err.Error())
+		}
+
+		switch msg := logicalMsg.(type) {
+		case *pglogrepl.RelationMessageV2:
+			cdc, err := v.updateRelation(msg)
+			if err != nil {
+				return err
+			}
+			if cdc != nil {
+				setCommit(cdc)
+				result = append(result, cdc)
+			}
+			return nil
+
+		case *pglogrepl.TruncateMessageV2:
+			cdcs, err := v.parseTruncate(&msg.TruncateMessage, v.relations)
+			if err != nil {
+				return v.handleError(nil, walData, err)
+			}
+			for _, cdc := range cdcs {
+				setCommit(cdc)
+			}
+			result = append(result, cdcs...)
+			return nil
+		}
+
+		cdc, err := v.parseChange(logicalMsg, v.relations)
+		if cdc != nil {
+			setCommit(cdc)
+		}
+		if err != nil {
+			return v.handleError(cdc, walData, err)
+		}
+		if cdc != nil {
+			result = append(result, cdc)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func (v *v2ParseImpl) parseTruncate(msg *pglogrepl.TruncateMessage, relations map[uint32]*pglogrepl.RelationMessageV2) ([]*CdcMessage, error) {
+	data := TruncateData{
+		Relations:       []*SourceMetadata{},
+		Cascade:         msg.Option&pglogrepl.TruncateOptionCascade != 0,
+		RestartIdentity: msg.Option&pglogrepl.TruncateOptionRestartIdentity != 0,
+	}
+
+	for _, relationID := range msg.RelationIDs {
+		rel, ok := relations[relationID]
 		if !ok {
-			log.Fatalf("unknown relation ID %d", logicalMsg.RelationID)
+			return nil, &ErrUnknownRelation{RelationID: relationID}
 		}
+		data.Relations = append(data.Relations, &SourceMetadata{
+			Table:  rel.RelationName,
+			Schema: rel.Namespace,
+		})
+	}
 
-		cdc := CdcMessage{
-			SourceMetadata: &SourceMetadata{
-				Table:    rel.RelationName,
-				Schema:   rel.Namespace,
-				Database: "",
-			},
-			ModType:   CdcUpdate,
-			Data:      map[string]interface{}{},
-			Timestamp: time.Now().UnixMicro(),
+	result := make([]*CdcMessage, len(data.Relations))
+	for i, meta := range data.Relations {
+		result[i] = &CdcMessage{
+			SourceMetadata: meta,
+			ModType:        CdcTruncate,
+			Data:           &data,
+			Timestamp:      time.Now().UnixMicro(),
+		}
+	}
+
+	return result, nil
+}
+
+// updateRelation stores the relation, returns a schema change message when its columns differ from the previous one.
+// Relation pertama setelah restart dibandingkan dengan relation terakhir di store.
+func (v *v2ParseImpl) updateRelation(rel *pglogrepl.RelationMessageV2) (*CdcMessage, error) {
+	old, ok := v.relations[rel.RelationID]
+	if !ok && v.relStore != nil {
+		stored, err := v.relStore.LastRelation(rel.RelationID)
+		if err != nil {
+			return nil, err
 		}
+		old, ok = stored, stored != nil
+	}
+	v.relations[rel.RelationID] = rel
 
-		dataMap := map[string]interface{}{}
+	if v.relStore != nil {
+		err := v.relStore.SaveRelation(rel)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		for idx, col := range logicalMsg.NewTuple.Columns {
-			colName := rel.Columns[idx].Name
-			switch col.DataType {
-			case 'n': // null
-				dataMap[colName] = nil
-			case 'u': // unchanged toast
-				// This TOAST value was not changed. TOAST values are not stored in the tuple, and logical replication doesn't want to spend a disk read to fetch its value for you.
-			case 't': //text
-				val, err := decodeTextColumnData(v.typemap, col.Data, rel.Columns[idx].DataType)
-				if err != nil {
-					log.Fatalln("error decoding column data:", err)
-				}
-				dataMap[colName] = val
+	meta := &SourceMetadata{Table: rel.RelationName, Schema: rel.Namespace}
+	columns := make([]string, len(rel.Columns))
+	for i, col := range rel.Columns {
+		columns[i] = col.Name
+	}
+
+	var report *CoderReport
+	spec, err := registeredSpec(v.ctx, meta.PrefixKey())
+	if err == nil {
+		report = spec.check(columns)
+	}
+
+	if !ok {
+		if report != nil && !report.Ok() {
+			slog.Warn("coder not match relation columns",
+				slog.String("table", rel.RelationName),
+				slog.String("schema", rel.Namespace),
+				slog.Any("unmapped", report.Unmapped),
+				slog.Any("extra", report.Extra),
+			)
+		}
+		return nil, nil
+	}
+
+	change := diffRelation(old, rel)
+	if change.Empty() {
+		return nil, nil
+	}
+
+	change.Coder = report
+	if spec != nil {
+		// kolom yang di drop atau ganti tipe tapi masih dipakai coder
+		for _, column := range change.Dropped {
+			if spec.hasColumn(column) {
+				change.CoderDrift = append(change.CoderDrift, column)
 			}
 		}
-		err = v.convertToCoder(dataMap, &cdc)
-		return &cdc, err
+		for _, col := range change.Retyped {
+			if spec.hasColumn(col.Column) {
+				change.CoderDrift = append(change.CoderDrift, col.Column)
+			}
+		}
+	}
 
-	case *pglogrepl.DeleteMessageV2:
-		rel, ok := v.relations[logicalMsg.RelationID]
-		if !ok {
-			log.Fatalf("unknown relation ID %d", logicalMsg.RelationID)
+	slog.Warn("schema change detected", slog.String("change", change.String()))
+	return &CdcMessage{
+		SourceMetadata: meta,
+		ModType:        CdcSchemaChange,
+		Data:           change,
+		Timestamp:      time.Now().UnixMicro(),
+	}, nil
+}
+
+// registerTypeMessage registers a custom type sent by pgoutput. Type message tidak membawa jenis type,
+// enum didaftarkan dengan EnumCodec, domain dengan codec base type dan composite dengan CompositeCodec dari
+// field hasil lookup. Type lain tidak didaftarkan, kolom text jadi string dan kolom binary harus di RegisterType dulu.
+func (v *v2ParseImpl) registerTypeMessage(msg *pglogrepl.TypeMessage) {
+	if _, ok := v.typemap.TypeForOID(msg.DataType); ok || v.typeLookup == nil {
+		return
+	}
+
+	_, err := v.registerType(msg.DataType)
+	if err != nil {
+		slog.Warn("type not registered", slog.String("type", msg.Name), slog.String("err", err.Error()))
+	}
+}
+
+// registerType looks up oid and registers its codec, base domain dan field composite yang belum terdaftar
+// ikut di lookup karena pgoutput hanya mengirim type message untuk type kolom.
+func (v *v2ParseImpl) registerType(oid uint32) (*pgtype.Type, error) {
+	if t, ok := v.typemap.TypeForOID(oid); ok {
+		return t, nil
+	}
+
+	info, err := v.typeLookup(v.ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+
+	var codec pgtype.Codec
+	switch info.Kind {
+	case TypeKindEnum:
+		codec = &pgtype.EnumCodec{}
+	case TypeKindDomain:
+		base, err := v.registerType(info.BaseOID)
+		if err != nil {
+			return nil, fmt.Errorf("domain %s base type %d: %w", info.Name, info.BaseOID, err)
 		}
+		codec = base.Codec
+	case TypeKindComposite:
+		fields := make([]pgtype.CompositeCodecField, len(info.Fields))
+		for i, field := range info.Fields {
+			ftype, err := v.registerType(field.OID)
+			if err != nil {
+				return nil, fmt.Errorf("composite %s field %s: %w", info.Name, field.Name, err)
+			}
+			fields[i] = pgtype.CompositeCodecField{Name: field.Name, Type: ftype}
+		}
+		codec = &pgtype.CompositeCodec{Fields: fields}
+	default:
+		return nil, fmt.Errorf("%w: %s kind %c", ErrUnsupportedType, info.Name, info.Kind)
+	}
 
-		cdc := CdcMessage{
-			SourceMetadata: &SourceMetadata{
-				Table:    rel.RelationName,
-				Schema:   rel.Namespace,
-				Database: "",
-			},
-			ModType:   CdcDelete,
-			Data:      map[string]interface{}{},
-			Timestamp: time.Now().UnixMicro(),
+	t := &pgtype.Type{
+		Name:  info.Name,
+		OID:   oid,
+		Codec: codec,
+	}
+	v.typemap.RegisterType(t)
+	return t, nil
+}
+
+// SetRelationStore implements Parser.
+func (v *v2ParseImpl) SetRelationStore(store RelationStore) {
+	v.relStore = store
+}
+
+// SetTypeLookup implements Parser.
+func (v *v2ParseImpl) SetTypeLookup(lookup TypeLookup) {
+	v.typeLookup = lookup
+}
+
+// RegisterType implements Parser.
+func (v *v2ParseImpl) RegisterType(t *pgtype.Type) {
+	v.typemap.RegisterType(t)
+}
+
+func (v *v2ParseImpl) parseChange(logicalMsg pglogrepl.Message, relations map[uint32]*pglogrepl.RelationMessageV2) (*CdcMessage, error) {
+	var relationID uint32
+	var modType ModificationType
+	var tuple *pglogrepl.TupleData
+	// old tuple hanya dipakai kalau REPLICA IDENTITY FULL, identity key cuma berisi primary key
+	var oldTuple *pglogrepl.TupleData
+
+	switch logicalMsg := logicalMsg.(type) {
+	case *pglogrepl.InsertMessageV2:
+		relationID = logicalMsg.RelationID
+		modType = CdcInsert
+		tuple = logicalMsg.Tuple
+	case *pglogrepl.UpdateMessageV2:
+		relationID = logicalMsg.RelationID
+		modType = CdcUpdate
+		tuple = logicalMsg.NewTuple
+		if logicalMsg.OldTupleType == pglogrepl.UpdateMessageTupleTypeOld {
+			oldTuple = logicalMsg.OldTuple
+		}
+	case *pglogrepl.DeleteMessageV2:
+		relationID = logicalMsg.RelationID
+		modType = CdcDelete
+		tuple = logicalMsg.OldTuple
+		if logicalMsg.OldTupleType == pglogrepl.DeleteMessageTupleTypeOld {
+			oldTuple = logicalMsg.OldTuple
 		}
+	default:
+		return nil, nil
+	}
 
-		dataMap := map[string]interface{}{}
+	rel, ok := relations[relationID]
+	if !ok {
+		return nil, &ErrUnknownRelation{RelationID: relationID}
+	}
 
-		for idx, col := range logicalMsg.OldTuple.Columns {
-			colName := rel.Columns[idx].Name
-			switch col.DataType {
-			case 'n': // null
-				dataMap[colName] = nil
-			case 'u': // unchanged toast
-				// This TOAST value was not changed. TOAST values are not stored in the tuple, and logical replication doesn't want to spend a disk read to fetch its value for you.
-			case 't': //text
-				val, err := decodeTextColumnData(v.typemap, col.Data, rel.Columns[idx].DataType)
-				if err != nil {
-					log.Fatalln("error decoding column data:", err)
+	cdc := CdcMessage{
+		SourceMetadata: &SourceMetadata{
+			Table:    rel.RelationName,
+			Schema:   rel.Namespace,
+			Database: "",
+		},
+		ModType:   modType,
+		Data:      map[string]interface{}{},
+		Timestamp: time.Now().UnixMicro(),
+	}
+
+	cdc.Present = presentMask(rel, tuple)
+	dataMap, err := v.decodeTuple(rel, tuple)
+	if err != nil {
+		cdc.Data = dataMap
+		return &cdc, err
+	}
+
+	err = v.convertToCoder(dataMap, &cdc)
+	if err != nil || oldTuple == nil {
+		return &cdc, err
+	}
+
+	oldMap, err := v.decodeTuple(rel, oldTuple)
+	if err != nil {
+		return &cdc, err
+	}
+	cdc.OldData, err = v.mapToCoder(oldMap, cdc.SourceMetadata)
+	return &cdc, err
+}
+
+func (v *v2ParseImpl) decodeTuple(rel *pglogrepl.RelationMessageV2, tuple *pglogrepl.TupleData) (map[string]interface{}, error) {
+	dataMap := map[string]interface{}{}
+	if tuple == nil {
+		return dataMap, nil
+	}
+
+	for idx, col := range tuple.Columns {
+		colName := rel.Columns[idx].Name
+		switch col.DataType {
+		case 'n': // null
+			dataMap[colName] = nil
+		case 'u': // unchanged toast
+			// This TOAST value was not changed. TOAST values are not stored in the tuple, and logical replication doesn't want to spend a disk read to fetch its value for you.
+		case 't', 'b': // text atau binary
+			var val interface{}
+			var err error
+			if col.DataType == 'b' {
+				val, err = decodeBinaryColumnData(v.typemap, col.Data, rel.Columns[idx].DataType)
+			} else {
+				val, err = decodeTextColumnData(v.typemap, col.Data, rel.Columns[idx].DataType)
+			}
+			if err != nil {
+				return dataMap, &ErrDecodeColumn{
+					Schema: rel.Namespace,
+					Table:  rel.RelationName,
+					Column: colName,
+					OID:    rel.Columns[idx].DataType,
+					Err:    err,
 				}
-				dataMap[colName] = val
 			}
+			dataMap[colName] = val
 		}
+	}
 
-		err = v.convertToCoder(dataMap, &cdc)
-		return &cdc, err
+	return dataMap, nil
+}
 
-		// ...
-	case *pglogrepl.TruncateMessageV2:
-		// log.Printf("truncate for xid %d\n", logicalMsg.Xid)
-		// ...
+func presentMask(rel *pglogrepl.RelationMessageV2, tuple *pglogrepl.TupleData) map[string]bool {
+	if tuple == nil {
+		return nil
+	}
 
-	case *pglogrepl.TypeMessageV2:
-	case *pglogrepl.OriginMessage:
+	var mask map[string]bool
+	for idx, col := range tuple.Columns {
+		if col.DataType != 'u' {
+			continue
+		}
 
-	case *pglogrepl.LogicalDecodingMessageV2:
-		// log.Printf("Logical decoding message: %q, %q, %d", logicalMsg.Prefix, logicalMsg.Content, logicalMsg.Xid)
+		if mask == nil {
+			mask = map[string]bool{}
+			for _, relcol := range rel.Columns {
+				mask[relcol.Name] = true
+			}
+		}
+		mask[rel.Columns[idx].Name] = false
+	}
 
-	case *pglogrepl.StreamStartMessageV2:
-		*v.inStream = true
-		// log.Printf("Stream start message: xid %d, first segment? %d", logicalMsg.Xid, logicalMsg.FirstSegment)
-	case *pglogrepl.StreamStopMessageV2:
-		*v.inStream = false
-		// log.Printf("Stream stop message")
-	case *pglogrepl.StreamCommitMessageV2:
-		// log.Printf("Stream commit message: xid %d", logicalMsg.Xid)
-	case *pglogrepl.StreamAbortMessageV2:
-		// log.Printf("Stream abort message: xid %d", logicalMsg.Xid)
-	default:
-		return nil, fmt.Errorf("Unknown message type in pgoutput stream: %T", logicalMsg)
+	return mask
+}
+
+// handleError applies the error policy to a row that failed to parse. returns nil when the row is dropped.
+func (v *v2ParseImpl) handleError(cdc *CdcMessage, walData []byte, err error) error {
+	switch v.policy {
+	case ErrorPolicySkip:
+		slog.Warn("skipping row", slog.String("err", err.Error()))
+		return nil
+
+	case ErrorPolicyDeadLetter:
+		if v.deadLetter == nil {
+			return err
+		}
+
+		row := DeadLetterRow{
+			WALData:   walData,
+			Err:       err.Error(),
+			Timestamp: time.Now().UnixMicro(),
+		}
+		if cdc != nil {
+			row.SourceMetadata = cdc.SourceMetadata
+			row.ModType = cdc.ModType
+			row.Xid = cdc.Xid
+			row.CommitLSN = cdc.CommitLSN
+			row.Data, _ = cdc.Data.(map[string]interface{})
+		}
+
+		slog.Warn("sending row to dead letter", slog.String("err", err.Error()))
+		return v.deadLetter.Send(&row)
 	}
 
-	return nil, nil
+	return err
+}
+
+// SetErrorPolicy implements Parser.
+func (v *v2ParseImpl) SetErrorPolicy(policy ErrorPolicy, sink DeadLetterSink) {
+	v.policy = policy
+	v.deadLetter = sink
 }
 
 func NewV2Parser(ctx context.Context) Parser {
+	return NewV2StreamParser(ctx, NewStreamBuffer(os.TempDir(), STREAM_MEMORY_LIMIT))
+}
+
+func NewV2StreamParser(ctx context.Context, stream StreamBuffer) Parser {
 	typeMap := pgtype.NewMap()
 	inStream := false
 	return &v2ParseImpl{
@@ -218,6 +624,8 @@ func NewV2Parser(ctx context.Context) Parser {
 		relations: map[uint32]*pglogrepl.RelationMessageV2{},
 		typemap:   typeMap,
 		inStream:  &inStream,
+		stream:    stream,
+		policy:    ErrorPolicyFail,
 	}
 }
 
@@ -229,24 +637,17 @@ func MapToStruct(input map[string]interface{}, output interface{}) error {
 		return fmt.Errorf("MapToStruct output must be a pointer to a struct")
 	}
 
+	spec, err := getCoderSpec(v.Type())
+	if err != nil {
+		return err
+	}
 	v = v.Elem()
-	t := v.Type()
-
-	for i := 0; i < t.NumField(); i++ {
-		structField := t.Field(i)
-		fieldName := toSnakeCase(structField.Name)
-		field := v.Field(i)
 
-		// log.Println(fieldName, t.NumField())
-		// if fieldName == "fund_at" {
-		// 	panic("asdasd")
-		// }
+	for _, coderField := range spec.fields {
+		structField := spec.typ.Field(coderField.index)
+		field := v.Field(coderField.index)
 
-		if !field.CanSet() {
-			continue
-		}
-
-		mapVal, ok := input[fieldName]
+		mapVal, ok := lookupColumn(coderField, input)
 		if !ok || mapVal == nil {
 			continue
 		}
@@ -258,21 +659,120 @@ func MapToStruct(input map[string]interface{}, output interface{}) error {
 		} else {
 			switch mapValValue.Type() {
 			case PG_NUMERIC:
-				pgnum := mapValValue.Interface().(pgtype.Numeric)
-				pgfloat, err := pgnum.Float64Value()
+				err := numericToField(mapValValue.Interface().(pgtype.Numeric), field)
 				if err != nil {
-					return err
+					return fmt.Errorf("fieldname %s: %w", coderField.column, err)
 				}
+				continue
+			}
 
-				field.Set(reflect.ValueOf(pgfloat.Float64))
+			switch mapValValue.Kind() {
+			case reflect.Map, reflect.Slice:
+				// jsonb
+				err := jsonToField(mapVal, field)
+				if err != nil {
+					return fmt.Errorf("fieldname %s: %w", coderField.column, err)
+				}
 				continue
 			}
 
 			name := mapValValue.Type().Name()
 			dstname := structField.Type.Name()
-			err := fmt.Errorf("fieldname %s %s cannot convertible to %s", fieldName, name, dstname)
+			err := fmt.Errorf("fieldname %s %s cannot convertible to %s", coderField.column, name, dstname)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func numericToField(num pgtype.Numeric, field reflect.Value) error {
+	if field.Kind() == reflect.String {
+		val, err := num.Value()
+		if err != nil {
 			return err
 		}
+		if val != nil {
+			field.SetString(val.(string))
+		}
+		return nil
+	}
+
+	pgfloat, err := num.Float64Value()
+	if err != nil {
+		return err
+	}
+	floatVal := reflect.ValueOf(pgfloat.Float64)
+	if !floatVal.Type().ConvertibleTo(field.Type()) {
+		return fmt.Errorf("numeric cannot convertible to %s", field.Type())
+	}
+	field.Set(floatVal.Convert(field.Type()))
+	return nil
+}
+
+// jsonToField fills field from a decoded json value, string dan []byte diisi json mentah.
+func jsonToField(val interface{}, field reflect.Value) error {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(string(raw))
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes(raw)
+		return nil
+	}
+
+	ptr := reflect.New(field.Type())
+	err = json.Unmarshal(raw, ptr.Interface())
+	if err != nil {
+		return err
+	}
+	field.Set(ptr.Elem())
+	return nil
+}
+
+// MergeUnchanged copies the columns not present in the message (unchanged TOAST) from old into data.
+func MergeUnchanged(data, old interface{}, present map[string]bool) error {
+	if present == nil || old == nil {
+		return nil
+	}
+
+	if datamap, ok := data.(map[string]interface{}); ok {
+		oldmap, ok := old.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot merge unchanged %T into map", old)
+		}
+		for column, isPresent := range present {
+			if !isPresent {
+				datamap[column] = oldmap[column]
+			}
+		}
+		return nil
+	}
+
+	dv := reflect.ValueOf(data)
+	ov := reflect.ValueOf(old)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Struct || dv.Type() != ov.Type() {
+		return fmt.Errorf("cannot merge unchanged %T into %T", old, data)
+	}
+
+	spec, err := getCoderSpec(dv.Type())
+	if err != nil {
+		return err
+	}
+
+	dv = dv.Elem()
+	ov = ov.Elem()
+	for _, field := range spec.fields {
+		isPresent, ok := lookupColumn(field, present)
+		if !ok || isPresent {
+			continue
+		}
+		dv.Field(field.index).Set(ov.Field(field.index))
 	}
 
 	return nil
@@ -1,10 +1,16 @@
 package stat_replica_test
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/db_models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,9 +22,9 @@ func TestBasicCrudParsing(t *testing.T) {
 
 		fname := "../test_assets/wal_samples/wal_2025-01-08.sample"
 		err = stat_replica.ExtractValuesAsBytes(fname, func(value []byte) {
-			msg, err := parser.Parse(value)
+			msgs, err := parser.Parse(value)
 			assert.Nil(t, err)
-			t.Log(msg)
+			t.Log(msgs)
 		})
 
 		assert.Nil(t, err)
@@ -84,3 +90,459 @@ func TestMapToStruct(t *testing.T) {
 	)
 
 }
+
+func TestTransactionBoundary(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	parser := stat_replica.NewV2Parser(ctx)
+	txbuf := stat_replica.NewTransactionBuffer()
+
+	txs := []*stat_replica.CdcTransaction{}
+	fname := "../test_assets/wal_samples/wal_2025-01-08.sample"
+	err := stat_replica.ExtractValuesAsBytes(fname, func(value []byte) {
+		msgs, err := parser.Parse(value)
+		assert.Nil(t, err)
+		for _, msg := range msgs {
+			txbuf.Add(msg)
+		}
+
+		if pglogrepl.MessageType(value[0]) == pglogrepl.MessageTypeCommit {
+			tx := txbuf.Flush()
+			if tx != nil {
+				txs = append(txs, tx)
+			}
+		}
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, txs)
+
+	t.Run("testing message satu transaksi punya xid dan commit lsn sama", func(t *testing.T) {
+		for _, tx := range txs {
+			assert.NotEmpty(t, tx.Messages)
+			assert.NotZero(t, tx.CommitLSN)
+			assert.NotZero(t, tx.CommitTimestamp)
+
+			for i, msg := range tx.Messages {
+				assert.Equal(t, uint32(i+1), msg.Seq)
+				assert.Equal(t, tx.Xid, msg.Xid)
+				assert.Equal(t, tx.CommitLSN, msg.CommitLSN)
+				assert.Equal(t, tx.CommitTimestamp, msg.CommitTimestamp)
+			}
+		}
+	})
+
+	t.Run("testing commit lsn urut", func(t *testing.T) {
+		for i := 1; i < len(txs); i++ {
+			assert.Greater(t, txs[i].CommitLSN, txs[i-1].CommitLSN)
+		}
+	})
+
+	t.Run("testing semua transaction handler dipanggil", func(t *testing.T) {
+		first := []uint32{}
+		second := []uint32{}
+		handlers := stat_replica.TransactionHandlers{}
+		handlers = append(handlers, func(tx *stat_replica.CdcTransaction) {
+			first = append(first, tx.Xid)
+		})
+		handlers = append(handlers, func(tx *stat_replica.CdcTransaction) {
+			second = append(second, tx.Xid)
+		})
+
+		for _, tx := range txs {
+			handlers.Handle(tx)
+		}
+		assert.Len(t, first, len(txs))
+		assert.Equal(t, first, second)
+	})
+}
+
+type StatusOrder struct {
+	ID     int32
+	Status db_models.OrdStatus
+}
+
+func appendCString(raw []byte, s string) []byte {
+	raw = append(raw, s...)
+	return append(raw, 0)
+}
+
+func statusOrderRelation() []byte {
+	relMsg := []byte{'R'}
+	relMsg = binary.BigEndian.AppendUint32(relMsg, 9100)
+	relMsg = appendCString(relMsg, "public")
+	relMsg = appendCString(relMsg, "status_orders")
+	relMsg = append(relMsg, 'f')
+	relMsg = binary.BigEndian.AppendUint16(relMsg, 2)
+	for _, col := range []struct {
+		name string
+		oid  uint32
+	}{{"id", 23}, {"status", 16500}} {
+		relMsg = append(relMsg, 0)
+		relMsg = appendCString(relMsg, col.name)
+		relMsg = binary.BigEndian.AppendUint32(relMsg, col.oid)
+		relMsg = binary.BigEndian.AppendUint32(relMsg, 0xFFFFFFFF)
+	}
+	return relMsg
+}
+
+func appendStatusOrderTuple(raw []byte, values ...string) []byte {
+	raw = binary.BigEndian.AppendUint16(raw, uint16(len(values)))
+	for _, val := range values {
+		raw = append(raw, 't')
+		raw = binary.BigEndian.AppendUint32(raw, uint32(len(val)))
+		raw = append(raw, val...)
+	}
+	return raw
+}
+
+func TestTruncateTypeOrigin(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := stat_replica.RegisterCoderSource(ctx,
+		&stat_replica.SourceMetadata{Table: "status_orders", Schema: "public"},
+		&StatusOrder{},
+	)
+	assert.Nil(t, err)
+
+	parser := stat_replica.NewV2Parser(ctx)
+
+	// type ord_status (enum), oid 16500
+	typeMsg := []byte{'Y'}
+	typeMsg = binary.BigEndian.AppendUint32(typeMsg, 16500)
+	typeMsg = appendCString(typeMsg, "public")
+	typeMsg = appendCString(typeMsg, "ord_status")
+
+	relMsg := statusOrderRelation()
+
+	originMsg := []byte{'O'}
+	originMsg = binary.BigEndian.AppendUint64(originMsg, 0x100)
+	originMsg = appendCString(originMsg, "pg_upstream")
+
+	insertMsg := []byte{'I'}
+	insertMsg = binary.BigEndian.AppendUint32(insertMsg, 9100)
+	insertMsg = append(insertMsg, 'N')
+	insertMsg = appendStatusOrderTuple(insertMsg, "7", "cancel")
+
+	truncateMsg := []byte{'T'}
+	truncateMsg = binary.BigEndian.AppendUint32(truncateMsg, 1)
+	truncateMsg = append(truncateMsg, pglogrepl.TruncateOptionCascade)
+	truncateMsg = binary.BigEndian.AppendUint32(truncateMsg, 9100)
+
+	for _, raw := range [][]byte{typeMsg, relMsg, originMsg} {
+		msgs, err := parser.Parse(raw)
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+	}
+
+	t.Run("testing enum decode ke coder", func(t *testing.T) {
+		msgs, err := parser.Parse(insertMsg)
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+
+		data := msgs[0].Data.(*StatusOrder)
+		assert.Equal(t, int32(7), data.ID)
+		assert.Equal(t, db_models.OrdCancel, data.Status)
+		assert.Equal(t, "pg_upstream", msgs[0].Origin)
+	})
+
+	t.Run("testing truncate", func(t *testing.T) {
+		msgs, err := parser.Parse(truncateMsg)
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, stat_replica.CdcTruncate, msgs[0].ModType)
+		assert.Equal(t, "status_orders", msgs[0].SourceMetadata.Table)
+
+		data := msgs[0].Data.(*stat_replica.TruncateData)
+		assert.True(t, data.Cascade)
+		assert.False(t, data.RestartIdentity)
+		assert.Len(t, data.Relations, 1)
+	})
+
+	t.Run("testing truncate relasi tidak dikenal", func(t *testing.T) {
+		raw := []byte{'T'}
+		raw = binary.BigEndian.AppendUint32(raw, 1)
+		raw = append(raw, 0)
+		raw = binary.BigEndian.AppendUint32(raw, 404)
+		_, err := parser.Parse(raw)
+		assert.True(t, stat_replica.IsUnknownRelation(err))
+	})
+}
+
+func TestReplicaIdentityFull(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := stat_replica.RegisterCoderSource(ctx,
+		&stat_replica.SourceMetadata{Table: "status_orders", Schema: "public"},
+		&StatusOrder{},
+	)
+	assert.Nil(t, err)
+
+	parser := stat_replica.NewV2Parser(ctx)
+	_, err = parser.Parse(statusOrderRelation())
+	assert.Nil(t, err)
+
+	t.Run("testing update bawa old tuple", func(t *testing.T) {
+		raw := []byte{'U'}
+		raw = binary.BigEndian.AppendUint32(raw, 9100)
+		raw = append(raw, 'O')
+		raw = appendStatusOrderTuple(raw, "7", "problem")
+		raw = append(raw, 'N')
+		raw = appendStatusOrderTuple(raw, "7", "cancel")
+
+		msgs, err := parser.Parse(raw)
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, db_models.OrdCancel, msgs[0].Data.(*StatusOrder).Status)
+		assert.Equal(t, db_models.OrdProblem, msgs[0].OldData.(*StatusOrder).Status)
+	})
+
+	t.Run("testing update identity key tanpa old data", func(t *testing.T) {
+		raw := []byte{'U'}
+		raw = binary.BigEndian.AppendUint32(raw, 9100)
+		raw = append(raw, 'K')
+		raw = binary.BigEndian.AppendUint16(raw, 2)
+		raw = append(raw, 't')
+		raw = binary.BigEndian.AppendUint32(raw, 1)
+		raw = append(raw, '8')
+		raw = append(raw, 'n')
+		raw = append(raw, 'N')
+		raw = appendStatusOrderTuple(raw, "9", "cancel")
+
+		msgs, err := parser.Parse(raw)
+		assert.Nil(t, err)
+		assert.Nil(t, msgs[0].OldData)
+	})
+
+	t.Run("testing unchanged toast ditandai tidak present", func(t *testing.T) {
+		raw := []byte{'U'}
+		raw = binary.BigEndian.AppendUint32(raw, 9100)
+		raw = append(raw, 'N')
+		raw = binary.BigEndian.AppendUint16(raw, 2)
+		raw = append(raw, 't')
+		raw = binary.BigEndian.AppendUint32(raw, 1)
+		raw = append(raw, '7')
+		raw = append(raw, 'u')
+
+		msgs, err := parser.Parse(raw)
+		assert.Nil(t, err)
+		assert.True(t, msgs[0].IsPresent("id"))
+		assert.False(t, msgs[0].IsPresent("status"))
+
+		err = stat_replica.MergeUnchanged(msgs[0].Data, &StatusOrder{ID: 7, Status: db_models.OrdProblem}, msgs[0].Present)
+		assert.Nil(t, err)
+		assert.Equal(t, db_models.OrdProblem, msgs[0].Data.(*StatusOrder).Status)
+	})
+
+	t.Run("testing delete full", func(t *testing.T) {
+		raw := []byte{'D'}
+		raw = binary.BigEndian.AppendUint32(raw, 9100)
+		raw = append(raw, 'O')
+		raw = appendStatusOrderTuple(raw, "7", "cancel")
+
+		msgs, err := parser.Parse(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, stat_replica.CdcDelete, msgs[0].ModType)
+		assert.Equal(t, int32(7), msgs[0].OldData.(*StatusOrder).ID)
+		assert.Equal(t, int32(7), msgs[0].Data.(*StatusOrder).ID)
+	})
+}
+
+type BinaryPayment struct {
+	ID     int64
+	Amount float64
+	PaidAt time.Time
+	Meta   struct {
+		Bank string `json:"bank"`
+	}
+}
+
+// kolom yang sama dibaca sebagai string dan json mentah
+type BinaryPaymentRaw struct {
+	ID     int64
+	Amount string
+	Meta   json.RawMessage
+}
+
+func TestBinaryColumn(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := stat_replica.RegisterCoders(ctx,
+		stat_replica.CoderSource[BinaryPayment](&stat_replica.SourceMetadata{Table: "payments", Schema: "public"}),
+		stat_replica.CoderSource[BinaryPaymentRaw](&stat_replica.SourceMetadata{Table: "payments_raw", Schema: "public"}),
+	)
+	assert.Nil(t, err)
+
+	parser := stat_replica.NewV2Parser(ctx)
+	typemap := pgtype.NewMap()
+	paidAt := time.Date(2025, 1, 8, 3, 0, 0, 123000, time.UTC)
+	amount := pgtype.Numeric{}
+	assert.Nil(t, amount.Scan("12345678.91"))
+
+	columns := []struct {
+		name string
+		oid  uint32
+		val  any
+	}{
+		{"id", pgtype.Int8OID, int64(7)},
+		{"amount", pgtype.NumericOID, amount},
+		{"paid_at", pgtype.TimestamptzOID, paidAt},
+		{"meta", pgtype.JSONBOID, map[string]any{"bank": "bca"}},
+	}
+
+	insertMsg := func(relID uint32, table string) []byte {
+		relColumns := []relationColumn{}
+		for _, col := range columns {
+			relColumns = append(relColumns, relationColumn{col.name, col.oid})
+		}
+		_, err := parser.Parse(relationMessage(relID, table, relColumns...))
+		assert.Nil(t, err)
+
+		raw := []byte{'I'}
+		raw = binary.BigEndian.AppendUint32(raw, relID)
+		raw = append(raw, 'N')
+		raw = binary.BigEndian.AppendUint16(raw, uint16(len(columns)))
+		for _, col := range columns {
+			buf, err := typemap.Encode(col.oid, pgtype.BinaryFormatCode, col.val, nil)
+			assert.Nil(t, err)
+			raw = append(raw, 'b')
+			raw = binary.BigEndian.AppendUint32(raw, uint32(len(buf)))
+			raw = append(raw, buf...)
+		}
+		return raw
+	}
+
+	t.Run("testing numeric timestamptz dan jsonb", func(t *testing.T) {
+		msgs, err := parser.Parse(insertMsg(9200, "payments"))
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+
+		data := msgs[0].Data.(*BinaryPayment)
+		assert.Equal(t, int64(7), data.ID)
+		assert.Equal(t, 12345678.91, data.Amount)
+		assert.True(t, paidAt.Equal(data.PaidAt))
+		assert.Equal(t, "bca", data.Meta.Bank)
+	})
+
+	t.Run("testing numeric ke string dan jsonb mentah", func(t *testing.T) {
+		msgs, err := parser.Parse(insertMsg(9201, "payments_raw"))
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+
+		data := msgs[0].Data.(*BinaryPaymentRaw)
+		assert.Equal(t, "12345678.91", data.Amount)
+		assert.JSONEq(t, `{"bank": "bca"}`, string(data.Meta))
+	})
+
+	t.Run("testing binary oid tidak dikenal", func(t *testing.T) {
+		_, err := parser.Parse(relationMessage(9202, "unknowns", relationColumn{"val", 99999}))
+		assert.Nil(t, err)
+
+		raw := []byte{'I'}
+		raw = binary.BigEndian.AppendUint32(raw, 9202)
+		raw = append(raw, 'N')
+		raw = binary.BigEndian.AppendUint16(raw, 1)
+		raw = append(raw, 'b')
+		raw = binary.BigEndian.AppendUint32(raw, 2)
+		raw = append(raw, 0, 1)
+
+		_, err = parser.Parse(raw)
+		assert.True(t, stat_replica.IsDecodeColumn(err))
+	})
+}
+
+type CustomTypeRow struct {
+	Status string `json:"status"`
+	Qty    int64  `json:"qty"`
+}
+
+func typeMessage(oid uint32, name string) []byte {
+	raw := []byte{'Y'}
+	raw = binary.BigEndian.AppendUint32(raw, oid)
+	raw = append(raw, "public"...)
+	raw = append(raw, 0)
+	raw = append(raw, name...)
+	return append(raw, 0)
+}
+
+func TestTypeMessage(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := stat_replica.RegisterCoders(ctx,
+		stat_replica.CoderSource[CustomTypeRow](&stat_replica.SourceMetadata{Table: "custom_rows", Schema: "public"}),
+	)
+	assert.Nil(t, err)
+
+	const statusOID, qtyOID, priceOID, amountOID, periodOID = 70001, 70002, 70003, 70004, 70005
+	lookups := 0
+	parser := stat_replica.NewV2Parser(ctx)
+	parser.SetTypeLookup(func(ctx context.Context, oid uint32) (*stat_replica.TypeInfo, error) {
+		lookups += 1
+		switch oid {
+		case statusOID:
+			return &stat_replica.TypeInfo{OID: oid, Name: "order_status", Kind: stat_replica.TypeKindEnum}, nil
+		case qtyOID:
+			return &stat_replica.TypeInfo{OID: oid, Name: "qty", Kind: stat_replica.TypeKindDomain, BaseOID: pgtype.Int8OID}, nil
+		case priceOID:
+			return &stat_replica.TypeInfo{OID: oid, Name: "price", Kind: stat_replica.TypeKindComposite, Fields: []*stat_replica.TypeField{
+				{Name: "currency", OID: pgtype.TextOID},
+				{Name: "amount", OID: amountOID},
+			}}, nil
+		case amountOID:
+			return &stat_replica.TypeInfo{OID: oid, Name: "amount", Kind: stat_replica.TypeKindDomain, BaseOID: pgtype.Int8OID}, nil
+		case periodOID:
+			return &stat_replica.TypeInfo{OID: oid, Name: "period", Kind: stat_replica.TypeKindRange}, nil
+		}
+		return nil, stat_replica.ErrTypeNotFound
+	})
+
+	for _, msg := range [][]byte{
+		typeMessage(statusOID, "order_status"),
+		typeMessage(qtyOID, "qty"),
+		typeMessage(statusOID, "order_status"),
+		relationMessage(9300, "custom_rows", relationColumn{"status", statusOID}, relationColumn{"qty", qtyOID}),
+	} {
+		_, err := parser.Parse(msg)
+		assert.Nil(t, err)
+	}
+	// type yang sudah terdaftar tidak di lookup lagi
+	assert.Equal(t, 2, lookups)
+
+	qty, err := pgtype.NewMap().Encode(pgtype.Int8OID, pgtype.BinaryFormatCode, int64(12), nil)
+	assert.Nil(t, err)
+
+	raw := []byte{'I'}
+	raw = binary.BigEndian.AppendUint32(raw, 9300)
+	raw = append(raw, 'N')
+	raw = binary.BigEndian.AppendUint16(raw, 2)
+	raw = append(raw, 'b')
+	raw = binary.BigEndian.AppendUint32(raw, uint32(len("paid")))
+	raw = append(raw, "paid"...)
+	raw = append(raw, 'b')
+	raw = binary.BigEndian.AppendUint32(raw, uint32(len(qty)))
+	raw = append(raw, qty...)
+
+	msgs, err := parser.Parse(raw)
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, &CustomTypeRow{Status: "paid", Qty: 12}, msgs[0].Data)
+
+	t.Run("testing composite dengan field custom type", func(t *testing.T) {
+		for _, msg := range [][]byte{
+			typeMessage(priceOID, "price"),
+			typeMessage(periodOID, "period"),
+			relationMessage(9301, "priced_rows", relationColumn{"price", priceOID}, relationColumn{"period", periodOID}),
+		} {
+			_, err := parser.Parse(msg)
+			assert.Nil(t, err)
+		}
+
+		raw := []byte{'I'}
+		raw = binary.BigEndian.AppendUint32(raw, 9301)
+		raw = append(raw, 'N')
+		raw = appendStatusOrderTuple(raw, "(IDR,15000)", "[2025-01-01,2025-02-01)")
+
+		msgs, err := parser.Parse(raw)
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+
+		data := msgs[0].Data.(map[string]interface{})
+		assert.Equal(t, map[string]any{"currency": "IDR", "amount": int64(15000)}, data["price"])
+		// range belum didukung, kolom text tetap string
+		assert.Equal(t, "[2025-01-01,2025-02-01)", data["period"])
+	})
+}
@@ -0,0 +1,109 @@
+package stat_replica
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrTypeNotFound = errors.New("type not found")
+var ErrUnsupportedType = errors.New("unsupported custom type")
+
+// TypeKind is pg_type.typtype.
+type TypeKind byte
+
+const (
+	TypeKindBase      TypeKind = 'b'
+	TypeKindComposite TypeKind = 'c'
+	TypeKindDomain    TypeKind = 'd'
+	TypeKindEnum      TypeKind = 'e'
+	TypeKindRange     TypeKind = 'r'
+)
+
+type TypeInfo struct {
+	OID  uint32
+	Name string
+	Kind TypeKind
+	// BaseOID is the base type of a domain
+	BaseOID uint32
+	// Fields are the attributes of a composite, urut attnum
+	Fields []*TypeField
+}
+
+type TypeField struct {
+	Name string
+	OID  uint32
+}
+
+// TypeLookup resolves a custom type announced by a TYPE message, message itu tidak membawa jenis dan base type.
+type TypeLookup func(ctx context.Context, oid uint32) (*TypeInfo, error)
+
+const pgTypeQuery = `SELECT typname, typtype, typbasetype, typrelid FROM pg_type WHERE oid = $1`
+
+const pgAttributeQuery = `SELECT attname, atttypid FROM pg_attribute
+WHERE attrelid = $1 AND attnum > 0 AND NOT attisdropped ORDER BY attnum`
+
+// PgTypeLookup queries pg_type, the connection is reopened after an error.
+// connect harus koneksi biasa (ConnectProdQueryDatabase), koneksi replication tidak menerima bind parameter.
+func PgTypeLookup(connect Connector) TypeLookup {
+	var conn *pgconn.PgConn
+
+	return func(ctx context.Context, oid uint32) (*TypeInfo, error) {
+		var err error
+		if conn == nil || conn.IsClosed() {
+			conn, err = connect(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		query := func(sql string, id []byte) ([][][]byte, error) {
+			result := conn.ExecParams(ctx, sql, [][]byte{id}, nil, nil, nil).Read()
+			if result.Err != nil {
+				conn.Close(context.Background())
+				conn = nil
+				return nil, result.Err
+			}
+			return result.Rows, nil
+		}
+
+		rows, err := query(pgTypeQuery, []byte(strconv.FormatUint(uint64(oid), 10)))
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 || len(rows[0][1]) == 0 {
+			return nil, ErrTypeNotFound
+		}
+
+		row := rows[0]
+		info := TypeInfo{
+			OID:  oid,
+			Name: string(row[0]),
+			Kind: TypeKind(row[1][0]),
+		}
+		base, err := strconv.ParseUint(string(row[2]), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		info.BaseOID = uint32(base)
+		if info.Kind != TypeKindComposite {
+			return &info, nil
+		}
+
+		// attribute composite ada di pg_attribute milik typrelid
+		rows, err = query(pgAttributeQuery, row[3])
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range rows {
+			fieldOID, err := strconv.ParseUint(string(attr[1]), 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			info.Fields = append(info.Fields, &TypeField{Name: string(attr[0]), OID: uint32(fieldOID)})
+		}
+		return &info, nil
+	}
+}
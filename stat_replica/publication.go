@@ -0,0 +1,201 @@
+package stat_replica
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPublicationFilterUnsupported is returned when a column list or row filter is used before postgres 15.
+var ErrPublicationFilterUnsupported = errors.New("publication column list and row filter need postgres 15")
+
+// ErrPublicationMismatch is returned when an existing publication lacks configured tables and
+// ReplicationConfig.PublicationAddTables is off.
+var ErrPublicationMismatch = errors.New("publication tables differ from configured tables")
+
+// ErrPublicationNotFound is returned when ReplicationConfig.PublicationReadOnly is set and the publication does not exist.
+var ErrPublicationNotFound = errors.New("publication not found")
+
+// PublicationTable is one table of a FOR TABLE publication.
+type PublicationTable struct {
+	SourceMetadata *SourceMetadata
+	// kosong berarti semua kolom, harus memuat kolom replica identity
+	Columns []string
+	// expression tanpa WHERE, ex "status <> 'cancel'"
+	RowFilter string
+}
+
+func (t *PublicationTable) key() string {
+	return t.SourceMetadata.PrefixKey()
+}
+
+func (t *PublicationTable) filtered() bool {
+	return len(t.Columns) > 0 || t.RowFilter != ""
+}
+
+// String returns the table clause of CREATE/ALTER PUBLICATION.
+func (t *PublicationTable) String() string {
+	meta := t.SourceMetadata
+	ident := pgx.Identifier{meta.Table}
+	if meta.Schema != "" {
+		ident = pgx.Identifier{meta.Schema, meta.Table}
+	}
+
+	clause := ident.Sanitize()
+	if len(t.Columns) > 0 {
+		columns := make([]string, len(t.Columns))
+		for i, column := range t.Columns {
+			columns[i] = pgx.Identifier{column}.Sanitize()
+		}
+		clause += " (" + strings.Join(columns, ", ") + ")"
+	}
+	if t.RowFilter != "" {
+		clause += " WHERE (" + t.RowFilter + ")"
+	}
+	return clause
+}
+
+// same compares against the table read from the catalog, row filter postgres sudah di deparse
+// jadi beda format hanya membuat publication di alter ulang.
+func (t *PublicationTable) same(other *PublicationTable) bool {
+	if normalizeRowFilter(t.RowFilter) != normalizeRowFilter(other.RowFilter) {
+		return false
+	}
+	if len(t.Columns) != len(other.Columns) {
+		return false
+	}
+
+	columns := append([]string{}, t.Columns...)
+	otherColumns := append([]string{}, other.Columns...)
+	sort.Strings(columns)
+	sort.Strings(otherColumns)
+	for i := range columns {
+		if columns[i] != otherColumns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeRowFilter(filter string) string {
+	filter = strings.Join(strings.Fields(filter), "")
+	for len(filter) > 1 && filter[0] == '(' && filter[len(filter)-1] == ')' {
+		filter = filter[1 : len(filter)-1]
+	}
+	return filter
+}
+
+// PublicationTables derives the publication tables from the coders registered in ctx.
+func PublicationTables(ctx context.Context) ([]*PublicationTable, error) {
+	sources, err := CoderSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]*PublicationTable, len(sources))
+	for i, meta := range sources {
+		tables[i] = &PublicationTable{SourceMetadata: meta}
+	}
+	return tables, nil
+}
+
+func publicationTableClause(tables []*PublicationTable) string {
+	clauses := make([]string, len(tables))
+	for i, table := range tables {
+		clauses[i] = table.String()
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// publicationChanged returns the table keys added and removed, changed is true when a filter also differs.
+func publicationChanged(current, desired []*PublicationTable) (added, removed []string, changed bool) {
+	currentMap := map[string]*PublicationTable{}
+	for _, table := range current {
+		currentMap[table.key()] = table
+	}
+
+	desiredMap := map[string]bool{}
+	for _, table := range desired {
+		desiredMap[table.key()] = true
+		exist, ok := currentMap[table.key()]
+		if !ok {
+			added = append(added, table.key())
+			changed = true
+			continue
+		}
+		if !table.same(exist) {
+			changed = true
+		}
+	}
+
+	for _, table := range current {
+		if !desiredMap[table.key()] {
+			removed = append(removed, table.key())
+			changed = true
+		}
+	}
+
+	return added, removed, changed
+}
+
+// publicationFilterChanged returns the table keys in both lists whose column list or row filter differs.
+func publicationFilterChanged(current, desired []*PublicationTable) []string {
+	currentMap := map[string]*PublicationTable{}
+	for _, table := range current {
+		currentMap[table.key()] = table
+	}
+
+	result := []string{}
+	for _, table := range desired {
+		exist, ok := currentMap[table.key()]
+		if ok && !table.same(exist) {
+			result = append(result, table.key())
+		}
+	}
+	return result
+}
+
+// parseTextArray parses text format of a one dimension array, ex {id,"user name"}.
+func parseTextArray(raw string) []string {
+	raw = strings.TrimSuffix(strings.TrimPrefix(raw, "{"), "}")
+	if raw == "" {
+		return nil
+	}
+
+	result := []string{}
+	var item strings.Builder
+	var quoted, escaped bool
+	for _, ch := range raw {
+		switch {
+		case escaped:
+			item.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '"':
+			quoted = !quoted
+		case ch == ',' && !quoted:
+			result = append(result, item.String())
+			item.Reset()
+		default:
+			item.WriteRune(ch)
+		}
+	}
+	return append(result, item.String())
+}
+
+// majorVersion reads the major version from server_version, ex "15.4 (Debian 15.4-1)".
+func majorVersion(serverVersion string) (int, error) {
+	major, _, _ := strings.Cut(serverVersion, ".")
+	major, _, _ = strings.Cut(major, " ")
+	version, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("cant parse server version %s: %w", serverVersion, err)
+	}
+	return version, nil
+}
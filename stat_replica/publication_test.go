@@ -0,0 +1,56 @@
+package stat_replica_test
+
+import (
+	"testing"
+
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+type PublicationOrder struct {
+	ID     uint   `json:"id"`
+	TeamID uint   `json:"team_id"`
+	Status string `db:"order_status"`
+}
+
+func TestPublicationTables(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+
+	err := stat_replica.RegisterCoders(ctx,
+		stat_replica.CoderSource[PublicationOrder](&stat_replica.SourceMetadata{Table: "orders", Schema: "public"}),
+		stat_replica.CoderSource[DefaultCoder](&stat_replica.SourceMetadata{Table: "adjustments", Schema: "public"}),
+	)
+	assert.Nil(t, err)
+	err = stat_replica.RegisterCoder(ctx, "default", &DefaultCoder{})
+	assert.Nil(t, err)
+
+	t.Run("testing tabel dari coder urut dan tanpa coder key", func(t *testing.T) {
+		tables, err := stat_replica.PublicationTables(ctx)
+		assert.Nil(t, err)
+		assert.Len(t, tables, 2)
+		assert.Equal(t, `"public"."adjustments"`, tables[0].String())
+		assert.Equal(t, `"public"."orders"`, tables[1].String())
+	})
+
+	t.Run("testing column list dan row filter", func(t *testing.T) {
+		meta := &stat_replica.SourceMetadata{Table: "orders", Schema: "public"}
+		columns, err := stat_replica.CoderColumns(ctx, meta)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"id", "team_id", "order_status"}, columns)
+
+		table := stat_replica.PublicationTable{
+			SourceMetadata: meta,
+			Columns:        columns,
+			RowFilter:      "order_status <> 'cancel'",
+		}
+		assert.Equal(t,
+			`"public"."orders" ("id", "team_id", "order_status") WHERE (order_status <> 'cancel')`,
+			table.String(),
+		)
+	})
+
+	t.Run("testing tanpa coder mapper", func(t *testing.T) {
+		_, err := stat_replica.PublicationTables(t.Context())
+		assert.ErrorIs(t, err, stat_replica.ErrCoderMapperNotFound)
+	})
+}
@@ -1,16 +1,220 @@
 package stat_replica
 
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Connector opens a replication connection, ex ConnectProdDatabase.
+type Connector func(ctx context.Context) (*pgconn.PgConn, error)
+
+// Replica supervises a Replication, reconnecting with exponential backoff on network errors.
 type Replica interface {
-	Start() error
+	// Configure is called on every new Replication before it starts, add handlers here.
+	Configure(handle func(rep Replication))
+	SetBackoff(initial, max time.Duration)
+	SetLagThreshold(lag uint64)
+	OnConnected(hook func(attempt int))
+	OnLagging(hook func(lag uint64))
+	OnStopped(hook func(err error))
+	// Start runs until Stop or a fatal error, handler ditambahkan ke setiap Replication setelah Configure.
+	// Handler boleh nil kalau semua handler dipasang lewat Configure.
+	Source
+	Stop()
 }
 
-type repImpl struct{}
+type repImpl struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	cfg        *ReplicationConfig
+	connect    Connector
+	checkpoint Checkpoint
+	configure  func(rep Replication)
+	handler    ReplicationHandler
+
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	lagThreshold   uint64
+
+	connected func(attempt int)
+	lagging   func(lag uint64)
+	stopped   func(err error)
+}
+
+// Configure implements Replica.
+func (r *repImpl) Configure(handle func(rep Replication)) {
+	r.configure = handle
+}
+
+// SetBackoff implements Replica.
+func (r *repImpl) SetBackoff(initial, max time.Duration) {
+	r.initialBackoff = initial
+	r.maxBackoff = max
+}
+
+// SetLagThreshold implements Replica.
+func (r *repImpl) SetLagThreshold(lag uint64) {
+	r.lagThreshold = lag
+}
+
+// OnConnected implements Replica.
+func (r *repImpl) OnConnected(hook func(attempt int)) {
+	r.connected = hook
+}
+
+// OnLagging implements Replica.
+func (r *repImpl) OnLagging(hook func(lag uint64)) {
+	r.lagging = hook
+}
+
+// OnStopped implements Replica.
+func (r *repImpl) OnStopped(hook func(err error)) {
+	r.stopped = hook
+}
+
+// Stop implements Replica.
+func (r *repImpl) Stop() {
+	r.cancel()
+}
 
 // Start implements Replica.
-func (r *repImpl) Start() error {
-	panic("unimplemented")
+func (r *repImpl) Start(handler ReplicationHandler) error {
+	r.handler = handler
+	err := r.supervise()
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+
+	slog.Info("replica stopped", slog.String("slot", r.cfg.SlotName))
+	r.stopped(err)
+	return err
+}
+
+func (r *repImpl) supervise() error {
+	backoff := r.initialBackoff
+	attempt := 0
+
+	for {
+		attempt += 1
+		started := time.Now()
+		err := r.run(attempt)
+		if r.ctx.Err() != nil {
+			return r.ctx.Err()
+		}
+		if !IsNetworkError(err) {
+			return err
+		}
+
+		// koneksi sempat jalan cukup lama, mulai backoff dari awal lagi
+		if time.Since(started) > r.maxBackoff {
+			backoff = r.initialBackoff
+		}
+
+		slog.Warn("replica disconnected, reconnecting",
+			slog.String("err", err.Error()),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+		)
+
+		select {
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+	}
+}
+
+func (r *repImpl) run(attempt int) error {
+	conn, err := r.connect(r.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	err = NewInitReplica(r.ctx, conn, r.cfg).
+		Initialize(r.cfg.SlotTemporary).
+		Err()
+	if err != nil {
+		return err
+	}
+
+	r.connected(attempt)
+
+	replication := NewReplication(r.ctx, conn, r.cfg)
+	// resume dari lsn terakhir yang sudah dikonfirmasi
+	replication.SetCheckpoint(r.checkpoint)
+	replication.AddLagHandler(func(serverWALEnd, flushed pglogrepl.LSN) {
+		if serverWALEnd <= flushed {
+			return
+		}
+		lag := uint64(serverWALEnd - flushed)
+		if lag > r.lagThreshold {
+			r.lagging(lag)
+		}
+	})
+	r.configure(replication)
+	if r.handler != nil {
+		replication.AddHandler(r.handler)
+	}
+
+	return replication.Start()
+}
+
+// IsNetworkError reports errors that are fixed by reconnecting.
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// class 08 connection exception, 57P01 admin shutdown
+		return len(pgErr.Code) == 5 && (pgErr.Code[:2] == "08" || pgErr.Code == "57P01")
+	}
+
+	return pgconn.SafeToRetry(err)
 }
 
-func NewReplica() Replica {
-	return &repImpl{}
+func NewReplica(ctx context.Context, connect Connector, cfg *ReplicationConfig, checkpoint Checkpoint) Replica {
+	ctx, cancel := context.WithCancel(ctx)
+	return &repImpl{
+		ctx:            ctx,
+		cancel:         cancel,
+		cfg:            cfg,
+		connect:        connect,
+		checkpoint:     checkpoint,
+		configure:      func(rep Replication) {},
+		initialBackoff: time.Second,
+		maxBackoff:     time.Minute,
+		lagThreshold:   REPLICA_LAG_THRESHOLD,
+		connected:      func(attempt int) {},
+		lagging:        func(lag uint64) {},
+		stopped:        func(err error) {},
+	}
 }
@@ -0,0 +1,75 @@
+package stat_replica_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaReconnect(t *testing.T) {
+	cfg := &stat_replica.ReplicationConfig{SlotName: "test_slot", PublicationName: "test_pub"}
+
+	t.Run("testing network error dicoba ulang", func(t *testing.T) {
+		errFatal := errors.New("password authentication failed")
+		attempt := 0
+		connect := func(ctx context.Context) (*pgconn.PgConn, error) {
+			attempt += 1
+			if attempt < 3 {
+				return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+			}
+			return nil, errFatal
+		}
+
+		replica := stat_replica.NewReplica(t.Context(), connect, cfg, stat_replica.NewMemoryCheckpoint())
+		replica.SetBackoff(time.Millisecond, time.Millisecond*5)
+
+		var stopErr error
+		replica.OnStopped(func(err error) {
+			stopErr = err
+		})
+
+		err := replica.Start(nil)
+		assert.ErrorIs(t, err, errFatal)
+		assert.ErrorIs(t, stopErr, errFatal)
+		assert.Equal(t, 3, attempt)
+	})
+
+	t.Run("testing stop saat backoff", func(t *testing.T) {
+		connect := func(ctx context.Context) (*pgconn.PgConn, error) {
+			return nil, io.EOF
+		}
+
+		replica := stat_replica.NewReplica(t.Context(), connect, cfg, stat_replica.NewMemoryCheckpoint())
+		replica.SetBackoff(time.Hour, time.Hour)
+
+		stopped := false
+		replica.OnStopped(func(err error) {
+			stopped = true
+		})
+
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			replica.Stop()
+		}()
+
+		err := replica.Start(nil)
+		assert.Nil(t, err)
+		assert.True(t, stopped)
+	})
+}
+
+func TestIsNetworkError(t *testing.T) {
+	assert.True(t, stat_replica.IsNetworkError(io.ErrUnexpectedEOF))
+	assert.True(t, stat_replica.IsNetworkError(&pgconn.PgError{Code: "57P01"}))
+	assert.True(t, stat_replica.IsNetworkError(&pgconn.PgError{Code: "08006"}))
+	assert.False(t, stat_replica.IsNetworkError(&pgconn.PgError{Code: "42P01"}))
+	assert.False(t, stat_replica.IsNetworkError(&stat_replica.ErrUnknownRelation{RelationID: 1}))
+	assert.False(t, stat_replica.IsNetworkError(nil))
+}
@@ -2,8 +2,11 @@ package stat_replica
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 
 	"github.com/jackc/pglogrepl"
@@ -16,23 +19,102 @@ type ReplicationConfig struct {
 	SlotName        string
 	SlotTemporary   bool
 	PublicationName string
+	// directory for streamed transactions that outgrow STREAM_MEMORY_LIMIT, default os.TempDir()
+	StreamSpillDir string
+	// tabel publication, kosong berarti diambil dari coder yang terdaftar di context,
+	// tanpa coder publication dibuat FOR ALL TABLES
+	Tables []*PublicationTable
+	// PublicationAddTables lets Initialize run ALTER PUBLICATION ADD TABLE for configured tables that are
+	// missing, default publication yang sudah ada tidak pernah diubah
+	PublicationAddTables bool
+	// PublicationReadOnly only checks that the publication exists, dipakai saat record supaya
+	// publication production tidak dibuat atau diubah
+	PublicationReadOnly bool
+	// BinaryFormat asks pgoutput for binary columns supaya numeric dan timestamp tidak di parse dari string.
+	// Default text, binary butuh semua custom type terdaftar (SetTypeLookup atau RegisterType)
+	BinaryFormat bool
 }
 
 type ReplicationHandler func(msg *CdcMessage)
 
+// LagHandler is called on every primary keepalive.
+type LagHandler func(serverWALEnd, flushed pglogrepl.LSN)
+
 type Replication interface {
 	AddHandler(handler ReplicationHandler)
+	// AddRoutedHandler adds a handler scoped by table and mod type, with its own buffer when route.BufferSize > 0.
+	AddRoutedHandler(handler ReplicationHandler, route *HandlerRoute)
+	AddTransactionHandler(handler TransactionHandler)
+	AddLagHandler(handler LagHandler)
+	SetCheckpoint(checkpoint Checkpoint)
+	SetErrorPolicy(policy ErrorPolicy, sink DeadLetterSink)
+	RegisterType(t *pgtype.Type)
+	SetTypeLookup(lookup TypeLookup)
+	// SetRelationStore persists relations for schema change detection, SetCheckpoint memasang
+	// checkpoint sebagai store kalau checkpoint juga RelationStore.
+	SetRelationStore(store RelationStore)
+	// SetSchemaGate pauses replication on every schema change until the gate acknowledges it.
+	SetSchemaGate(gate SchemaGate)
+	// LogFile captures every XLogData to fname, bisa diputar ulang dengan NewReplay.
 	LogFile(fname string)
 	Start() error
 }
 
 type replicationImpl struct {
-	cfg     *ReplicationConfig
-	logfile string
-	ctx     context.Context
-	conn    *pgconn.PgConn
-	handler ReplicationHandler
-	parser  Parser
+	cfg         *ReplicationConfig
+	logfile     string
+	ctx         context.Context
+	conn        *pgconn.PgConn
+	fanout      *Fanout
+	txHandlers  TransactionHandlers
+	lagHandlers []LagHandler
+	txbuf       TransactionBuffer
+	parser      Parser
+	checkpoint  Checkpoint
+	gate        SchemaGate
+}
+
+// AddTransactionHandler implements Replication. transaction handler bisa lebih dari satu, sama seperti lag handler.
+func (r *replicationImpl) AddTransactionHandler(handler TransactionHandler) {
+	r.txHandlers = append(r.txHandlers, handler)
+}
+
+// AddLagHandler implements Replication. lag handler bisa lebih dari satu, ex replica dan slot monitor.
+func (r *replicationImpl) AddLagHandler(handler LagHandler) {
+	r.lagHandlers = append(r.lagHandlers, handler)
+}
+
+// SetCheckpoint implements Replication.
+func (r *replicationImpl) SetCheckpoint(checkpoint Checkpoint) {
+	r.checkpoint = checkpoint
+	if store, ok := checkpoint.(RelationStore); ok {
+		r.parser.SetRelationStore(store)
+	}
+}
+
+// SetRelationStore implements Replication.
+func (r *replicationImpl) SetRelationStore(store RelationStore) {
+	r.parser.SetRelationStore(store)
+}
+
+// SetErrorPolicy implements Replication.
+func (r *replicationImpl) SetErrorPolicy(policy ErrorPolicy, sink DeadLetterSink) {
+	r.parser.SetErrorPolicy(policy, sink)
+}
+
+// SetSchemaGate implements Replication.
+func (r *replicationImpl) SetSchemaGate(gate SchemaGate) {
+	r.gate = gate
+}
+
+// RegisterType implements Replication.
+func (r *replicationImpl) RegisterType(t *pgtype.Type) {
+	r.parser.RegisterType(t)
+}
+
+// SetTypeLookup implements Replication.
+func (r *replicationImpl) SetTypeLookup(lookup TypeLookup) {
+	r.parser.SetTypeLookup(lookup)
 }
 
 // LogFile implements Replication.
@@ -42,7 +124,12 @@ func (r *replicationImpl) LogFile(fname string) {
 
 // AddHandler implements Replication.
 func (r *replicationImpl) AddHandler(handler ReplicationHandler) {
-	r.handler = handler
+	r.fanout.Add(handler, nil)
+}
+
+// AddRoutedHandler implements Replication.
+func (r *replicationImpl) AddRoutedHandler(handler ReplicationHandler, route *HandlerRoute) {
+	r.fanout.Add(handler, route)
 }
 
 // Start implements Replication.
@@ -57,29 +144,124 @@ func (r *replicationImpl) Start() error {
 		"messages 'true'",
 		"streaming 'true'",
 	}
+	if r.cfg.BinaryFormat {
+		pluginArguments = append(pluginArguments, "binary 'true'")
+	}
 	// v2 = true
 	// sysident, err := pglogrepl.IdentifySystem(context.Background(), r.conn)
 	// if err != nil {
 	// 	return err
 	// }
-	start := pglogrepl.LSN(0)
+	start, err := r.checkpoint.LastLSN()
+	if err != nil {
+		return err
+	}
+	slog.Info("starting replication", slog.String("slot", r.cfg.SlotName), slog.String("lsn", start.String()))
+
 	err = pglogrepl.StartReplication(r.ctx, r.conn, r.cfg.SlotName, start, pglogrepl.StartReplicationOptions{PluginArgs: pluginArguments})
 	if err != nil {
 		return err
 	}
 
+	r.fanout.Start()
+	defer r.fanout.Close()
+
+	var walLog *WalWriter
+	if r.logfile != "" {
+		walLog, err = AppendWalWriter(r.logfile)
+		if err != nil {
+			return err
+		}
+		defer walLog.Close()
+	}
+
+	// clientXLogPos adalah posisi wal yang sudah diterima, markedPos posisi transaksi
+	// terakhir yang sudah dikirim ke handler, handledPos posisi yang sudah selesai
+	// diproses semua handler, committedPos posisi yang dikonfirmasi ke source
 	clientXLogPos := start
+	markedPos := start
+	handledPos := start
+	committedPos := start
+	var inTx bool
+	// transaksi stream yang belum StreamCommit, dianggap masih dalam transaksi
+	streams := openStreams{}
+
+	flush := func() error {
+		lsn := r.fanout.Confirmed(markedPos)
+		if lsn > handledPos {
+			err := r.checkpoint.Commit(lsn)
+			if err != nil {
+				return err
+			}
+			handledPos = lsn
+		}
+
+		// checkpoint sink baru konfirmasi setelah pipeline selesai
+		if confirmed, ok := r.checkpoint.(ConfirmedCheckpoint); ok {
+			lsn = confirmed.Confirmed()
+		} else {
+			lsn = handledPos
+		}
+		if lsn > committedPos {
+			committedPos = lsn
+		}
+		return nil
+	}
+
+	commit := func(lsn pglogrepl.LSN) error {
+		// wal transaksi stream yang masih terbuka belum diproses, konfirmasi ditahan di awal stream tertua
+		if oldest, ok := streams.oldest(); ok && lsn > oldest {
+			lsn = oldest
+		}
+		if lsn > markedPos {
+			r.fanout.Mark(lsn)
+			markedPos = lsn
+		}
+		return flush()
+	}
+
 	standbyMessageTimeout := time.Second * 10
 	nextStandbyMessageDeadline := time.Now().Add(standbyMessageTimeout)
 
+	sendStatus := func() error {
+		err := flush()
+		if err != nil {
+			return err
+		}
+
+		if walLog != nil {
+			err = walLog.Flush()
+			if err != nil {
+				slog.Error("flush wal log", slog.String("err", err.Error()))
+			}
+		}
+
+		status := pglogrepl.StandbyStatusUpdate{
+			WALWritePosition: clientXLogPos,
+			WALFlushPosition: committedPos,
+			WALApplyPosition: committedPos,
+		}
+		if committedPos == 0 {
+			// pglogrepl mengisi flush position dengan write position kalau kosong,
+			// belum ada transaksi yang selesai jadi jangan konfirmasi apapun
+			status.WALWritePosition = 0
+		}
+
+		err = pglogrepl.SendStandbyStatusUpdate(r.ctx, r.conn, status)
+		if err != nil {
+			return err
+		}
+		log.Printf("Sent Standby status message at %s flushed %s\n", clientXLogPos.String(), committedPos.String())
+		nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
+		return nil
+	}
+
 	for {
 		if time.Now().After(nextStandbyMessageDeadline) {
-			err = pglogrepl.SendStandbyStatusUpdate(r.ctx, r.conn, pglogrepl.StandbyStatusUpdate{WALWritePosition: clientXLogPos})
+			err = sendStatus()
 			if err != nil {
 				return err
 			}
-			log.Printf("Sent Standby status message at %s\n", clientXLogPos.String())
-			nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
 		}
 
 		ctx, cancel := context.WithDeadline(r.ctx, nextStandbyMessageDeadline)
@@ -113,6 +295,16 @@ func (r *replicationImpl) Start() error {
 			if pkm.ServerWALEnd > clientXLogPos {
 				clientXLogPos = pkm.ServerWALEnd
 			}
+			if !inTx {
+				// tidak ada transaksi yang setengah jalan, semua yang diterima sudah diproses
+				err = commit(pkm.ServerWALEnd)
+				if err != nil {
+					return err
+				}
+			}
+			for _, handler := range r.lagHandlers {
+				handler(pkm.ServerWALEnd, committedPos)
+			}
 			if pkm.ReplyRequested {
 				nextStandbyMessageDeadline = time.Time{}
 			}
@@ -123,25 +315,164 @@ func (r *replicationImpl) Start() error {
 				return err
 			}
 
-			if r.logfile != "" {
-				DumpBytesToFile(r.logfile, xld.WALData)
+			if walLog != nil {
+				err = walLog.Write(&WalFrame{
+					LSN:  xld.WALStart,
+					Time: xld.ServerTime,
+					Data: xld.WALData,
+				})
+				if err != nil {
+					slog.Error("dump wal frame", slog.String("err", err.Error()))
+				}
 			}
 
-			msg, err := r.parser.Parse(xld.WALData)
+			msgType := pglogrepl.MessageType(xld.WALData[0])
+			if msgType == pglogrepl.MessageTypeBegin {
+				inTx = true
+			}
+			err = streams.update(msgType, xld.WALData, xld.WALStart)
 			if err != nil {
 				return err
 			}
 
-			r.handler(msg)
+			msgs, err := r.parser.Parse(xld.WALData)
+			if err != nil {
+				return err
+			}
+
+			for _, msg := range msgs {
+				if msg.ModType == CdcSchemaChange && r.gate != nil {
+					err = r.holdSchemaChange(msg.Data.(*SchemaChange), standbyMessageTimeout, sendStatus)
+					if err != nil {
+						return err
+					}
+				}
+
+				r.fanout.Dispatch(msg)
+				if len(r.txHandlers) > 0 {
+					r.txbuf.Add(msg)
+				}
+			}
 
 			if xld.WALStart > clientXLogPos {
 				clientXLogPos = xld.WALStart
 			}
+
+			endLSN, ok, err := transactionEndLSN(msgType, xld.WALData)
+			if err != nil {
+				return err
+			}
+			if ok {
+				inTx = false
+				if len(r.txHandlers) > 0 {
+					tx := r.txbuf.Flush()
+					if tx != nil {
+						r.txHandlers.Handle(tx)
+					}
+				}
+
+				err = commit(endLSN)
+				if err != nil {
+					return err
+				}
+			}
 		}
 	}
 
 }
 
+// holdSchemaChange waits for the gate, standby status tetap dikirim supaya tidak kena wal_sender_timeout.
+func (r *replicationImpl) holdSchemaChange(change *SchemaChange, interval time.Duration, sendStatus func() error) error {
+	ack := r.gate.Hold(change)
+	slog.Warn("replication paused until schema change acknowledged", slog.String("change", change.String()))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		case <-ack:
+			slog.Info("schema change acknowledged", slog.String("table", change.SourceMetadata.PrefixKey()))
+			return nil
+		case <-ticker.C:
+			err := sendStatus()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openStreams keeps the first wal position of every streamed transaction not yet committed or aborted.
+type openStreams map[uint32]pglogrepl.LSN
+
+func (o openStreams) update(msgType pglogrepl.MessageType, walData []byte, walStart pglogrepl.LSN) error {
+	switch msgType {
+	case pglogrepl.MessageTypeStreamStart, pglogrepl.MessageTypeStreamCommit, pglogrepl.MessageTypeStreamAbort:
+	default:
+		return nil
+	}
+	if len(walData) < 5 {
+		return fmt.Errorf("stream message %c too short", msgType)
+	}
+	xid := binary.BigEndian.Uint32(walData[1:5])
+
+	switch msgType {
+	case pglogrepl.MessageTypeStreamStart:
+		if _, ok := o[xid]; !ok {
+			o[xid] = walStart
+		}
+	case pglogrepl.MessageTypeStreamCommit:
+		delete(o, xid)
+	case pglogrepl.MessageTypeStreamAbort:
+		if len(walData) < 9 {
+			return fmt.Errorf("stream abort message too short")
+		}
+		// abort subtransaction tidak menutup transaksi utama
+		if binary.BigEndian.Uint32(walData[5:9]) == xid {
+			delete(o, xid)
+		}
+	}
+	return nil
+}
+
+func (o openStreams) oldest() (pglogrepl.LSN, bool) {
+	var lsn pglogrepl.LSN
+	found := false
+	for _, start := range o {
+		if !found || start < lsn {
+			lsn = start
+			found = true
+		}
+	}
+	return lsn, found
+}
+
+// transactionEndLSN returns the end LSN when walData closes a transaction.
+func transactionEndLSN(msgType pglogrepl.MessageType, walData []byte) (pglogrepl.LSN, bool, error) {
+	switch msgType {
+	case pglogrepl.MessageTypeCommit:
+		commit := pglogrepl.CommitMessage{}
+		err := commit.Decode(walData[1:])
+		if err != nil {
+			return 0, false, err
+		}
+		return commit.TransactionEndLSN, true, nil
+
+	case pglogrepl.MessageTypeStreamCommit:
+		commit := pglogrepl.StreamCommitMessageV2{}
+		err := commit.DecodeV2(walData[1:], false)
+		if err != nil {
+			return 0, false, err
+		}
+		return commit.TransactionEndLSN, true, nil
+	}
+
+	return 0, false, nil
+}
+
 func decodeTextColumnData(mi *pgtype.Map, data []byte, dataType uint32) (interface{}, error) {
 	if dt, ok := mi.TypeForOID(dataType); ok {
 		return dt.Codec.DecodeValue(mi, dataType, pgtype.TextFormatCode, data)
@@ -149,13 +480,27 @@ func decodeTextColumnData(mi *pgtype.Map, data []byte, dataType uint32) (interfa
 	return string(data), nil
 }
 
+// decodeBinaryColumnData tidak punya fallback string, format binary type yang tidak dikenal tidak bisa dibaca.
+func decodeBinaryColumnData(mi *pgtype.Map, data []byte, dataType uint32) (interface{}, error) {
+	if dt, ok := mi.TypeForOID(dataType); ok {
+		return dt.Codec.DecodeValue(mi, dataType, pgtype.BinaryFormatCode, data)
+	}
+	return nil, fmt.Errorf("unknown binary type oid %d, register it with RegisterType or disable BinaryFormat", dataType)
+}
+
 func NewReplication(ctx context.Context, conn *pgconn.PgConn, cfg *ReplicationConfig) Replication {
-	parser := NewV2Parser(ctx)
+	spillDir := cfg.StreamSpillDir
+	if spillDir == "" {
+		spillDir = os.TempDir()
+	}
+	parser := NewV2StreamParser(ctx, NewStreamBuffer(spillDir, STREAM_MEMORY_LIMIT))
 	return &replicationImpl{
-		cfg:     cfg,
-		ctx:     ctx,
-		conn:    conn,
-		handler: func(msg *CdcMessage) {},
-		parser:  parser,
+		cfg:        cfg,
+		ctx:        ctx,
+		conn:       conn,
+		fanout:     NewFanout(),
+		txbuf:      NewTransactionBuffer(),
+		parser:     parser,
+		checkpoint: NewMemoryCheckpoint(),
 	}
 }
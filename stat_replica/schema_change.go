@@ -0,0 +1,156 @@
+package stat_replica
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pglogrepl"
+)
+
+type ColumnChange struct {
+	Column string `json:"column"`
+	OldOID uint32 `json:"old_oid"`
+	NewOID uint32 `json:"new_oid"`
+}
+
+// SchemaChange is the Data of a CdcSchemaChange message.
+type SchemaChange struct {
+	RelationID     uint32          `json:"relation_id"`
+	SourceMetadata *SourceMetadata `json:"source_metadata"`
+	Added          []string        `json:"added"`
+	Dropped        []string        `json:"dropped"`
+	Retyped        []*ColumnChange `json:"retyped"`
+	// kolom yang berubah dan dipakai coder, coder perlu diupdate
+	CoderDrift []string     `json:"coder_drift"`
+	Coder      *CoderReport `json:"coder,omitempty"`
+}
+
+func (c *SchemaChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Dropped) == 0 && len(c.Retyped) == 0
+}
+
+func (c *SchemaChange) String() string {
+	return fmt.Sprintf("%s added %v dropped %v retyped %d coder drift %v",
+		c.SourceMetadata.PrefixKey(), c.Added, c.Dropped, len(c.Retyped), c.CoderDrift)
+}
+
+func diffRelation(old, rel *pglogrepl.RelationMessageV2) *SchemaChange {
+	change := SchemaChange{
+		RelationID: rel.RelationID,
+		SourceMetadata: &SourceMetadata{
+			Table:  rel.RelationName,
+			Schema: rel.Namespace,
+		},
+		Added:      []string{},
+		Dropped:    []string{},
+		Retyped:    []*ColumnChange{},
+		CoderDrift: []string{},
+	}
+
+	oldcols := map[string]uint32{}
+	for _, col := range old.Columns {
+		oldcols[col.Name] = col.DataType
+	}
+
+	newcols := map[string]bool{}
+	for _, col := range rel.Columns {
+		newcols[col.Name] = true
+		oid, ok := oldcols[col.Name]
+		switch {
+		case !ok:
+			change.Added = append(change.Added, col.Name)
+		case oid != col.DataType:
+			change.Retyped = append(change.Retyped, &ColumnChange{
+				Column: col.Name,
+				OldOID: oid,
+				NewOID: col.DataType,
+			})
+		}
+	}
+
+	for _, col := range old.Columns {
+		if !newcols[col.Name] {
+			change.Dropped = append(change.Dropped, col.Name)
+		}
+	}
+
+	return &change
+}
+
+// RelationStore keeps the last relation per oid, perubahan schema saat stream berhenti tetap terdeteksi
+// karena relation pertama setelah restart dibandingkan dengan yang tersimpan.
+type RelationStore interface {
+	// LastRelation returns nil when the relation was never seen.
+	LastRelation(relationID uint32) (*pglogrepl.RelationMessageV2, error)
+	SaveRelation(rel *pglogrepl.RelationMessageV2) error
+}
+
+// SchemaGate holds replication on a schema change until it is acknowledged.
+type SchemaGate interface {
+	// Hold returns a channel closed once the change is acknowledged.
+	Hold(change *SchemaChange) <-chan struct{}
+	Pending() []*SchemaChange
+	Ack(relationID uint32) error
+}
+
+type pendingChange struct {
+	change *SchemaChange
+	ack    chan struct{}
+}
+
+type memorySchemaGate struct {
+	sync.Mutex
+	pending map[uint32]*pendingChange
+}
+
+// Hold implements SchemaGate.
+func (g *memorySchemaGate) Hold(change *SchemaChange) <-chan struct{} {
+	g.Lock()
+	defer g.Unlock()
+
+	pending, ok := g.pending[change.RelationID]
+	if ok {
+		pending.change = change
+		return pending.ack
+	}
+
+	pending = &pendingChange{
+		change: change,
+		ack:    make(chan struct{}),
+	}
+	g.pending[change.RelationID] = pending
+	return pending.ack
+}
+
+// Pending implements SchemaGate.
+func (g *memorySchemaGate) Pending() []*SchemaChange {
+	g.Lock()
+	defer g.Unlock()
+
+	result := []*SchemaChange{}
+	for _, pending := range g.pending {
+		result = append(result, pending.change)
+	}
+	return result
+}
+
+// Ack implements SchemaGate.
+func (g *memorySchemaGate) Ack(relationID uint32) error {
+	g.Lock()
+	defer g.Unlock()
+
+	pending, ok := g.pending[relationID]
+	if !ok {
+		return fmt.Errorf("no pending schema change for relation %d", relationID)
+	}
+
+	close(pending.ack)
+	delete(g.pending, relationID)
+	return nil
+}
+
+func NewSchemaGate() SchemaGate {
+	return &memorySchemaGate{
+		pending: map[uint32]*pendingChange{},
+	}
+}
@@ -0,0 +1,238 @@
+package stat_replica_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_process/db_mock"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/pdcgo/shared/pkg/moretest"
+	"github.com/stretchr/testify/assert"
+)
+
+type relationColumn struct {
+	name string
+	oid  uint32
+}
+
+func relationMessage(relID uint32, table string, columns ...relationColumn) []byte {
+	raw := []byte{'R'}
+	raw = binary.BigEndian.AppendUint32(raw, relID)
+	raw = appendCString(raw, "public")
+	raw = appendCString(raw, table)
+	raw = append(raw, 'd')
+	raw = binary.BigEndian.AppendUint16(raw, uint16(len(columns)))
+	for _, col := range columns {
+		raw = append(raw, 0)
+		raw = appendCString(raw, col.name)
+		raw = binary.BigEndian.AppendUint32(raw, col.oid)
+		raw = binary.BigEndian.AppendUint32(raw, 0xFFFFFFFF)
+	}
+	return raw
+}
+
+func TestSchemaChange(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	err := stat_replica.RegisterCoderSource(ctx,
+		&stat_replica.SourceMetadata{Table: "status_orders", Schema: "public"},
+		&StatusOrder{},
+	)
+	assert.Nil(t, err)
+
+	parser := stat_replica.NewV2Parser(ctx)
+
+	t.Run("testing relasi pertama tidak dianggap perubahan", func(t *testing.T) {
+		msgs, err := parser.Parse(relationMessage(9100, "status_orders",
+			relationColumn{"id", 23},
+			relationColumn{"status", 25},
+			relationColumn{"note", 25},
+		))
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("testing relasi sama tidak ada event", func(t *testing.T) {
+		msgs, err := parser.Parse(relationMessage(9100, "status_orders",
+			relationColumn{"id", 23},
+			relationColumn{"status", 25},
+			relationColumn{"note", 25},
+		))
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("testing kolom tambah, drop dan ganti tipe", func(t *testing.T) {
+		msgs, err := parser.Parse(relationMessage(9100, "status_orders",
+			relationColumn{"id", 20},
+			relationColumn{"status", 25},
+			relationColumn{"created_at", 1184},
+		))
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, stat_replica.CdcSchemaChange, msgs[0].ModType)
+		assert.True(t, msgs[0].IsControl())
+
+		change := msgs[0].Data.(*stat_replica.SchemaChange)
+		assert.Equal(t, uint32(9100), change.RelationID)
+		assert.Equal(t, []string{"created_at"}, change.Added)
+		assert.Equal(t, []string{"note"}, change.Dropped)
+		assert.Len(t, change.Retyped, 1)
+		assert.Equal(t, uint32(20), change.Retyped[0].NewOID)
+		assert.Equal(t, []string{"id"}, change.CoderDrift)
+		assert.Equal(t, []string{"created_at"}, change.Coder.Unmapped)
+	})
+}
+
+func TestSchemaChangeAfterRestart(t *testing.T) {
+	var badgedb db_mock.BadgeDBMock
+
+	moretest.Suite(t, "testing relation tersimpan di checkpoint",
+		moretest.SetupListFunc{
+			db_mock.NewBadgeDBMock(&badgedb),
+		},
+		func(t *testing.T) {
+			ctx := stat_replica.ContextWithCoder(t.Context())
+			checkpoint := stat_replica.NewSinkCheckpoint(stat_replica.NewBadgeCheckpoint(badgedb.DB, "stat_slot"))
+
+			parser := stat_replica.NewV2Parser(ctx)
+			parser.SetRelationStore(checkpoint)
+			msgs, err := parser.Parse(relationMessage(9200, "status_orders",
+				relationColumn{"id", 23},
+				relationColumn{"status", 25},
+			))
+			assert.Nil(t, err)
+			assert.Empty(t, msgs)
+
+			t.Run("testing kolom ditambah saat stream berhenti", func(t *testing.T) {
+				restarted := stat_replica.NewV2Parser(ctx)
+				restarted.SetRelationStore(checkpoint)
+				msgs, err := restarted.Parse(relationMessage(9200, "status_orders",
+					relationColumn{"id", 23},
+					relationColumn{"status", 25},
+					relationColumn{"note", 25},
+				))
+				assert.Nil(t, err)
+				assert.Len(t, msgs, 1)
+
+				change := msgs[0].Data.(*stat_replica.SchemaChange)
+				assert.Equal(t, []string{"note"}, change.Added)
+			})
+
+			t.Run("testing relation sama setelah restart tidak ada event", func(t *testing.T) {
+				restarted := stat_replica.NewV2Parser(ctx)
+				restarted.SetRelationStore(checkpoint)
+				msgs, err := restarted.Parse(relationMessage(9200, "status_orders",
+					relationColumn{"id", 23},
+					relationColumn{"status", 25},
+					relationColumn{"note", 25},
+				))
+				assert.Nil(t, err)
+				assert.Empty(t, msgs)
+			})
+
+			t.Run("testing slot lain tidak tercampur", func(t *testing.T) {
+				other := stat_replica.NewBadgeCheckpoint(badgedb.DB, "other_slot").(stat_replica.RelationStore)
+				rel, err := other.LastRelation(9200)
+				assert.Nil(t, err)
+				assert.Nil(t, rel)
+			})
+		},
+	)
+}
+
+func TestSchemaGate(t *testing.T) {
+	gate := stat_replica.NewSchemaGate()
+	change := &stat_replica.SchemaChange{
+		RelationID:     9100,
+		SourceMetadata: &stat_replica.SourceMetadata{Table: "orders", Schema: "public"},
+	}
+
+	ack := gate.Hold(change)
+	assert.Len(t, gate.Pending(), 1)
+
+	select {
+	case <-ack:
+		t.Error("belum di acknowledge")
+	default:
+	}
+
+	err := gate.Ack(9100)
+	assert.Nil(t, err)
+	<-ack
+	assert.Empty(t, gate.Pending())
+
+	err = gate.Ack(9100)
+	assert.NotNil(t, err)
+}
+
+func streamMessage(msgType byte, xid uint32, body ...byte) []byte {
+	raw := []byte{msgType}
+	raw = binary.BigEndian.AppendUint32(raw, xid)
+	return append(raw, body...)
+}
+
+// inStream adds the xid of the stream after the message type.
+func inStream(xid uint32, raw []byte) []byte {
+	return streamMessage(raw[0], xid, raw[1:]...)
+}
+
+func TestStreamSchemaChange(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	parser := stat_replica.NewV2StreamParser(ctx, stat_replica.NewStreamBuffer(t.TempDir(), stat_replica.STREAM_MEMORY_LIMIT))
+
+	msgs, err := parser.Parse(relationMessage(9300, "stream_orders",
+		relationColumn{"id", 23},
+	))
+	assert.Nil(t, err)
+	assert.Empty(t, msgs)
+
+	streamRelation := func(xid uint32) []*stat_replica.CdcMessage {
+		result := []*stat_replica.CdcMessage{}
+		for _, raw := range [][]byte{
+			streamMessage('S', xid, 1),
+			inStream(xid, relationMessage(9300, "stream_orders",
+				relationColumn{"id", 23},
+				relationColumn{"note", 25},
+			)),
+			{'E'},
+		} {
+			msgs, err := parser.Parse(raw)
+			assert.Nil(t, err)
+			result = append(result, msgs...)
+		}
+		return result
+	}
+
+	t.Run("testing relation stream yang di abort dibuang", func(t *testing.T) {
+		assert.Empty(t, streamRelation(2000))
+
+		msgs, err := parser.Parse(streamMessage('A', 2000, binary.BigEndian.AppendUint32(nil, 2000)...))
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+
+		msgs, err = parser.Parse(relationMessage(9300, "stream_orders",
+			relationColumn{"id", 23},
+		))
+		assert.Nil(t, err)
+		assert.Empty(t, msgs)
+	})
+
+	t.Run("testing schema change keluar saat stream commit", func(t *testing.T) {
+		assert.Empty(t, streamRelation(2001))
+
+		commit := []byte{0}
+		commit = binary.BigEndian.AppendUint64(commit, 0x500)
+		commit = binary.BigEndian.AppendUint64(commit, 0x510)
+		commit = binary.BigEndian.AppendUint64(commit, 0)
+		msgs, err := parser.Parse(streamMessage('c', 2001, commit...))
+		assert.Nil(t, err)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, stat_replica.CdcSchemaChange, msgs[0].ModType)
+		assert.Equal(t, uint32(2001), msgs[0].Xid)
+		assert.Equal(t, pglogrepl.LSN(0x500), msgs[0].CommitLSN)
+
+		change := msgs[0].Data.(*stat_replica.SchemaChange)
+		assert.Equal(t, []string{"note"}, change.Added)
+	})
+}
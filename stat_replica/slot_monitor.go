@@ -0,0 +1,306 @@
+package stat_replica
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrSlotNotFound = errors.New("replication slot not found")
+
+// ErrSlotInUse is returned when another consumer holds the slot or already confirmed past our checkpoint,
+// dua consumer dengan checkpoint masing masing di satu slot saling melewatkan wal.
+var ErrSlotInUse = errors.New("replication slot used by another consumer")
+
+// CheckSlotOwner refuses a slot that is active or whose confirmed_flush_lsn is past lsn, lsn adalah
+// checkpoint consumer ini. Checkpoint kosong berarti mulai dari backfill jadi posisi slot tidak dicek.
+func CheckSlotOwner(ctx context.Context, query SlotQuery, slotName string, lsn pglogrepl.LSN) error {
+	stats, err := query(ctx, slotName)
+	if err != nil {
+		if errors.Is(err, ErrSlotNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if stats.Active {
+		return fmt.Errorf("%w: slot %s is active", ErrSlotInUse, slotName)
+	}
+	if lsn != 0 && stats.ConfirmedFlushLSN > lsn {
+		return fmt.Errorf("%w: slot %s confirmed %s past checkpoint %s", ErrSlotInUse, slotName, stats.ConfirmedFlushLSN, lsn)
+	}
+	return nil
+}
+
+type SlotStats struct {
+	SlotName          string        `json:"slot_name"`
+	Active            bool          `json:"active"`
+	State             string        `json:"state"`
+	ConfirmedFlushLSN pglogrepl.LSN `json:"confirmed_flush_lsn"`
+	CurrentWALLSN     pglogrepl.LSN `json:"current_wal_lsn"`
+	RetainedBytes     int64         `json:"retained_bytes"`
+	// dari keepalive replication
+	ServerWALEnd pglogrepl.LSN `json:"server_wal_end"`
+	FlushedLSN   pglogrepl.LSN `json:"flushed_lsn"`
+	ConsumerLag  uint64        `json:"consumer_lag"`
+	CheckedAt    time.Time     `json:"checked_at"`
+}
+
+// SlotThreshold zero value disables the check.
+type SlotThreshold struct {
+	RetainedBytes int64
+	ConsumerLag   uint64
+	Inactive      time.Duration
+}
+
+type SlotAlert struct {
+	Reason string    `json:"reason"`
+	Stats  SlotStats `json:"stats"`
+}
+
+// SlotQuery reads the slot state from the source database.
+type SlotQuery func(ctx context.Context, slotName string) (*SlotStats, error)
+
+type SlotMonitor interface {
+	// Observe is a LagHandler, add it to the Replication.
+	Observe(serverWALEnd, flushed pglogrepl.LSN)
+	Check() (*SlotStats, error)
+	Stats() SlotStats
+	OnAlert(hook func(alert *SlotAlert))
+	// Start checks the slot every interval until ctx done, a failed check is only logged.
+	Start(interval time.Duration)
+}
+
+var slotMetrics = expvar.NewMap("stat_replica_slot")
+
+type slotMonitorImpl struct {
+	sync.Mutex
+	ctx           context.Context
+	query         SlotQuery
+	slotName      string
+	threshold     *SlotThreshold
+	stats         SlotStats
+	inactiveSince time.Time
+	alert         func(alert *SlotAlert)
+}
+
+// Observe implements SlotMonitor.
+func (m *slotMonitorImpl) Observe(serverWALEnd, flushed pglogrepl.LSN) {
+	m.Lock()
+	m.stats.ServerWALEnd = serverWALEnd
+	m.stats.FlushedLSN = flushed
+	m.stats.ConsumerLag = lsnDiff(serverWALEnd, flushed)
+	m.Unlock()
+
+	m.publish()
+}
+
+// Check implements SlotMonitor.
+func (m *slotMonitorImpl) Check() (*SlotStats, error) {
+	result, err := m.query(m.ctx, m.slotName)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock()
+	m.stats.SlotName = m.slotName
+	m.stats.Active = result.Active
+	m.stats.State = result.State
+	m.stats.ConfirmedFlushLSN = result.ConfirmedFlushLSN
+	m.stats.CurrentWALLSN = result.CurrentWALLSN
+	m.stats.RetainedBytes = result.RetainedBytes
+	m.stats.CheckedAt = time.Now()
+	if m.stats.ServerWALEnd < result.CurrentWALLSN {
+		// keepalive belum datang, pakai posisi wal server dari query
+		m.stats.ServerWALEnd = result.CurrentWALLSN
+	}
+	m.stats.ConsumerLag = lsnDiff(m.stats.ServerWALEnd, result.ConfirmedFlushLSN)
+
+	switch {
+	case result.Active:
+		m.inactiveSince = time.Time{}
+	case m.inactiveSince.IsZero():
+		m.inactiveSince = m.stats.CheckedAt
+	}
+
+	stats := m.stats
+	alerts := m.alerts(&stats)
+	m.Unlock()
+
+	m.publish()
+	for _, alert := range alerts {
+		slog.Warn("replication slot alert", slog.String("slot", m.slotName), slog.String("reason", alert.Reason))
+		m.alert(alert)
+	}
+
+	return &stats, nil
+}
+
+func (m *slotMonitorImpl) alerts(stats *SlotStats) []*SlotAlert {
+	alerts := []*SlotAlert{}
+	th := m.threshold
+
+	if th.RetainedBytes > 0 && stats.RetainedBytes > th.RetainedBytes {
+		alerts = append(alerts, &SlotAlert{
+			Reason: fmt.Sprintf("retained wal %d bytes over %d", stats.RetainedBytes, th.RetainedBytes),
+			Stats:  *stats,
+		})
+	}
+
+	if th.ConsumerLag > 0 && stats.ConsumerLag > th.ConsumerLag {
+		alerts = append(alerts, &SlotAlert{
+			Reason: fmt.Sprintf("consumer lag %d bytes over %d", stats.ConsumerLag, th.ConsumerLag),
+			Stats:  *stats,
+		})
+	}
+
+	if th.Inactive > 0 && !m.inactiveSince.IsZero() && stats.CheckedAt.Sub(m.inactiveSince) >= th.Inactive {
+		alerts = append(alerts, &SlotAlert{
+			Reason: fmt.Sprintf("slot inactive since %s", m.inactiveSince.Format(time.RFC3339)),
+			Stats:  *stats,
+		})
+	}
+
+	return alerts
+}
+
+func (m *slotMonitorImpl) publish() {
+	stats := m.Stats()
+
+	active := int64(0)
+	if stats.Active {
+		active = 1
+	}
+
+	prefix := m.slotName + "."
+	setMetric(prefix+"active", active)
+	setMetric(prefix+"retained_bytes", stats.RetainedBytes)
+	setMetric(prefix+"consumer_lag", int64(stats.ConsumerLag))
+	setMetric(prefix+"confirmed_flush_lsn", int64(stats.ConfirmedFlushLSN))
+	setMetric(prefix+"server_wal_end", int64(stats.ServerWALEnd))
+}
+
+func setMetric(key string, value int64) {
+	metric, ok := slotMetrics.Get(key).(*expvar.Int)
+	if !ok {
+		metric = new(expvar.Int)
+		slotMetrics.Set(key, metric)
+	}
+	metric.Set(value)
+}
+
+// Stats implements SlotMonitor.
+func (m *slotMonitorImpl) Stats() SlotStats {
+	m.Lock()
+	defer m.Unlock()
+	return m.stats
+}
+
+// OnAlert implements SlotMonitor.
+func (m *slotMonitorImpl) OnAlert(hook func(alert *SlotAlert)) {
+	m.alert = hook
+}
+
+// Start implements SlotMonitor.
+func (m *slotMonitorImpl) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := m.Check()
+		if err != nil {
+			slog.Error("slot monitor check failed", slog.String("slot", m.slotName), slog.String("err", err.Error()))
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func lsnDiff(end, start pglogrepl.LSN) uint64 {
+	if end <= start {
+		return 0
+	}
+	return uint64(end - start)
+}
+
+func NewSlotMonitor(ctx context.Context, query SlotQuery, slotName string, threshold *SlotThreshold) SlotMonitor {
+	if threshold == nil {
+		threshold = &SlotThreshold{}
+	}
+	return &slotMonitorImpl{
+		ctx:       ctx,
+		query:     query,
+		slotName:  slotName,
+		threshold: threshold,
+		stats:     SlotStats{SlotName: slotName},
+		alert:     func(alert *SlotAlert) {},
+	}
+}
+
+const pgSlotQuery = `SELECT s.active, s.confirmed_flush_lsn, pg_current_wal_lsn(),
+	coalesce(pg_wal_lsn_diff(pg_current_wal_lsn(), s.restart_lsn), 0)::bigint, coalesce(r.state, '')
+FROM pg_replication_slots s LEFT JOIN pg_stat_replication r ON r.pid = s.active_pid
+WHERE s.slot_name = $1`
+
+// PgSlotQuery queries pg_replication_slots and pg_stat_replication, the connection is reopened after an error.
+// connect harus koneksi biasa (ConnectProdQueryDatabase), koneksi replication tidak menerima bind parameter.
+func PgSlotQuery(connect Connector) SlotQuery {
+	var conn *pgconn.PgConn
+
+	return func(ctx context.Context, slotName string) (*SlotStats, error) {
+		var err error
+		if conn == nil || conn.IsClosed() {
+			conn, err = connect(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result := conn.ExecParams(ctx, pgSlotQuery, [][]byte{[]byte(slotName)}, nil, nil, nil).Read()
+		if result.Err != nil {
+			conn.Close(context.Background())
+			conn = nil
+			return nil, result.Err
+		}
+		if len(result.Rows) == 0 {
+			return nil, ErrSlotNotFound
+		}
+
+		row := result.Rows[0]
+		stats := SlotStats{
+			SlotName: slotName,
+			Active:   string(row[0]) == "t",
+			State:    string(row[4]),
+		}
+
+		// confirmed_flush_lsn null kalau slot belum pernah dipakai
+		if row[1] != nil {
+			stats.ConfirmedFlushLSN, err = pglogrepl.ParseLSN(string(row[1]))
+			if err != nil {
+				return nil, err
+			}
+		}
+		stats.CurrentWALLSN, err = pglogrepl.ParseLSN(string(row[2]))
+		if err != nil {
+			return nil, err
+		}
+		stats.RetainedBytes, err = strconv.ParseInt(string(row[3]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return &stats, nil
+	}
+}
@@ -0,0 +1,116 @@
+package stat_replica_test
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlotMonitor(t *testing.T) {
+	result := &stat_replica.SlotStats{
+		Active:            true,
+		State:             "streaming",
+		ConfirmedFlushLSN: 1000,
+		CurrentWALLSN:     1500,
+		RetainedBytes:     500,
+	}
+	query := func(ctx context.Context, slotName string) (*stat_replica.SlotStats, error) {
+		res := *result
+		return &res, nil
+	}
+
+	monitor := stat_replica.NewSlotMonitor(t.Context(), query, "monitor_slot", &stat_replica.SlotThreshold{
+		RetainedBytes: 1000,
+		ConsumerLag:   2000,
+		Inactive:      time.Nanosecond,
+	})
+
+	alerts := []*stat_replica.SlotAlert{}
+	monitor.OnAlert(func(alert *stat_replica.SlotAlert) {
+		alerts = append(alerts, alert)
+	})
+
+	t.Run("testing sehat tidak ada alert", func(t *testing.T) {
+		stats, err := monitor.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(500), stats.ConsumerLag)
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("testing lag dari keepalive", func(t *testing.T) {
+		monitor.Observe(pglogrepl.LSN(5000), pglogrepl.LSN(1000))
+		assert.Equal(t, uint64(4000), monitor.Stats().ConsumerLag)
+
+		_, err := monitor.Check()
+		assert.Nil(t, err)
+		assert.Len(t, alerts, 1)
+		assert.Contains(t, alerts[0].Reason, "consumer lag")
+	})
+
+	t.Run("testing slot tidak aktif dan wal tertahan", func(t *testing.T) {
+		alerts = alerts[:0]
+		result.Active = false
+		result.RetainedBytes = 4000
+		result.ConfirmedFlushLSN = 5000
+
+		_, err := monitor.Check()
+		assert.Nil(t, err)
+		time.Sleep(time.Millisecond)
+		_, err = monitor.Check()
+		assert.Nil(t, err)
+
+		reasons := []string{}
+		for _, alert := range alerts {
+			reasons = append(reasons, alert.Reason)
+		}
+		assert.Contains(t, reasons[len(reasons)-1], "inactive")
+	})
+
+	t.Run("testing metric expvar", func(t *testing.T) {
+		metrics := expvar.Get("stat_replica_slot").(*expvar.Map)
+		assert.Equal(t, "4000", metrics.Get("monitor_slot.retained_bytes").String())
+		assert.Equal(t, "0", metrics.Get("monitor_slot.active").String())
+	})
+}
+
+func TestCheckSlotOwner(t *testing.T) {
+	stats := &stat_replica.SlotStats{ConfirmedFlushLSN: 1000}
+	query := func(ctx context.Context, slotName string) (*stat_replica.SlotStats, error) {
+		if slotName == "missing_slot" {
+			return nil, stat_replica.ErrSlotNotFound
+		}
+		res := *stats
+		return &res, nil
+	}
+
+	t.Run("testing slot belum ada", func(t *testing.T) {
+		err := stat_replica.CheckSlotOwner(t.Context(), query, "missing_slot", 500)
+		assert.Nil(t, err)
+	})
+
+	t.Run("testing slot di belakang checkpoint", func(t *testing.T) {
+		err := stat_replica.CheckSlotOwner(t.Context(), query, "stat_slot", 1000)
+		assert.Nil(t, err)
+
+		err = stat_replica.CheckSlotOwner(t.Context(), query, "stat_slot", 0)
+		assert.Nil(t, err)
+	})
+
+	t.Run("testing slot dikonfirmasi consumer lain", func(t *testing.T) {
+		err := stat_replica.CheckSlotOwner(t.Context(), query, "stat_slot", 900)
+		assert.ErrorIs(t, err, stat_replica.ErrSlotInUse)
+	})
+
+	t.Run("testing slot sedang aktif", func(t *testing.T) {
+		stats.Active = true
+		defer func() { stats.Active = false }()
+
+		err := stat_replica.CheckSlotOwner(t.Context(), query, "stat_slot", 1000)
+		assert.ErrorIs(t, err, stat_replica.ErrSlotInUse)
+	})
+}
@@ -0,0 +1,11 @@
+package stat_replica
+
+// Source produces CdcMessage from a change log, replication slot bukan satu satunya sumber.
+type Source interface {
+	// Start sends every message to handler, returns when the source ends or ctx is done.
+	Start(handler ReplicationHandler) error
+}
+
+var _ Source = (*repImpl)(nil)
+var _ Source = (*Replay)(nil)
+var _ Source = (*DebeziumSource)(nil)
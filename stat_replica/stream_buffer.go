@@ -0,0 +1,244 @@
+package stat_replica
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// StreamBuffer holds raw pgoutput messages of in-progress (streamed) transactions until
+// the stream commit or abort arrives. messages are grouped by top level xid and keep the
+// xid of the subtransaction that produced them.
+type StreamBuffer interface {
+	Append(xid uint32, subxid uint32, walData []byte) error
+	Release(xid uint32, handle func(walData []byte) error) error
+	Discard(xid uint32, subxid uint32) error
+	Size(xid uint32) int
+}
+
+type streamEntry struct {
+	subxid  uint32
+	walData []byte
+}
+
+type streamTx struct {
+	entries []*streamEntry
+	size    int
+	count   int
+	aborted map[uint32]bool
+	file    *os.File
+	writer  *bufio.Writer
+}
+
+type streamBufferImpl struct {
+	sync.Mutex
+	spillDir    string
+	memoryLimit int
+	txs         map[uint32]*streamTx
+}
+
+// Size implements StreamBuffer.
+func (s *streamBufferImpl) Size(xid uint32) int {
+	s.Lock()
+	defer s.Unlock()
+
+	tx, ok := s.txs[xid]
+	if !ok {
+		return 0
+	}
+	return tx.count
+}
+
+// Append implements StreamBuffer.
+func (s *streamBufferImpl) Append(xid uint32, subxid uint32, walData []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	tx, ok := s.txs[xid]
+	if !ok {
+		tx = &streamTx{
+			entries: []*streamEntry{},
+			aborted: map[uint32]bool{},
+		}
+		s.txs[xid] = tx
+	}
+
+	// walData dari pglogrepl dipakai ulang, harus dicopy dulu
+	raw := make([]byte, len(walData))
+	copy(raw, walData)
+
+	tx.count += 1
+	if tx.file != nil {
+		return writeStreamEntry(tx.writer, subxid, raw)
+	}
+
+	tx.entries = append(tx.entries, &streamEntry{
+		subxid:  subxid,
+		walData: raw,
+	})
+	tx.size += len(raw)
+
+	if tx.size > s.memoryLimit {
+		return s.spill(xid, tx)
+	}
+	return nil
+}
+
+func (s *streamBufferImpl) spill(xid uint32, tx *streamTx) error {
+	err := os.MkdirAll(s.spillDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// nama file unik, beberapa proses bisa memakai spillDir yang sama. file dihapus saat commit atau abort
+	tx.file, err = os.CreateTemp(s.spillDir, fmt.Sprintf("stream_%d_*.spill", xid))
+	if err != nil {
+		return err
+	}
+	tx.writer = bufio.NewWriter(tx.file)
+
+	for _, entry := range tx.entries {
+		err = writeStreamEntry(tx.writer, entry.subxid, entry.walData)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx.entries = []*streamEntry{}
+	tx.size = 0
+	return nil
+}
+
+// Release implements StreamBuffer.
+func (s *streamBufferImpl) Release(xid uint32, handle func(walData []byte) error) error {
+	s.Lock()
+	tx, ok := s.txs[xid]
+	delete(s.txs, xid)
+	s.Unlock()
+
+	if !ok {
+		return nil
+	}
+	defer tx.close()
+
+	if tx.file != nil {
+		return tx.replayFile(handle)
+	}
+
+	for _, entry := range tx.entries {
+		if tx.aborted[entry.subxid] {
+			continue
+		}
+		err := handle(entry.walData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Discard implements StreamBuffer.
+func (s *streamBufferImpl) Discard(xid uint32, subxid uint32) error {
+	s.Lock()
+	defer s.Unlock()
+
+	tx, ok := s.txs[xid]
+	if !ok {
+		return nil
+	}
+
+	if xid == subxid {
+		delete(s.txs, xid)
+		return tx.close()
+	}
+
+	tx.aborted[subxid] = true
+	return nil
+}
+
+func (tx *streamTx) replayFile(handle func(walData []byte) error) error {
+	err := tx.writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(tx.file)
+	for {
+		subxid, walData, err := readStreamEntry(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if tx.aborted[subxid] {
+			continue
+		}
+
+		err = handle(walData)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (tx *streamTx) close() error {
+	if tx.file == nil {
+		return nil
+	}
+
+	fname := tx.file.Name()
+	tx.file.Close()
+	return os.Remove(fname)
+}
+
+func writeStreamEntry(w io.Writer, subxid uint32, walData []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], subxid)
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(walData)))
+
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(walData)
+	return err
+}
+
+func readStreamEntry(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, 8)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	subxid := binary.BigEndian.Uint32(header[0:4])
+	walData := make([]byte, binary.BigEndian.Uint32(header[4:8]))
+	_, err = io.ReadFull(r, walData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return subxid, walData, nil
+}
+
+// NewStreamBuffer keeps each streamed transaction in memory until it grows past
+// memoryLimit bytes, after that the transaction is spilled to a file in spillDir.
+func NewStreamBuffer(spillDir string, memoryLimit int) StreamBuffer {
+	return &streamBufferImpl{
+		spillDir:    spillDir,
+		memoryLimit: memoryLimit,
+		txs:         map[uint32]*streamTx{},
+	}
+}
@@ -0,0 +1,114 @@
+package stat_replica_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseStreamSample(t *testing.T, parser stat_replica.Parser) []*stat_replica.CdcMessage {
+	result := []*stat_replica.CdcMessage{}
+
+	fname := "../test_assets/wal_samples/stream_wal.sample"
+	err := stat_replica.ExtractValuesAsBytes(fname, func(value []byte) {
+		msgs, err := parser.Parse(value)
+		assert.Nil(t, err)
+		result = append(result, msgs...)
+	})
+	assert.Nil(t, err)
+
+	return result
+}
+
+func messageIDs(msgs []*stat_replica.CdcMessage) []int32 {
+	ids := []int32{}
+	for _, msg := range msgs {
+		data := msg.Data.(map[string]interface{})
+		ids = append(ids, data["id"].(int32))
+	}
+	return ids
+}
+
+func TestStreamedTransaction(t *testing.T) {
+	ctx := stat_replica.ContextWithCoder(t.Context())
+
+	t.Run("testing streaming di memory", func(t *testing.T) {
+		parser := stat_replica.NewV2StreamParser(ctx, stat_replica.NewStreamBuffer(t.TempDir(), stat_replica.STREAM_MEMORY_LIMIT))
+		msgs := parseStreamSample(t, parser)
+
+		// transaksi biasa keluar duluan, streaming keluar saat stream commit,
+		// subtransaksi dan transaksi yang di abort dibuang
+		assert.Equal(t, []int32{10, 1, 2}, messageIDs(msgs))
+
+		for _, msg := range msgs[1:] {
+			assert.Equal(t, uint32(1000), msg.Xid)
+			assert.Equal(t, pglogrepl.LSN(0x2118316C720), msg.CommitLSN)
+			assert.NotZero(t, msg.CommitTimestamp)
+			assert.Equal(t, "stream_items", msg.SourceMetadata.Table)
+		}
+	})
+
+	t.Run("testing streaming spill ke disk", func(t *testing.T) {
+		spillDir := t.TempDir()
+		parser := stat_replica.NewV2StreamParser(ctx, stat_replica.NewStreamBuffer(spillDir, 1))
+		msgs := parseStreamSample(t, parser)
+
+		assert.Equal(t, []int32{10, 1, 2}, messageIDs(msgs))
+
+		t.Run("testing file spill dihapus setelah commit dan abort", func(t *testing.T) {
+			files, err := os.ReadDir(spillDir)
+			assert.Nil(t, err)
+			assert.Empty(t, files)
+		})
+	})
+}
+
+func TestStreamBuffer(t *testing.T) {
+	buffer := stat_replica.NewStreamBuffer(t.TempDir(), 8)
+
+	err := buffer.Append(1, 1, []byte("satu"))
+	assert.Nil(t, err)
+	err = buffer.Append(1, 2, []byte("dua"))
+	assert.Nil(t, err)
+	err = buffer.Append(1, 1, []byte("tiga-spill"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, buffer.Size(1))
+
+	err = buffer.Discard(1, 2)
+	assert.Nil(t, err)
+
+	released := []string{}
+	err = buffer.Release(1, func(walData []byte) error {
+		released = append(released, string(walData))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"satu", "tiga-spill"}, released)
+	assert.Equal(t, 0, buffer.Size(1))
+}
+
+func TestStreamBufferSharedSpillDir(t *testing.T) {
+	spillDir := t.TempDir()
+	first := stat_replica.NewStreamBuffer(spillDir, 1)
+	second := stat_replica.NewStreamBuffer(spillDir, 1)
+
+	// proses lain dengan xid sama tidak boleh menimpa file spill
+	assert.Nil(t, first.Append(1, 1, []byte("pertama")))
+	assert.Nil(t, second.Append(1, 1, []byte("kedua")))
+	assert.Nil(t, second.Discard(1, 1))
+
+	released := []string{}
+	err := first.Release(1, func(walData []byte) error {
+		released = append(released, string(walData))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pertama"}, released)
+
+	files, err := os.ReadDir(spillDir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
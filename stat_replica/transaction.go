@@ -0,0 +1,51 @@
+package stat_replica
+
+type TransactionHandler func(tx *CdcTransaction)
+
+// TransactionHandlers calls every handler with the committed transaction, urut sesuai waktu ditambahkan.
+type TransactionHandlers []TransactionHandler
+
+func (h TransactionHandlers) Handle(tx *CdcTransaction) {
+	for _, handler := range h {
+		handler(tx)
+	}
+}
+
+// TransactionBuffer collects parsed messages until their transaction commits.
+type TransactionBuffer interface {
+	Add(msg *CdcMessage)
+	Flush() *CdcTransaction
+}
+
+type transactionBufferImpl struct {
+	tx *CdcTransaction
+}
+
+// Add implements TransactionBuffer.
+func (t *transactionBufferImpl) Add(msg *CdcMessage) {
+	if msg == nil {
+		return
+	}
+
+	if t.tx == nil {
+		t.tx = &CdcTransaction{
+			Xid:             msg.Xid,
+			CommitLSN:       msg.CommitLSN,
+			CommitTimestamp: msg.CommitTimestamp,
+			Messages:        []*CdcMessage{},
+		}
+	}
+
+	t.tx.Messages = append(t.tx.Messages, msg)
+}
+
+// Flush implements TransactionBuffer. returns nil when the transaction had no changes.
+func (t *transactionBufferImpl) Flush() *CdcTransaction {
+	tx := t.tx
+	t.tx = nil
+	return tx
+}
+
+func NewTransactionBuffer() TransactionBuffer {
+	return &transactionBufferImpl{}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
 )
@@ -33,6 +34,38 @@ func DumpBytesToFile(path string, data []byte) error {
 	return nil
 }
 
+type fileDeadLetter struct {
+	path string
+}
+
+// Send implements DeadLetterSink.
+func (f *fileDeadLetter) Send(row *DeadLetterRow) error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	raw = append(raw, []byte("\n")...)
+	_, err = file.Write(raw)
+	if err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+
+	return file.Sync()
+}
+
+// NewFileDeadLetter appends every dead letter row as one json line to path.
+func NewFileDeadLetter(path string) DeadLetterSink {
+	return &fileDeadLetter{
+		path: path,
+	}
+}
+
 type ValueHandler func(value []byte)
 
 // ExtractValuesAsBytes reads the file and sends each non-separator line as []byte to the handler
@@ -53,13 +86,13 @@ func ExtractValuesAsBytes(path string, handler ValueHandler) error {
 			continue
 		}
 
-		decoded, err := base64.StdEncoding.DecodeString(string(trimmed))
+		frame, err := unmarshalWalFrame(trimmed)
 		if err != nil {
 			return err
 		}
 
 		// Pass the raw bytes to handler
-		handler(decoded)
+		handler(frame.Data)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -0,0 +1,375 @@
+package stat_replica
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// WalFrame is one captured XLogData, satu baris file: lsn<TAB>unix micro<TAB>base64 wal data.
+// File lama hanya berisi base64, LSN dan Time kosong.
+type WalFrame struct {
+	LSN  pglogrepl.LSN
+	Time time.Time
+	Data []byte
+}
+
+func (f *WalFrame) marshal() []byte {
+	var micro int64
+	if !f.Time.IsZero() {
+		micro = f.Time.UnixMicro()
+	}
+	line := fmt.Sprintf("%s\t%d\t%s\n", f.LSN, micro, base64.StdEncoding.EncodeToString(f.Data))
+	return []byte(line)
+}
+
+func unmarshalWalFrame(line []byte) (*WalFrame, error) {
+	fields := bytes.Split(line, []byte("\t"))
+	frame := WalFrame{}
+
+	var err error
+	switch len(fields) {
+	case 1:
+	case 3:
+		frame.LSN, err = pglogrepl.ParseLSN(string(fields[0]))
+		if err != nil {
+			return nil, err
+		}
+		micro, err := strconv.ParseInt(string(fields[1]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if micro != 0 {
+			frame.Time = time.UnixMicro(micro)
+		}
+	default:
+		return nil, fmt.Errorf("invalid wal frame with %d fields", len(fields))
+	}
+
+	frame.Data, err = base64.StdEncoding.DecodeString(string(fields[len(fields)-1]))
+	return &frame, err
+}
+
+// WalWriter writes many frames to one file with one buffer, dipakai saat slice dan selama satu sesi LogFile.
+type WalWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func (w *WalWriter) Write(frame *WalFrame) error {
+	_, err := w.buf.Write(frame.marshal())
+	return err
+}
+
+// Flush writes the buffered frames and syncs the file, dipanggil berkala bukan per frame.
+func (w *WalWriter) Flush() error {
+	err := w.buf.Flush()
+	if err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *WalWriter) Close() error {
+	err := w.buf.Flush()
+	if err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func NewWalWriter(path string) (*WalWriter, error) {
+	return openWalWriter(path, os.O_TRUNC)
+}
+
+// AppendWalWriter continues an existing capture, sesi record berikutnya ditambahkan di akhir file.
+func AppendWalWriter(path string) (*WalWriter, error) {
+	return openWalWriter(path, os.O_APPEND)
+}
+
+func openWalWriter(path string, mode int) (*WalWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|mode, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return &WalWriter{
+		file: file,
+		buf:  bufio.NewWriter(file),
+	}, nil
+}
+
+// ReadWalFrames reads a capture file in order, both the frame and the old base64 only format.
+func ReadWalFrames(path string, handler func(frame *WalFrame) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// satu frame bisa sebesar satu row dengan kolom toast
+	scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+	for scanner.Scan() {
+		trimmed := bytes.TrimSpace(scanner.Bytes())
+		if len(trimmed) == 0 {
+			continue
+		}
+
+		frame, err := unmarshalWalFrame(trimmed)
+		if err != nil {
+			return err
+		}
+
+		err = handler(frame)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	return nil
+}
+
+// WalFilter slices a capture by commit time and table. Relation dan type message selalu lolos
+// supaya parser tetap kenal tabelnya.
+type WalFilter struct {
+	// zero berarti tanpa batas
+	From time.Time
+	To   time.Time
+	// nama tabel atau schema.table, kosong berarti semua tabel
+	Tables []string
+
+	inStream  bool
+	keepTx    bool
+	streamXid uint32
+	streams   map[uint32]bool
+	relations map[uint32]*SourceMetadata
+}
+
+func (f *WalFilter) inWindow(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	if !f.From.IsZero() && t.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !t.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+func (f *WalFilter) matchRelation(relationID uint32) bool {
+	if len(f.Tables) == 0 {
+		return true
+	}
+
+	meta, ok := f.relations[relationID]
+	if !ok {
+		// biar parser yang melaporkan unknown relation
+		return true
+	}
+	for _, table := range f.Tables {
+		if table == meta.Table || table == meta.Schema+"."+meta.Table {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *WalFilter) current() bool {
+	if f.inStream {
+		return f.streams[f.streamXid]
+	}
+	return f.keepTx
+}
+
+// Keep reports whether the frame is part of the slice, frames must be passed in capture order.
+// Transaksi biasa dipotong berdasarkan commit time, transaksi streaming berdasarkan waktu rekam segment pertama.
+func (f *WalFilter) Keep(frame *WalFrame) (bool, error) {
+	logicalMsg, err := pglogrepl.ParseV2(frame.Data, f.inStream)
+	if err != nil {
+		return false, fmt.Errorf("Parse Logical Message Failed %s", err.Error())
+	}
+
+	switch msg := logicalMsg.(type) {
+	case *pglogrepl.RelationMessageV2:
+		f.relations[msg.RelationID] = &SourceMetadata{
+			Table:  msg.RelationName,
+			Schema: msg.Namespace,
+		}
+		return !f.inStream || f.current(), nil
+
+	case *pglogrepl.TypeMessageV2:
+		return !f.inStream || f.current(), nil
+
+	case *pglogrepl.BeginMessage:
+		f.keepTx = f.inWindow(msg.CommitTime)
+		return f.keepTx, nil
+
+	case *pglogrepl.CommitMessage:
+		keep := f.keepTx
+		f.keepTx = false
+		return keep, nil
+
+	case *pglogrepl.InsertMessageV2:
+		return f.current() && f.matchRelation(msg.RelationID), nil
+
+	case *pglogrepl.UpdateMessageV2:
+		return f.current() && f.matchRelation(msg.RelationID), nil
+
+	case *pglogrepl.DeleteMessageV2:
+		return f.current() && f.matchRelation(msg.RelationID), nil
+
+	case *pglogrepl.TruncateMessageV2:
+		if !f.current() {
+			return false, nil
+		}
+		for _, relationID := range msg.RelationIDs {
+			if f.matchRelation(relationID) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case *pglogrepl.StreamStartMessageV2:
+		f.inStream = true
+		f.streamXid = msg.Xid
+		_, ok := f.streams[msg.Xid]
+		if msg.FirstSegment == 1 || !ok {
+			f.streams[msg.Xid] = f.inWindow(frame.Time)
+		}
+		return f.streams[msg.Xid], nil
+
+	case *pglogrepl.StreamStopMessageV2:
+		keep := f.current()
+		f.inStream = false
+		return keep, nil
+
+	case *pglogrepl.StreamCommitMessageV2:
+		keep := f.streams[msg.Xid]
+		delete(f.streams, msg.Xid)
+		return keep, nil
+
+	case *pglogrepl.StreamAbortMessageV2:
+		keep := f.streams[msg.Xid]
+		if msg.Xid == msg.SubXid {
+			delete(f.streams, msg.Xid)
+		}
+		return keep, nil
+	}
+
+	// origin dan logical decoding message ikut transaksinya
+	return f.current(), nil
+}
+
+func NewWalFilter(from, to time.Time, tables ...string) *WalFilter {
+	return &WalFilter{
+		From:      from,
+		To:        to,
+		Tables:    tables,
+		streams:   map[uint32]bool{},
+		relations: map[uint32]*SourceMetadata{},
+	}
+}
+
+// Replay feeds a capture file through Parser, at the original pace or faster.
+type Replay struct {
+	ctx    context.Context
+	path   string
+	filter *WalFilter
+	speed  float64
+	parser Parser
+}
+
+// SetSpeed sets the pace against the capture, 1 sesuai waktu rekam, 10 sepuluh kali lebih cepat, 0 tanpa jeda.
+func (r *Replay) SetSpeed(speed float64) {
+	r.speed = speed
+}
+
+func (r *Replay) RegisterType(t *pgtype.Type) {
+	r.parser.RegisterType(t)
+}
+
+func (r *Replay) SetTypeLookup(lookup TypeLookup) {
+	r.parser.SetTypeLookup(lookup)
+}
+
+func (r *Replay) SetErrorPolicy(policy ErrorPolicy, sink DeadLetterSink) {
+	r.parser.SetErrorPolicy(policy, sink)
+}
+
+// Start parses every kept frame and calls handler for each message, returns when the file ends.
+func (r *Replay) Start(handler ReplicationHandler) error {
+	var first time.Time
+	var started time.Time
+
+	return ReadWalFrames(r.path, func(frame *WalFrame) error {
+		if r.filter != nil {
+			keep, err := r.filter.Keep(frame)
+			if err != nil {
+				return err
+			}
+			if !keep {
+				return nil
+			}
+		}
+
+		if r.speed > 0 && !frame.Time.IsZero() {
+			if first.IsZero() {
+				first = frame.Time
+				started = time.Now()
+			}
+			err := r.wait(started.Add(time.Duration(float64(frame.Time.Sub(first)) / r.speed)))
+			if err != nil {
+				return err
+			}
+		}
+
+		msgs, err := r.parser.Parse(frame.Data)
+		if err != nil {
+			return fmt.Errorf("replay frame %s: %w", frame.LSN, err)
+		}
+		for _, msg := range msgs {
+			handler(msg)
+		}
+		return nil
+	})
+}
+
+func (r *Replay) wait(until time.Time) error {
+	delay := time.Until(until)
+	if delay <= 0 {
+		return r.ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// NewReplay creates a replay of path, filter boleh nil untuk memutar semua frame.
+func NewReplay(ctx context.Context, path string, filter *WalFilter) *Replay {
+	return &Replay{
+		ctx:    ctx,
+		path:   path,
+		filter: filter,
+		parser: NewV2StreamParser(ctx, NewStreamBuffer(os.TempDir(), STREAM_MEMORY_LIMIT)),
+	}
+}
@@ -0,0 +1,149 @@
+package stat_replica_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/pdcgo/materialize/stat_replica"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureSample writes a sample file as frames, tiap frame berjarak 10ms.
+func captureSample(t *testing.T, sample string) (string, time.Time) {
+	fname := filepath.Join(t.TempDir(), "capture.wal")
+	writer, err := stat_replica.NewWalWriter(fname)
+	assert.Nil(t, err)
+
+	start := time.Now().Truncate(time.Second)
+	i := 0
+	err = stat_replica.ExtractValuesAsBytes(sample, func(value []byte) {
+		err := writer.Write(&stat_replica.WalFrame{
+			LSN:  pglogrepl.LSN(i + 1),
+			Time: start.Add(time.Duration(i) * 10 * time.Millisecond),
+			Data: value,
+		})
+		assert.Nil(t, err)
+		i += 1
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+	return fname, start
+}
+
+func replayAll(t *testing.T, replay *stat_replica.Replay) []*stat_replica.CdcMessage {
+	msgs := []*stat_replica.CdcMessage{}
+	err := replay.Start(func(msg *stat_replica.CdcMessage) {
+		msgs = append(msgs, msg)
+	})
+	assert.Nil(t, err)
+	return msgs
+}
+
+func TestWalReplay(t *testing.T) {
+	sample := "../test_assets/wal_samples/wal_2025-01-08.sample"
+	fname, start := captureSample(t, sample)
+
+	ctx := stat_replica.ContextWithCoder(t.Context())
+	expected := []*stat_replica.CdcMessage{}
+	parser := stat_replica.NewV2Parser(ctx)
+	err := stat_replica.ExtractValuesAsBytes(sample, func(value []byte) {
+		msgs, err := parser.Parse(value)
+		assert.Nil(t, err)
+		expected = append(expected, msgs...)
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, expected)
+
+	t.Run("testing frame terbaca dengan lsn dan waktu", func(t *testing.T) {
+		frames := []*stat_replica.WalFrame{}
+		err := stat_replica.ReadWalFrames(fname, func(frame *stat_replica.WalFrame) error {
+			frames = append(frames, frame)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, pglogrepl.LSN(1), frames[0].LSN)
+		assert.True(t, start.Equal(frames[0].Time))
+		assert.True(t, start.Add(10*time.Millisecond).Equal(frames[1].Time))
+	})
+
+	t.Run("testing replay semua sama dengan parse langsung", func(t *testing.T) {
+		msgs := replayAll(t, stat_replica.NewReplay(ctx, fname, nil))
+		assert.Len(t, msgs, len(expected))
+		for i := range msgs {
+			assert.Equal(t, expected[i].SourceMetadata, msgs[i].SourceMetadata)
+			assert.Equal(t, expected[i].CommitLSN, msgs[i].CommitLSN)
+		}
+	})
+
+	t.Run("testing slice per tabel", func(t *testing.T) {
+		table := expected[0].SourceMetadata.Table
+		count := 0
+		for _, msg := range expected {
+			if msg.SourceMetadata.Table == table {
+				count += 1
+			}
+		}
+
+		msgs := replayAll(t, stat_replica.NewReplay(ctx, fname, stat_replica.NewWalFilter(time.Time{}, time.Time{}, table)))
+		assert.Len(t, msgs, count)
+		for _, msg := range msgs {
+			assert.Equal(t, table, msg.SourceMetadata.Table)
+		}
+	})
+
+	t.Run("testing slice per commit time", func(t *testing.T) {
+		from := time.UnixMicro(expected[len(expected)/2].CommitTimestamp)
+		count := 0
+		for _, msg := range expected {
+			if msg.CommitTimestamp >= from.UnixMicro() {
+				count += 1
+			}
+		}
+
+		msgs := replayAll(t, stat_replica.NewReplay(ctx, fname, stat_replica.NewWalFilter(from, time.Time{})))
+		assert.Len(t, msgs, count)
+		for _, msg := range msgs {
+			assert.GreaterOrEqual(t, msg.CommitTimestamp, from.UnixMicro())
+		}
+	})
+
+	t.Run("testing replay mengikuti waktu rekam", func(t *testing.T) {
+		short, _ := captureSample(t, "../test_assets/wal_samples/stream_wal.sample")
+		replay := stat_replica.NewReplay(ctx, short, nil)
+		replay.SetSpeed(2)
+
+		begin := time.Now()
+		replayAll(t, replay)
+		// 19 frame berjarak 10ms, dua kali lebih cepat
+		assert.GreaterOrEqual(t, time.Since(begin), 80*time.Millisecond)
+	})
+}
+
+func TestAppendWalWriter(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "capture.wal")
+
+	// dua sesi record ke file yang sama
+	for session := 0; session < 2; session++ {
+		writer, err := stat_replica.AppendWalWriter(fname)
+		assert.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			err = writer.Write(&stat_replica.WalFrame{
+				LSN:  pglogrepl.LSN(session*3 + i + 1),
+				Data: []byte{'B', byte(i)},
+			})
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, writer.Flush())
+		assert.Nil(t, writer.Close())
+	}
+
+	lsns := []pglogrepl.LSN{}
+	err := stat_replica.ReadWalFrames(fname, func(frame *stat_replica.WalFrame) error {
+		lsns = append(lsns, frame.LSN)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []pglogrepl.LSN{1, 2, 3, 4, 5, 6}, lsns)
+}